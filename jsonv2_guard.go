@@ -0,0 +1,10 @@
+//go:build goexperiment.jsonv2
+
+package main
+
+// PocketBase v0.19 decodes collection schemas through a json.Unmarshal call that recurses forever on the
+// encoding/json v2 backend, so a binary (or test) built with it dies with a stack overflow on the first
+// migration. Go 1.27 turns that backend on by default. Build and test with GOEXPERIMENT=nojsonv2 (or run
+// `go env -w GOEXPERIMENT=nojsonv2` once), or with the Go version from go.mod as the Dockerfile and CI do.
+// This reference fails the build instead of letting it crash at startup.
+var _ = buildWithGOEXPERIMENTnojsonv2
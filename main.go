@@ -1,25 +1,50 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/subtle"
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
 	"errors" // For errors.Is
 	"fmt"
+	"html"
+	"io"
 	"log"
+	"math"
 	"net/http"
+	"net/mail"
+	"net/url"
 	"os"
+	"os/exec"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings" // Added for worker existence check
+	"sync"
+	"text/template"
 	"time"
+	_ "time/tzdata" // Embed the tz database so APP_TIMEZONE works on minimal images
+	"unicode/utf8"
 
 	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/forms"
 	"github.com/pocketbase/pocketbase/models"
 	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/cron"
+	"github.com/pocketbase/pocketbase/tools/dbutils"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+	"github.com/pocketbase/pocketbase/tools/list"
+	"github.com/pocketbase/pocketbase/tools/mailer"
+	"github.com/pocketbase/pocketbase/tools/security"
+	"github.com/pocketbase/pocketbase/tools/subscriptions"
 	"github.com/pocketbase/pocketbase/tools/types"
 	// Cobra is imported by pocketbase.New() implicitly, ensure it's in go.mod
 	// _ "github.com/spf13/cobra"
@@ -30,7 +55,12 @@ type CalendarEntry struct {
 	Date       string `json:"date"`
 	WorkerID   string `json:"worker_id,omitempty"`
 	WorkerName string `json:"worker_name"`
-	Status     string `json:"status"` // "assigned", "queued", "past_done", "past_not_done"
+	Status     string `json:"status"` // "assigned", "pending_acceptance", "queued", "past_done", "past_not_done", "blackout"
+	Source     string `json:"source,omitempty"`
+	Reason     string `json:"reason,omitempty"` // set for blackout entries
+	// DurationDays is set for queued entries, which start on Date and cover that many days.
+	DurationDays int    `json:"duration_days,omitempty"`
+	ExternalRef  string `json:"external_ref,omitempty"`
 }
 
 // CalendarResponse defines the structure for the calendar API response.
@@ -39,876 +69,8939 @@ type CalendarResponse struct {
 	QueuedAssignments []CalendarEntry `json:"queued_assignments"`
 }
 
+// MonthCell defines a single day of the month grid API response.
+type MonthCell struct {
+	Date       string         `json:"date"`
+	InMonth    bool           `json:"in_month"` // false for padding days from the neighbouring months
+	WorkerID   string         `json:"worker_id,omitempty"`
+	WorkerName string         `json:"worker_name,omitempty"`
+	Color      string         `json:"color,omitempty"`
+	Status     string         `json:"status"` // calendar status, or "unassigned"
+	Queued     []QueuedInCell `json:"queued"`
+}
+
+// QueuedInCell is a queue item covering a month grid day.
+type QueuedInCell struct {
+	WorkerID   string `json:"worker_id"`
+	WorkerName string `json:"worker_name"`
+	Color      string `json:"color,omitempty"`
+}
+
+// Validation for worker names and colors, shared by the collection schema and the import endpoint.
 const (
-	timeLayoutYMD  = "2006-01-02"
-	timeLayoutFull = "2006-01-02 15:04:05.000Z" // PocketBase default datetime format (equivalent to types.DateTimeLayout)
+	workerNameMaxLength = 100
+	workerColorPattern  = `^#[0-9a-fA-F]{6}$`
 )
 
-// AddToQueueRequest defines the structure for the add to queue API request.
-type AddToQueueRequest struct {
-	WorkerID      string `json:"worker_id"` // Or WorkerName string `json:"worker_name"`
-	DurationDays  int    `json:"duration_days"`
-	AdminPassword string `json:"admin_password"`
-}
+// blackoutReasonMaxLength caps the reason given for a blackout date.
+const blackoutReasonMaxLength = 200
 
-// --- Helper Functions ---
+// forecastMaxDays caps how far ahead /forecast simulates.
+const forecastMaxDays = 90
 
-func formatDateToYMDGo(t time.Time) string {
-	return t.Format(timeLayoutYMD)
-}
+// fairnessDiagnosticsMaxDays caps how far ahead /diagnostics/fairness simulates.
+const fairnessDiagnosticsMaxDays = 730
 
-func getTodayYMDGo() string {
-	return formatDateToYMDGo(time.Now().UTC())
-}
+// recentDefaultLimit and recentMaxLimit are the default and largest limit accepted by /recent.
+const (
+	recentDefaultLimit = 10
+	recentMaxLimit     = 100
+)
 
-func parseYMDToGoTime(ymd string) (time.Time, error) {
-	return time.Parse(timeLayoutYMD, ymd)
-}
+// selectionBiasMaxDays caps how far a worker's selection_bias can move their last turn either way.
+const selectionBiasMaxDays = 365
 
-func addDaysToYMDGo(ymdString string, days int) (string, error) {
-	t, err := parseYMDToGoTime(ymdString)
-	if err != nil {
-		return "", err
-	}
-	t = t.AddDate(0, 0, days)
-	return formatDateToYMDGo(t), nil
-}
+// externalRefMaxLength caps the external_ref an integration can attach to an assignment or queue item.
+const externalRefMaxLength = 100
 
-func isAdminGo(providedPassword string) bool {
-	adminPass := os.Getenv("ADMIN_PASS")
-	if adminPass == "" {
-		log.Println("Warning: ADMIN_PASS environment variable is not set. Admin actions will be blocked.")
-		return false
-	}
-	return providedPassword == adminPass
-}
+// historyBatchSize is how many records are loaded at a time when walking the whole history, which grows
+// without bound; everything else reads assignments for a bounded date range.
+const historyBatchSize = 500
 
-func logActionGo(dao *daos.Dao, actionType string, details map[string]interface{}) error {
-	actionLogCollection, err := dao.FindCollectionByNameOrId("action_log")
-	if err != nil {
-		log.Printf("Error finding 'action_log' collection for logging: %v", err)
-		return fmt.Errorf("failed to find action_log collection: %w", err)
-	}
+// exportCollections are the collections written by /api/dishduty/export, in order. The action log is
+// only added on request since it is usually the largest and least needed for a restore.
+var exportCollections = []string{"workers", "assignments", "assignment_queue", "recurring_assignments", "settings"}
 
-	record := models.NewRecord(actionLogCollection)
-	record.Set("action_type", actionType)
-	record.Set("timestamp", time.Now().UTC().Format(timeLayoutFull)) // Use timeLayoutFull
+// queueOrderColumns is the sort used wherever the queue is read in order. Nothing makes `order` unique, so
+// start_date and id break ties deterministically.
+var queueOrderColumns = []string{"order ASC", "start_date ASC", "id ASC"}
 
-	if details != nil {
-		detailsJSON, jsonErr := json.Marshal(details)
-		if jsonErr != nil {
-			log.Printf("Error marshalling details for action log '%s': %v", actionType, jsonErr)
-			record.Set("details", fmt.Sprintf(`{"error": "failed to marshal details: %s"}`, jsonErr.Error()))
-		} else {
-			record.Set("details", string(detailsJSON))
-		}
-	}
+// Values of assignment_queue.source. Items from before the field existed count as manual.
+const (
+	queueSourceManual = "manual" // added through /api/dishduty/queue/add or the admin UI
+	queueSourceAuto   = "auto"   // appended by the AUTO_QUEUE_AHEAD_DAYS top-up, replanned when the roster changes
+)
 
-	if err := dao.SaveRecord(record); err != nil {
-		log.Printf("Error saving action_log record for action '%s': %v", actionType, err)
-		return fmt.Errorf("failed to save action_log record: %w", err)
-	}
-	return nil
-}
+var queueItemSources = []string{queueSourceManual, queueSourceAuto}
 
-func main() {
-	app := pocketbase.New()
+// Values of assignment_queue.coverage and assignments.coverage. A half-day queue item lasts a single day,
+// which it shares with whoever takes the other half. Records from before the field existed cover the whole day.
+const (
+	coverageFull = "full"
+	coverageAM   = "am"
+	coveragePM   = "pm"
+)
 
-	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
-		dao := app.Dao()
-
-		// --- Define Workers Collection ---
-		var workersCollection *models.Collection
-		existingWorkers, _ := dao.FindCollectionByNameOrId("workers")
-
-		if existingWorkers == nil {
-			workersCollection = &models.Collection{
-				Name:       "workers",
-				Type:       models.CollectionTypeBase,
-				ListRule:   nil,
-				ViewRule:   nil,
-				CreateRule: types.Pointer("@request.auth.id != '' && @request.auth.admin = true"),
-				UpdateRule: types.Pointer("@request.auth.id != '' && @request.auth.admin = true"),
-				DeleteRule: types.Pointer("@request.auth.id != '' && @request.auth.admin = true"),
-				Schema: schema.NewSchema(
-					&schema.SchemaField{
-						Name:     "name",
-						Type:     schema.FieldTypeText,
-						Required: true,
-						Unique:   true,
-						System:   false,
-						Options:  &schema.TextOptions{Min: types.Pointer(1), Max: nil, Pattern: ""},
-					},
-					&schema.SchemaField{
-						Name:     "last_assigned_date",
-						Type:     schema.FieldTypeDate,
-						Required: false,
-						System:   false,
-						Options:  &schema.DateOptions{},
-					},
-				),
-			}
-			if err := dao.SaveCollection(workersCollection); err != nil {
-				log.Printf("Error creating 'workers' collection: %v", err)
-				return err
-			}
-			log.Println("'workers' collection created successfully.")
-		} else {
-			log.Println("'workers' collection already exists.")
-			workersCollection = existingWorkers
+var coverageValues = []string{coverageFull, coverageAM, coveragePM}
 
-			rulesChanged := false
-			// Ensure ListRule is nil for public access
-			if workersCollection.ListRule != nil {
-				workersCollection.ListRule = nil
-				rulesChanged = true
-			}
-			// Ensure ViewRule is nil for public access
-			if workersCollection.ViewRule != nil {
-				workersCollection.ViewRule = nil
-				rulesChanged = true
-			}
+// autoQueueWorkerFields are the worker fields the fairness pick looks at. Changing one of them replans the
+// roster's auto-queued days.
+var autoQueueWorkerFields = []string{"inactive", "roster_id", "weekend_ok", "priority", "selection_bias", "selection_bias_until"}
 
-			// Ensure CUD rules are for admin only, consistent with initial creation logic
-			// Initial creation uses: types.Pointer("@request.auth.id != '' && @request.auth.admin = true")
-			// The instruction uses @request.admin = true. We'll stick to @request.auth.admin for consistency with how it's defined at creation.
-			expectedAdminCudRule := types.Pointer("@request.auth.id != '' && @request.auth.admin == true")
+// Supported values for the import mode query parameter, and the largest import document accepted.
+const (
+	importModeMerge   = "merge"   // keep local data; add imported workers, days and queue items that are missing (default)
+	importModeReplace = "replace" // the import becomes the new state; local data it doesn't contain is deleted
+	importMaxBytes    = 64 << 20
+)
 
-			if workersCollection.CreateRule == nil || *workersCollection.CreateRule != *expectedAdminCudRule {
-				workersCollection.CreateRule = expectedAdminCudRule
-				rulesChanged = true
-			}
-			if workersCollection.UpdateRule == nil || *workersCollection.UpdateRule != *expectedAdminCudRule {
-				workersCollection.UpdateRule = expectedAdminCudRule
-				rulesChanged = true
-			}
-			if workersCollection.DeleteRule == nil || *workersCollection.DeleteRule != *expectedAdminCudRule {
-				workersCollection.DeleteRule = expectedAdminCudRule
-				rulesChanged = true
-			}
+// proofMaxBytes caps the size of a photo attached when marking an assignment done.
+const proofMaxBytes = 5 << 20
 
-			if rulesChanged {
-				if err := dao.SaveCollection(workersCollection); err != nil {
-					log.Printf("Error saving 'workers' collection with updated rules: %v", err)
-					return fmt.Errorf("failed to save workers collection with updated rules: %w", err)
-				}
-				log.Println("'workers' collection API rules explicitly set/updated for public read and admin CUD.")
-			} else {
-				log.Println("'workers' collection API rules already conform to public read and admin CUD.")
-			}
-		}
-
-		if workersCollection == nil || workersCollection.Id == "" {
-			log.Println("Critical error: 'workers' collection could not be initialized.")
-			return errors.New("workers collection not found and could not be created")
-		}
-
-		// --- Define Assignments Collection ---
-		existingAssignments, _ := dao.FindCollectionByNameOrId("assignments")
-		if existingAssignments == nil {
-			assignmentsCollection := &models.Collection{
-				Name:       "assignments",
-				Type:       models.CollectionTypeBase,
-				ListRule:   nil,
-				ViewRule:   nil,
-				CreateRule: types.Pointer("@request.auth.id != ''"),
-				UpdateRule: types.Pointer("@request.auth.id != ''"),
-				DeleteRule: types.Pointer("@request.auth.id != ''"),
-				Schema: schema.NewSchema(
-					&schema.SchemaField{
-						Name:     "worker_id",
-						Type:     schema.FieldTypeRelation,
-						Required: true,
-						Options: &schema.RelationOptions{
-							CollectionId:  workersCollection.Id,
-							CascadeDelete: false,
-							MinSelect:     types.Pointer(1),
-							MaxSelect:     types.Pointer(1),
-						},
-					},
-					&schema.SchemaField{
-						Name:     "date",
-						Type:     schema.FieldTypeDate,
-						Required: true,
-						Unique:   true,
-						Options:  &schema.DateOptions{},
-					},
-					&schema.SchemaField{
-						Name:     "status",
-						Type:     schema.FieldTypeSelect,
-						Required: true,
-						Options: &schema.SelectOptions{
-							MaxSelect: 1,
-							Values:    []string{"assigned", "done", "not_done"},
-						},
-					},
-				),
-			}
-			if err := dao.SaveCollection(assignmentsCollection); err != nil {
-				log.Printf("Error creating 'assignments' collection: %v", err)
-				return err
-			}
-			log.Println("'assignments' collection created successfully.")
-		} else {
-			log.Println("'assignments' collection already exists.")
-		}
-
-		// --- Define Assignment Queue Collection ---
-		existingAssignmentQueue, _ := dao.FindCollectionByNameOrId("assignment_queue")
-		if existingAssignmentQueue == nil {
-			assignmentQueueCollection := &models.Collection{
-				Name:       "assignment_queue",
-				Type:       models.CollectionTypeBase,
-				ListRule:   nil,
-				ViewRule:   nil,
-				CreateRule: types.Pointer("@request.auth.id != '' && @request.auth.admin = true"),
-				UpdateRule: types.Pointer("@request.auth.id != '' && @request.auth.admin = true"),
-				DeleteRule: types.Pointer("@request.auth.id != '' && @request.auth.admin = true"),
-				Schema: schema.NewSchema(
-					&schema.SchemaField{
-						Name: "worker_id", Type: schema.FieldTypeRelation, Required: true,
-						Options: &schema.RelationOptions{CollectionId: workersCollection.Id, CascadeDelete: false, MinSelect: types.Pointer(1), MaxSelect: types.Pointer(1)},
-					},
-					&schema.SchemaField{Name: "start_date", Type: schema.FieldTypeDate, Required: true, Options: &schema.DateOptions{}},
-					&schema.SchemaField{Name: "duration_days", Type: schema.FieldTypeNumber, Required: true, Options: &schema.NumberOptions{Min: types.Pointer(1.0), Max: types.Pointer(7.0), NoDecimal: true}},
-					&schema.SchemaField{Name: "order", Type: schema.FieldTypeNumber, Required: true, Options: &schema.NumberOptions{NoDecimal: true}},
-				),
-			}
-			if err := dao.SaveCollection(assignmentQueueCollection); err != nil {
-				log.Printf("Error creating 'assignment_queue' collection: %v", err)
-				return err
-			}
-			log.Println("'assignment_queue' collection created successfully.")
-		} else {
-			log.Println("'assignment_queue' collection already exists.")
-		}
+// proofMimeTypes lists the image types accepted as proof.
+var proofMimeTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
 
-		// --- Define Action Log Collection ---
-		existingActionLog, _ := dao.FindCollectionByNameOrId("action_log")
-		if existingActionLog == nil {
-			actionLogCollection := &models.Collection{
-				Name: "action_log", Type: models.CollectionTypeBase,
-				ListRule: types.Pointer("@request.auth.id != '' && @request.auth.admin = true"), ViewRule: types.Pointer("@request.auth.id != '' && @request.auth.admin = true"),
-				CreateRule: types.Pointer("@request.auth.id != ''"), UpdateRule: types.Pointer(""), DeleteRule: types.Pointer(""),
-				Schema: schema.NewSchema(
-					&schema.SchemaField{Name: "timestamp", Type: schema.FieldTypeDate, Required: true, Options: &schema.DateOptions{}},
-					&schema.SchemaField{Name: "action_type", Type: schema.FieldTypeSelect, Required: true, Options: &schema.SelectOptions{MaxSelect: 1, Values: []string{"assigned", "added_to_queue", "marked_not_done", "randomly_assigned", "queue_processed"}}},
-					&schema.SchemaField{Name: "details", Type: schema.FieldTypeJson, Required: false, Options: &schema.JsonOptions{}},
-				),
-			}
-			if err := dao.SaveCollection(actionLogCollection); err != nil {
-				log.Printf("Error creating 'action_log' collection: %v", err)
-				return err
-			}
-			log.Println("'action_log' collection created successfully.")
-		} else {
-			log.Println("'action_log' collection already exists.")
-		}
+// doneNoteMaxLength caps the note that can be left instead of a proof photo.
+const doneNoteMaxLength = 500
 
-		// --- Seed Initial Workers ---
-		if workersCollection != nil && workersCollection.Id != "" {
-			workerNames := []string{"keromag", "megatorg", "baby-ch"}
-			for _, workerName := range workerNames {
-				var existingRecord models.Record   // Important to declare it to receive the result
-				err := dao.RecordQuery("workers"). // Using dao which is app.Dao()
-									AndWhere(dbx.NewExp("LOWER(name) = LOWER({:workerName})", dbx.Params{"workerName": workerName})).
-									Limit(1).
-									One(&existingRecord) // Use One to fetch into existingRecord
+const (
+	timeLayoutYMD  = "2006-01-02"
+	timeLayoutFull = "2006-01-02 15:04:05.000Z" // PocketBase default datetime format (equivalent to types.DateTimeLayout)
+)
 
-				if err == nil && existingRecord.Id != "" {
-					log.Printf("Worker '%s' already exists. Skipping.", workerName)
-					continue
-				}
-				// Check specifically for "no rows" or other "not found" variations
-				if err != nil && !(errors.Is(err, sql.ErrNoRows) || strings.Contains(strings.ToLower(err.Error()), "no record found") || strings.Contains(strings.ToLower(err.Error()), "no rows in result set")) {
-					log.Printf("Error checking if worker '%s' exists: %v", workerName, err)
-					continue
-				}
-				// If err is sql.ErrNoRows (or similar) or (err == nil && existingRecord.Id is empty), proceed to create
-				log.Printf("Worker '%s' does not exist or error was 'no rows'. Creating...", workerName)
-				record := models.NewRecord(workersCollection)
-				record.Set("name", workerName)
-				if errSave := dao.SaveRecord(record); errSave != nil {
-					log.Printf("Error seeding worker '%s': %v", workerName, errSave)
-				} else {
-					log.Printf("Worker '%s' seeded successfully.", workerName)
-				}
-			}
-		} else {
-			log.Println("'workers' collection not found or invalid, cannot seed workers.")
-		}
+// assignmentRealtimeTopic is the PocketBase realtime topic that receives every assignment change, so
+// dashboards can update live instead of polling /current-assignee. Clients subscribe through the
+// regular realtime API, e.g. with the JS SDK: pb.realtime.subscribe("dishduty:assignment", callback).
+// Each message is {"action": "create"|"update"|"delete", "assignment": {...}} with the same assignment
+// fields as GET /api/dishduty/assignments/by-date/:date.
+const assignmentRealtimeTopic = "dishduty:assignment"
 
-		// --- API Routes ---
+// Supported values for the ON_EMPTY_QUEUE environment variable.
+const (
+	onEmptyQueueRandom = "random" // Fall back to fair random assignment (default)
+	onEmptyQueueStop   = "stop"   // Treat the queue as the schedule and stop assigning once it's empty
+)
 
-		// GET /api/dishduty/workers
-		e.Router.AddRoute(echo.Route{
-			Method: http.MethodGet,
-			Path:   "/api/dishduty/workers", // New dedicated endpoint
-			Handler: func(c echo.Context) error {
-				records, err := app.Dao().FindRecordsByFilter(
-					"workers",
-					"1=1",   // Get all records
-					"+name", // Sort by name ascending
-					0,       // No limit (get all)
-					0,       // No offset
-				)
-				if err != nil {
-					log.Printf("Error fetching workers for API: %v", err)
-					return apis.NewApiError(http.StatusInternalServerError, "Failed to fetch workers.", err)
-				}
-				return c.JSON(http.StatusOK, records)
-			},
-			Middlewares: []echo.MiddlewareFunc{
-				// No admin auth middleware here, this is public
-			},
-		})
+// Supported values for the SELECTION_MODE environment variable.
+const (
+	selectionModeOldest     = "oldest"      // Pick the worker with the oldest last_assigned_date (default)
+	selectionModeRoundRobin = "round_robin" // Everyone active is assigned once before anyone repeats
+)
 
-		// POST /api/dishduty/queue/add
-		e.Router.AddRoute(echo.Route{
-			Method: http.MethodPost,
-			Path:   "/api/dishduty/queue/add",
-			Handler: func(c echo.Context) error {
-				var req AddToQueueRequest // Use the new struct type
+// Supported values for the FAIRNESS_RESET environment variable.
+const (
+	fairnessResetNever   = "never"   // Fairness history carries over indefinitely (default)
+	fairnessResetMonthly = "monthly" // Everyone starts from a clean slate on the 1st of each month
+)
 
-				if err := c.Bind(&req); err != nil {
-					log.Printf("Error binding request for add to queue: %v", err)
-					return apis.NewBadRequestError("Invalid request body.", err)
-				}
+// actionLogTypes lists every allowed action_log.action_type value.
+var actionLogTypes = []string{
+	"assigned",
+	"added_to_queue",
+	"marked_not_done",
+	"randomly_assigned",
+	"queue_processed",
+	"queue_recomputed",
+	"queue_clamped",
+	"settings_updated",
+	"recurring_assigned",
+	"recurring_rule_created",
+	"recurring_rule_updated",
+	"recurring_rule_deleted",
+	"reminder_sent",
+	"reassigned_coverage",
+	"workers_imported",
+	"queue_cleaned_for_inactive",
+	"worker_updated",
+	"manual_assign",
+	"fairness_reset",
+	"today_reset",
+	"digest_sent",
+	"last_assigned_repaired",
+	"admin_request",
+	"backfill",
+	"state_imported",
+	"queue_reordered",
+	"queue_topped_up",
+	"queue_accepted",
+	"queue_declined",
+	"rebalanced",
+	"roster_created",
+	"roster_updated",
+	"repeat_offender",
+	"volunteered",
+	"blackout_added",
+	"blackout_removed",
+}
 
-				if !isAdminGo(req.AdminPassword) {
-					return apis.NewForbiddenError("Forbidden: Invalid admin password.", nil)
-				}
+// assignmentStatuses lists every allowed assignments.status value. "pending_acceptance" is only used with
+// REQUIRE_QUEUE_ACCEPTANCE, for queued days the worker hasn't accepted yet.
+var assignmentStatuses = []string{"assigned", "done", "not_done", "pending_acceptance"}
 
-				// Validate DurationDays
-				if req.DurationDays < 1 || req.DurationDays > 7 {
-					log.Printf("Validation error: duration_days %d out of range", req.DurationDays)
-					return apis.NewBadRequestError("duration_days must be between 1 and 7.", nil)
-				}
+// assignmentSources lists every allowed assignments.source value, i.e. how an assignment was chosen.
+// "unknown" marks assignments created before the source was recorded.
+var assignmentSources = []string{
+	"queue",
+	"random",
+	"manual",
+	"backup",
+	"unknown",
+	"recurring",
+	"backfill",
+	"rebalance",
+	"volunteer",
+}
 
-				var worker *models.Record
-				var errFindWorker error
-				// Note: The AddToQueueRequest struct only has WorkerID. If WorkerName is also needed,
-				// the struct and frontend payload should be updated. For now, assuming WorkerID is primary.
-				if req.WorkerID != "" {
-					worker, errFindWorker = dao.FindRecordById("workers", req.WorkerID)
-				} else {
-					// If WorkerID is not provided, and WorkerName was an option, this logic would need adjustment.
-					// Based on current struct, WorkerID is expected.
-					return apis.NewBadRequestError("Bad Request: worker_id is required.", nil)
-				}
-				if errFindWorker != nil || worker == nil {
-					log.Printf("Error finding worker (id: %s): %v", req.WorkerID, errFindWorker)
-					return apis.NewNotFoundError("Not Found: Worker not found.", errFindWorker)
-				}
-
-				var startDateYMD string
-				order := 1
-				todayYMD := getTodayYMDGo()
-
-				lastQueueItem, _ := dao.FindFirstRecordByFilter("assignment_queue", "1=1 ORDER BY {{order}} DESC")
-				if lastQueueItem != nil {
-					lastQueueItemStartDate := lastQueueItem.GetTime("start_date")
-					lastQueueItemDuration := lastQueueItem.GetInt("duration_days")
-					lastQueueItemEndDate := formatDateToYMDGo(lastQueueItemStartDate.AddDate(0, 0, lastQueueItemDuration-1))
-					startDateYMD, _ = addDaysToYMDGo(lastQueueItemEndDate, 1)
-					order = lastQueueItem.GetInt("order") + 1
-				} else {
-					latestAssignment, _ := dao.FindFirstRecordByFilter("assignments", "1=1 ORDER BY date DESC")
-					if latestAssignment != nil {
-						latestAssignmentDate := latestAssignment.GetTime("date")
-						latestAssignmentYMD := formatDateToYMDGo(latestAssignmentDate)
-						parsedLatestAssignmentDate, _ := parseYMDToGoTime(latestAssignmentYMD)
-						parsedToday, _ := parseYMDToGoTime(todayYMD)
-						if parsedLatestAssignmentDate.After(parsedToday) || parsedLatestAssignmentDate.Equal(parsedToday) {
-							startDateYMD, _ = addDaysToYMDGo(latestAssignmentYMD, 1)
-						} else {
-							startDateYMD = todayYMD
-						}
-					} else {
-						startDateYMD = todayYMD
-					}
-				}
+// notificationChannels lists the allowed workers.notify_channels values.
+var notificationChannels = []string{"telegram", "email"}
 
-				parsedStartDate, _ := parseYMDToGoTime(startDateYMD)
-				parsedToday, _ := parseYMDToGoTime(todayYMD)
-				if parsedStartDate.Before(parsedToday) {
-					startDateYMD = todayYMD
-				}
+// weekdayNames lists the allowed recurring_assignments.weekday values, indexed like time.Weekday.
+var weekdayNames = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
 
-				finalStartDateForRecord, errParseFinal := time.Parse(timeLayoutYMD, startDateYMD)
-				if errParseFinal != nil {
-					log.Printf("Error parsing final startDateYMD '%s' for queue: %v", startDateYMD, errParseFinal)
-					return apis.NewApiError(http.StatusInternalServerError, "Error formatting start date for DB.", errParseFinal)
-				}
+// StatusResponse defines the structure for the status API response.
+type StatusResponse struct {
+	OnEmptyQueue   string `json:"on_empty_queue"`
+	QueueLength    int    `json:"queue_length"`
+	QueueMaxItems  int    `json:"queue_max_items"` // 0 = unlimited
+	QueueExhausted bool   `json:"queue_exhausted"`
+	SnoozeUntil    string `json:"snooze_until,omitempty"` // set while automatic assignment is snoozed
+}
 
-				queueCollection, _ := dao.FindCollectionByNameOrId("assignment_queue")
-				newQueueRecord := models.NewRecord(queueCollection)
-				newQueueRecord.Set("worker_id", worker.Id)
-				newQueueRecord.Set("start_date", finalStartDateForRecord.Format(timeLayoutYMD))
-				newQueueRecord.Set("duration_days", req.DurationDays) // Use req.DurationDays
-				newQueueRecord.Set("order", order)
+// WorkerStats defines the per-worker entry of the stats API response.
+type WorkerStats struct {
+	WorkerID      string  `json:"worker_id" db:"worker_id"`
+	WorkerName    string  `json:"worker_name" db:"-"`
+	Total         int     `json:"total" db:"total"`
+	Done          int     `json:"done" db:"done"`
+	NotDone       int     `json:"not_done" db:"not_done"`
+	WeightedTotal float64 `json:"weighted_total" db:"weighted_total"`
+	WeightedDone  float64 `json:"weighted_done" db:"weighted_done"`
+}
 
-				if err := dao.SaveRecord(newQueueRecord); err != nil {
-					log.Printf("Error saving new queue record: %v", err)
-					return apis.NewApiError(http.StatusInternalServerError, "Could not add worker to queue.", err)
-				}
-				logActionGo(dao, "added_to_queue", map[string]interface{}{"worker_id": worker.Id, "worker_name": worker.GetString("name"), "duration_days": req.DurationDays, "start_date": startDateYMD, "order": order})
-				return c.JSON(http.StatusCreated, map[string]interface{}{"message": "Worker added to queue.", "data": newQueueRecord})
-			},
-		})
+// BlackoutEntry defines a single day of the blackout API response.
+type BlackoutEntry struct {
+	Date   string `json:"date"`
+	Reason string `json:"reason"`
+}
 
-		// GET /api/dishduty/current-assignee
-		e.Router.AddRoute(echo.Route{
-			Method: http.MethodGet,
-			Path:   "/api/dishduty/current-assignee",
-			Handler: func(c echo.Context) error {
-				if err := ensureDailyAssignmentGo(dao); err != nil {
-					log.Printf("Error during ensureDailyAssignmentGo: %v. Attempting to fetch current assignee anyway.", err)
-				}
-
-				// Corrected filter for fetching today's assignment
-				todayStart := time.Now().UTC().Truncate(24 * time.Hour)
-				todayEnd := todayStart.Add(24*time.Hour - 1*time.Nanosecond) // End of the day
-				todayYMDForLog := todayStart.Format(timeLayoutYMD)           // For logging if not found
-
-				filter := dbx.NewExp(
-					"date >= {:startOfDay} AND date <= {:endOfDay} AND status = 'assigned'",
-					dbx.Params{
-						"startOfDay": todayStart.UTC().Format(timeLayoutFull),
-						"endOfDay":   todayEnd.UTC().Format(timeLayoutFull),
-					},
-				)
-				var assignmentRecord models.Record
-				err := dao.RecordQuery("assignments").
-					AndWhere(filter).
-					Limit(1).
-					One(&assignmentRecord)
+// StatsExtreme names the worker at one end of the stats summary.
+type StatsExtreme struct {
+	WorkerID   string `json:"worker_id"`
+	WorkerName string `json:"worker_name"`
+	Total      int    `json:"total"`
+}
 
-				if err != nil {
-					isNoRowsError := errors.Is(err, sql.ErrNoRows) ||
-						strings.Contains(strings.ToLower(err.Error()), "no record found") || // PocketBase specific
-						strings.Contains(strings.ToLower(err.Error()), "no rows in result set") // Generic SQL
-
-					if isNoRowsError {
-						log.Printf("No current assignment found for today (%s). Returning 404.", todayYMDForLog)
-						// Return 404 or a specific structure indicating N/A
-						return c.JSON(http.StatusNotFound, map[string]string{"message": "No assignee found for today."})
-					}
-					log.Printf("Error fetching current assignment for today (%s): %v", todayYMDForLog, err)
-					return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch current assignment."})
-				}
+// StatsSummary defines the totals of the stats API response, computed from its per-worker rows.
+type StatsSummary struct {
+	TotalAssignments int           `json:"total_assignments"`
+	Done             int           `json:"done"`
+	NotDone          int           `json:"not_done"`
+	CompletionRate   float64       `json:"completion_rate"` // done / total_assignments, from 0 to 1; 0 without assignments
+	AveragePerWorker float64       `json:"average_per_worker"`
+	Busiest          *StatsExtreme `json:"busiest"`    // null without assignments
+	LeastBusy        *StatsExtreme `json:"least_busy"` // null without assignments
+}
 
-				// If err is nil, .One() found a record.
-				// A check for assignmentRecord.Id == "" might be redundant if .One() always errors on no rows.
-				// However, keeping it as a safeguard if some DB driver behaves differently.
-				if assignmentRecord.Id == "" {
-					log.Printf("No current assignment found for today (%s) (record ID empty after query). Returning 404.", todayYMDForLog)
-					// Return 404 or a specific structure indicating N/A
-					return c.JSON(http.StatusNotFound, map[string]string{"message": "No assignee found for today."})
-				}
+// StatsResponse defines the structure for the stats API response.
+type StatsResponse struct {
+	StartDate string        `json:"start_date,omitempty"`
+	EndDate   string        `json:"end_date,omitempty"`
+	Summary   StatsSummary  `json:"summary"`
+	Workers   []WorkerStats `json:"workers"`
+}
 
-				workerID := assignmentRecord.GetString("worker_id")
-				assigneeRecord, errWorker := dao.FindRecordById("workers", workerID) // Renamed worker to assigneeRecord for clarity
-				if errWorker != nil {
-					log.Printf("Error fetching worker details for ID %s: %v", workerID, errWorker)
-					return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch worker details."})
-				}
-				// Calendar route has been moved to the main router setup area (below)
+// LeaderboardEntry defines a single row of the leaderboard API response.
+type LeaderboardEntry struct {
+	Rank       int    `json:"rank"`
+	WorkerID   string `json:"worker_id"`
+	WorkerName string `json:"worker_name"`
+	Done       int    `json:"done"`
+}
 
-				return c.JSON(http.StatusOK, map[string]interface{}{
-					"worker_id":   assigneeRecord.Id,
-					"worker_name": assigneeRecord.GetString("name"),
-					"date":        assignmentRecord.GetTime("date").Format(timeLayoutYMD),
-				})
-			},
-		})
+// LastAssignedRepair defines a single worker whose last_assigned_date was corrected by the repair endpoint.
+type LastAssignedRepair struct {
+	WorkerID   string `json:"worker_id"`
+	WorkerName string `json:"worker_name"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+}
 
-		// GET /api/dishduty/assignments
-		e.Router.AddRoute(echo.Route{
-			Method: http.MethodGet,
-			Path:   "/api/dishduty/assignments",
-			Handler: func(c echo.Context) error {
-				startDateStr := c.QueryParam("start_date")
-				endDateStr := c.QueryParam("end_date")
-				if startDateStr == "" || endDateStr == "" {
-					return apis.NewBadRequestError("start_date and end_date query parameters are required.", nil)
-				}
-				dateRegex := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
-				if !dateRegex.MatchString(startDateStr) || !dateRegex.MatchString(endDateStr) {
-					return apis.NewBadRequestError("Invalid date format. Use YYYY-MM-DD.", nil)
-				}
-
-				startDateTime, _ := time.Parse(timeLayoutYMD, startDateStr)
-				endDateTime, _ := time.Parse(timeLayoutYMD, endDateStr)
-				endDateTime = endDateTime.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
-
-				records, err := dao.FindRecordsByFilter(
-					"assignments",
-					"date >= {:startDate} AND date <= {:endDate}",
-					"date DESC", 0, 0,
-					dbx.Params{
-						"startDate": startDateTime.Format(timeLayoutFull),
-						"endDate":   endDateTime.Format(timeLayoutFull),
-					},
-				)
-				if err != nil {
-					log.Printf("Error fetching assignments: %v", err)
-					return apis.NewApiError(http.StatusInternalServerError, "Failed to fetch assignments.", err)
-				}
-				result := []map[string]interface{}{}
-				for _, record := range records {
-					worker, _ := dao.FindRecordById("workers", record.GetString("worker_id"))
-					workerName := "Unknown"
-					if worker != nil {
-						workerName = worker.GetString("name")
-					}
-					result = append(result, map[string]interface{}{
-						"id": record.Id, "worker_name": workerName,
-						"date": record.GetTime("date").Format(timeLayoutYMD), "status": record.GetString("status"),
-					})
-				}
-				return c.JSON(http.StatusOK, result)
-			},
-		})
+// RebalanceMove is one future assignment handed from the busiest worker to the least busy one.
+type RebalanceMove struct {
+	AssignmentID   string `json:"assignment_id"`
+	Date           string `json:"date"`
+	FromWorkerID   string `json:"from_worker_id"`
+	FromWorkerName string `json:"from_worker_name"`
+	ToWorkerID     string `json:"to_worker_id"`
+	ToWorkerName   string `json:"to_worker_name"`
+}
 
-		// PATCH /api/dishduty/assignments/:id/status
-		e.Router.AddRoute(echo.Route{
-			Method: http.MethodPatch,
-			Path:   "/api/dishduty/assignments/:id/status",
-			Handler: func(c echo.Context) error {
-				assignmentID := c.PathParam("id")
-				requestData := struct {
-					Status        string `json:"status"`
-					AdminPassword string `json:"admin_password"`
-				}{}
-				if err := c.Bind(&requestData); err != nil {
-					return apis.NewBadRequestError("Failed to parse request data.", err)
-				}
-				if !isAdminGo(requestData.AdminPassword) {
-					return apis.NewForbiddenError("Forbidden: Invalid admin password.", nil)
-				}
-				validStatuses := map[string]bool{"assigned": true, "done": true, "not_done": true}
-				if !validStatuses[requestData.Status] {
-					return apis.NewBadRequestError("Invalid status value.", nil)
-				}
-				assignment, err := dao.FindRecordById("assignments", assignmentID)
-				if err != nil {
-					return apis.NewNotFoundError("Assignment not found.", err)
-				}
-				assignment.Set("status", requestData.Status)
-				if err := dao.SaveRecord(assignment); err != nil {
-					log.Printf("Error updating assignment status: %v", err)
-					return apis.NewApiError(http.StatusInternalServerError, "Failed to update status.", err)
-				}
-				if requestData.Status == "not_done" {
-					workerName := "Unknown"
-					worker, _ := dao.FindRecordById("workers", assignment.GetString("worker_id"))
-					if worker != nil {
-						workerName = worker.GetString("name")
-					}
-					logActionGo(dao, "marked_not_done", map[string]interface{}{
-						"assignment_id": assignment.Id,
-						"worker_id":     assignment.GetString("worker_id"),
-						"worker_name":   workerName,
-						"date":          assignment.GetTime("date").Format(timeLayoutYMD),
-					})
-				}
-				return c.JSON(http.StatusOK, map[string]interface{}{"message": "Assignment status updated."})
-			},
-		})
+// OverdueWorker defines a single entry of the overdue API response.
+type OverdueWorker struct {
+	WorkerID         string `json:"worker_id"`
+	WorkerName       string `json:"worker_name"`
+	LastAssignedDate string `json:"last_assigned_date,omitempty"`
+	DaysSince        *int   `json:"days_since"` // null when the worker was never assigned
+}
 
-		// GET /api/dishduty/action-log
-		e.Router.AddRoute(echo.Route{
-			Method: http.MethodGet,
-			Path:   "/api/dishduty/action-log",
-			Handler: func(c echo.Context) error {
-				records, err := dao.FindRecordsByFilter("action_log", "1=1", "timestamp DESC", 50, 0)
-				if err != nil {
-					log.Printf("Error fetching action log: %v", err)
-					return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch action log."})
-				}
-				return c.JSON(http.StatusOK, records)
-			},
-		})
+// AppSettings holds the runtime-editable configuration stored in the singleton settings record.
+type AppSettings struct {
+	OnEmptyQueue               string `json:"on_empty_queue"`
+	FairnessUseWeights         bool   `json:"fairness_use_weights"`
+	SkipWeekends               bool   `json:"skip_weekends"`
+	Paused                     bool   `json:"paused"`
+	AllowDuplicateQueueEntries bool   `json:"allow_duplicate_queue_entries"` // a worker may hold several pending queue items
+	SnoozeUntil                string `json:"snooze_until"`                  // YYYY-MM-DD; no automatic assignments through this day, "" = not snoozed
+}
 
-		// GET /api/dishduty/calendar - MOVED HERE
-		e.Router.AddRoute(echo.Route{
-			Method: http.MethodGet,
-			Path:   "/api/dishduty/calendar",
-			Handler: func(c echo.Context) error {
-				startDateStr := c.QueryParam("start_date")
-				endDateStr := c.QueryParam("end_date")
+// UpdateSettingsRequest defines the structure for the settings PATCH request; omitted fields are left unchanged.
+type UpdateSettingsRequest struct {
+	OnEmptyQueue               *string `json:"on_empty_queue"`
+	FairnessUseWeights         *bool   `json:"fairness_use_weights"`
+	SkipWeekends               *bool   `json:"skip_weekends"`
+	Paused                     *bool   `json:"paused"`
+	AllowDuplicateQueueEntries *bool   `json:"allow_duplicate_queue_entries"`
+	AdminPassword              string  `json:"admin_password"`
+}
 
-				if startDateStr == "" || endDateStr == "" {
-					return c.JSON(http.StatusBadRequest, map[string]string{"error": "start_date and end_date query parameters are required."})
-				}
+// RecurringAssignmentRequest defines the structure for creating or updating a recurring assignment rule.
+// On update, empty/omitted fields are left unchanged.
+type RecurringAssignmentRequest struct {
+	WorkerID      string `json:"worker_id"`
+	Weekday       string `json:"weekday"`
+	Priority      *int   `json:"priority"`
+	AdminPassword string `json:"admin_password"`
+}
 
-				dateRegex := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
-				if !dateRegex.MatchString(startDateStr) || !dateRegex.MatchString(endDateStr) {
-					return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid date format. Use YYYY-MM-DD."})
-				}
+// RecurringAssignmentEntry defines a single rule in the recurring assignments API response.
+type RecurringAssignmentEntry struct {
+	ID         string `json:"id"`
+	WorkerID   string `json:"worker_id"`
+	WorkerName string `json:"worker_name"`
+	Weekday    string `json:"weekday"`
+	Priority   int    `json:"priority"`
+}
 
-				responseData := CalendarResponse{
-					Assignments:       make([]CalendarEntry, 0),
-					QueuedAssignments: make([]CalendarEntry, 0),
-				}
+// ForecastEntry defines a single day of the forecast API response.
+type ForecastEntry struct {
+	Date       string `json:"date"`
+	WorkerID   string `json:"worker_id,omitempty"`
+	WorkerName string `json:"worker_name,omitempty"`
+	Source     string `json:"source,omitempty"`
+	Skipped    string `json:"skipped,omitempty"` // why nobody would be assigned, e.g. "paused" or "weekend"
+}
 
-				// Fetch actual assignments
-				assignmentFilterExp := dbx.NewExp(
-					"date >= {:startDate} AND date <= {:endDate}",
-					dbx.Params{
-						"startDate": startDateStr,
-						"endDate":   endDateStr,
-					},
-				)
-				assignmentRecords := []*models.Record{}
-				errAssignments := dao.RecordQuery("assignments").
-					AndWhere(assignmentFilterExp).
-					OrderBy("date DESC").
-					All(&assignmentRecords)
+// RotationOrderEntry defines a single worker of the rotation-order API response.
+type RotationOrderEntry struct {
+	WorkerID              string   `json:"worker_id"`
+	WorkerName            string   `json:"worker_name"`
+	LastAssignedDate      string   `json:"last_assigned_date"`
+	EffectiveLastAssigned string   `json:"effective_last_assigned"` // after fairness period, window, weight and bias adjustments; "" = never
+	Score                 *float64 `json:"score"`                   // days waited since effective_last_assigned; null = never assigned, which goes first
+	Eligible              bool     `json:"eligible"`
+	Reason                string   `json:"reason,omitempty"` // why an ineligible worker can't be picked
+}
 
-				if errAssignments != nil && !errors.Is(errAssignments, sql.ErrNoRows) {
-					log.Printf("Error fetching calendar assignments: %v", errAssignments)
-					return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch calendar assignments."})
-				}
+// FairnessWorker defines a single worker's share of a fairness simulation.
+type FairnessWorker struct {
+	WorkerID    string `json:"worker_id"`
+	WorkerName  string `json:"worker_name"`
+	Assignments int    `json:"assignments"`
+}
 
-				if errAssignments == nil { // Process if no error or if error is sql.ErrNoRows (records will be empty)
-					for _, record := range assignmentRecords {
-						worker, _ := dao.FindRecordById("workers", record.GetString("worker_id"))
-						workerName := "Unknown"
-						if worker != nil {
-							workerName = worker.GetString("name")
-						}
-						// Determine status for calendar display (past_done, past_not_done, assigned)
-						assignmentDate := record.GetTime("date")
-						today := time.Now().UTC().Truncate(24 * time.Hour)
-						status := record.GetString("status")
-						calendarStatus := status // Default to actual status
-
-						if assignmentDate.Before(today) {
-							if status == "done" {
-								calendarStatus = "past_done"
-							} else if status == "not_done" || status == "assigned" { // Treat past assigned as not_done for calendar
-								calendarStatus = "past_not_done"
-							}
+// FairnessDiagnostics defines the structure for the fairness diagnostics API response.
+type FairnessDiagnostics struct {
+	StartDate string           `json:"start_date"`
+	EndDate   string           `json:"end_date"`
+	Days      int              `json:"days"`
+	Assigned  int              `json:"assigned"`
+	Skipped   int              `json:"skipped"`
+	Mean      float64          `json:"mean"`
+	StdDev    float64          `json:"std_dev"` // population standard deviation of assignments per worker
+	Workers   []FairnessWorker `json:"workers"`
+}
 
-						} else if assignmentDate.Equal(today) {
-							calendarStatus = status // "assigned", "done", "not_done"
-						} else { // Future assignment
-							calendarStatus = "assigned" // Future assignments are just "assigned"
-						}
+// ConfigWarning defines a single problem found by the configuration validation API.
+type ConfigWarning struct {
+	Code      string   `json:"code"`
+	Severity  string   `json:"severity"` // "error" (rotation can't work), "warning" or "info"
+	Message   string   `json:"message"`
+	RecordIDs []string `json:"record_ids,omitempty"` // the offending records, if any (at most configRecordIDsMax)
+}
 
-						responseData.Assignments = append(responseData.Assignments, CalendarEntry{
-							Date:       record.GetTime("date").Format(timeLayoutYMD),
-							WorkerID:   record.GetString("worker_id"),
-							WorkerName: workerName,
-							Status:     calendarStatus,
-						})
-					}
-				}
+// configRecordIDsMax caps the record ids listed with a ConfigWarning; its message still gives the full count.
+const configRecordIDsMax = 50
 
-				// Fetch queued assignments
-				// Queued items are relevant if their start_date is within the requested calendar range OR
-				// if they don't have a specific end_date but are generally "upcoming".
-				// For simplicity, let's fetch queued items whose start_date is before or on the endDateStr of the calendar view.
-				// This might need refinement based on how "duration_days" for queued items should affect their visibility in the calendar.
-				// For now, we'll list them if their start_date is within the view.
-				queuedFilterExp := dbx.NewExp(
-					"start_date <= {:endDate}", // Show if it starts before or on the last day of the calendar view
-					dbx.Params{"endDate": endDateStr},
-				)
-				queuedRecords := []*models.Record{}
-				errQueued := dao.RecordQuery("assignment_queue").
-					AndWhere(queuedFilterExp).
-					OrderBy("order ASC"). // Assuming 'order' field exists and is relevant
-					All(&queuedRecords)
-
-				if errQueued != nil && !errors.Is(errQueued, sql.ErrNoRows) {
-					log.Printf("Error fetching queued assignments: %v", errQueued)
-					// Potentially return error or just log and continue with empty queuedAssignments
-					// For now, let's log and continue, so assignments can still be shown.
-				}
-
-				if errQueued == nil {
-					for _, record := range queuedRecords {
-						worker, _ := dao.FindRecordById("workers", record.GetString("worker_id"))
-						workerName := "Unknown"
-						if worker != nil {
-							workerName = worker.GetString("name")
-						}
-						// For queued items, the "date" is their start_date.
-						// Status is "queued".
-						// Duration could be used to display them over multiple days if the frontend supports it.
-						// Here, we just mark the start_date.
-						startDate := record.GetTime("start_date").Format(timeLayoutYMD)
-						// Optional: consider duration_days if the frontend is to show multi-day queued blocks
-						// duration := record.GetInt("duration_days")
-
-						responseData.QueuedAssignments = append(responseData.QueuedAssignments, CalendarEntry{
-							Date:       startDate,
-							WorkerID:   record.GetString("worker_id"),
-							WorkerName: workerName,
-							Status:     "queued",
-						})
-					}
-				}
-				return c.JSON(http.StatusOK, responseData)
-			},
-		})
+// ConfigValidation defines the structure for the configuration validation API response.
+type ConfigValidation struct {
+	OK       bool            `json:"ok"` // false when any warning has severity "error"
+	Warnings []ConfigWarning `json:"warnings"`
+}
 
-		go func() {
-			time.Sleep(3 * time.Second)
-			log.Println("Attempting initial daily assignment check after startup...")
-			if err := ensureDailyAssignmentGo(dao); err != nil {
-				log.Printf("Error during initial ensureDailyAssignmentGo: %v", err)
-			}
-		}()
+// StreakResponse defines the structure for the streak API response.
+type StreakResponse struct {
+	Current int `json:"current"`
+	Longest int `json:"longest"`
+}
 
-		return nil
-	})
+// WorkerImportRow defines a single worker in the import API request.
+type WorkerImportRow struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Color       string `json:"color"`
+	Email       string `json:"email"`
+}
 
-	if err := app.Start(); err != nil {
-		log.Fatal(err)
-	}
+// WorkerImportResult defines the outcome for a single row of the import API response.
+type WorkerImportResult struct {
+	Row      int    `json:"row"`
+	Name     string `json:"name"`
+	Status   string `json:"status"` // "created", "skipped" or "error"
+	Error    string `json:"error,omitempty"`
+	WorkerID string `json:"worker_id,omitempty"`
 }
 
-// --- Daily Assignment Logic ---
-func ensureDailyAssignmentGo(dao *daos.Dao) error {
-	log.Println("ensureDailyAssignmentGo: Checking for today's assignment...")
-	today := time.Now().UTC()
-	todayYMD := today.Format(timeLayoutYMD)
-	todayStart := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
-	// todayStart is: time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
-	todayEnd := todayStart.Add(24*time.Hour - 1*time.Nanosecond) // End of the day
-
-	// Check for existing assignment for today using a date range
-	existingAssignmentFilter := dbx.NewExp(
-		"date >= {:startOfDay} AND date <= {:endOfDay}",
-		dbx.Params{
-			"startOfDay": todayStart.UTC().Format(timeLayoutFull),
-			"endOfDay":   todayEnd.UTC().Format(timeLayoutFull),
-		},
-	)
-	var existingAssignment models.Record
-	errExisting := dao.RecordQuery("assignments").
-		AndWhere(existingAssignmentFilter).
-		Limit(1). // We only need one to see if any assignment exists for the day
-		One(&existingAssignment)
-	// errExisting could be sql.ErrNoRows if no assignment exists for today.
-
-	if errExisting == nil && existingAssignment.Id != "" { // Assignment found for today
-		log.Printf("ensureDailyAssignmentGo: Assignment for today (%s) already exists (ID: %s). Status: %s", todayYMD, existingAssignment.Id, existingAssignment.GetString("status"))
-		if existingAssignment.GetString("status") == "not_done" {
-			log.Printf("ensureDailyAssignmentGo: Today's assignment (%s) was 'not_done'. Deleting to reassign.", todayYMD)
-			if err := dao.DeleteRecord(&existingAssignment); err != nil {
-				log.Printf("ensureDailyAssignmentGo: Failed to delete 'not_done' assignment %s: %v", existingAssignment.Id, err)
-				return fmt.Errorf("failed to delete 'not_done' assignment: %w", err)
-			}
-		} else {
-			return nil
-		}
-	} else {
-		log.Printf("ensureDailyAssignmentGo: No assignment found for today (%s). Proceeding to assign.", todayYMD)
-	}
+// ImportDocument is the body of the import API: an export document. Only workers, assignments and queue
+// items are restored; the other collections in it are ignored.
+type ImportDocument struct {
+	AdminPassword   string                   `json:"admin_password"`
+	Workers         []map[string]interface{} `json:"workers"`
+	Assignments     []map[string]interface{} `json:"assignments"`
+	AssignmentQueue []map[string]interface{} `json:"assignment_queue"`
+}
 
-	var workerToAssign *models.Record
-	var assignmentSource string
+// ImportResult summarizes what an import changed.
+type ImportResult struct {
+	Mode                string `json:"mode"`
+	Roster              string `json:"roster"` // name of the roster the document was imported into
+	WorkersCreated      int    `json:"workers_created"`
+	WorkersMatched      int    `json:"workers_matched"` // imported workers that already existed locally by name
+	WorkersDeleted      int    `json:"workers_deleted"`
+	AssignmentsImported int    `json:"assignments_imported"`
+	AssignmentsSkipped  int    `json:"assignments_skipped"` // merge: the day already had a local assignment
+	QueueItemsImported  int    `json:"queue_items_imported"`
+	QueueItemsSkipped   int    `json:"queue_items_skipped"` // merge: the same worker was already queued for the same start date
+}
 
-	var dueQueuedAssignment models.Record
-	// todayStart is: time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
-	// For assignment_queue, start_date should be on or before the end of today.
-	// Instruction: types.DateTime{Time: todayStartOfDay.Add(23*time.Hour + 59*time.Minute + 59*time.Second)}
-	endOfTodayForQueueQuery := todayStart.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+// AddToQueueRequest defines the structure for the add to queue API request.
+type AddToQueueRequest struct {
+	WorkerID     string `json:"worker_id"` // Or WorkerName string `json:"worker_name"`
+	DurationDays int    `json:"duration_days"`
+	// PinnedStartDate (YYYY-MM-DD) fixes the item to that date; recomputes flow around it instead of moving it.
+	PinnedStartDate string `json:"pinned_start_date"`
+	// ExternalRef is copied onto the assignment for the first day the item is assigned.
+	ExternalRef string `json:"external_ref"`
+	// Coverage is "full" (default), or "am"/"pm" for a one-day item sharing its day with the other half.
+	Coverage      string `json:"coverage"`
+	AdminPassword string `json:"admin_password"`
+}
 
-	errQueue := dao.RecordQuery("assignment_queue").
-		AndWhere(dbx.NewExp("start_date <= {:effectiveTodayEnd}", dbx.Params{"effectiveTodayEnd": endOfTodayForQueueQuery.UTC().Format(timeLayoutFull)})).
-		OrderBy("order ASC").
-		Limit(1).
-		One(&dueQueuedAssignment)
-
-	if errQueue == nil && dueQueuedAssignment.Id != "" { // Item found and ID is not empty
-		workerID := dueQueuedAssignment.GetString("worker_id")
-		worker, findErr := dao.FindRecordById("workers", workerID)
-		if findErr == nil && worker != nil {
-			workerToAssign = worker
-			assignmentSource = "queue_processed"
-			log.Printf("ensureDailyAssignmentGo: Assigning worker %s (ID: %s) from queue for %s.", worker.GetString("name"), worker.Id, todayYMD)
-			// last_assigned_date in workers is FieldTypeDate.
-			// todayStart is time.Date(...)
-			worker.Set("last_assigned_date", todayStart.Format(timeLayoutYMD))
-			if errSaveWorker := dao.SaveRecord(worker); errSaveWorker != nil {
-				log.Printf("ensureDailyAssignmentGo: Error updating last_assigned_date for worker %s from queue: %v", worker.GetString("name"), errSaveWorker)
-			}
-			if errDeleteQueue := dao.DeleteRecord(&dueQueuedAssignment); errDeleteQueue != nil { // Pass pointer to record for deletion
-				log.Printf("ensureDailyAssignmentGo: Error deleting queue item %s: %v", dueQueuedAssignment.Id, errDeleteQueue)
-			}
-		} else {
-			log.Printf("ensureDailyAssignmentGo: Error finding worker_id %s from queue item %s: %v.", workerID, dueQueuedAssignment.Id, findErr)
-		}
-	} else if errQueue != nil && !(errors.Is(errQueue, sql.ErrNoRows) ||
-		strings.Contains(strings.ToLower(errQueue.Error()), "no record found") ||
-		strings.Contains(strings.ToLower(errQueue.Error()), "no rows in result set")) {
-		// Log error only if it's not a "no rows" type of error (or similar "not found" messages)
-		log.Printf("ensureDailyAssignmentGo: Error fetching from assignment_queue: %v", errQueue)
-	}
-	// If sql.ErrNoRows or similar, workerToAssign remains nil, and logic proceeds to random assignment.
-
-	if workerToAssign == nil {
-		log.Println("ensureDailyAssignmentGo: No worker from queue. Attempting random assignment.")
-		allWorkers, findErr := dao.FindRecordsByFilter("workers", "1=1", "", 0, 0)
-		if findErr != nil || len(allWorkers) == 0 {
-			log.Printf("ensureDailyAssignmentGo: No workers for random assignment: %v", findErr)
-			return fmt.Errorf("no workers available for random assignment: %w", findErr)
-		}
-		var chosenWorker *models.Record
-		var oldestDate time.Time
-		firstUnassigned := true
-
-		for _, w := range allWorkers {
-			ladStr := w.GetString("last_assigned_date")
-			if ladStr == "" {
-				chosenWorker = w
-				break
-			}
-			ladTime, parseErr := time.Parse(timeLayoutFull, ladStr)
-			if parseErr != nil {
-				log.Printf("ensureDailyAssignmentGo: Error parsing last_assigned_date '%s' for worker %s: %v. Skipping.", ladStr, w.GetString("name"), parseErr)
-				continue
-			}
-			if firstUnassigned || ladTime.Before(oldestDate) {
-				chosenWorker = w
-				oldestDate = ladTime
-				firstUnassigned = false
-			}
-		}
-		if chosenWorker == nil && len(allWorkers) > 0 {
-			chosenWorker = allWorkers[0]
-		}
+// --- Helper Functions ---
 
-		if chosenWorker != nil {
-			workerToAssign = chosenWorker
-			assignmentSource = "randomly_assigned"
-			log.Printf("ensureDailyAssignmentGo: Randomly assigning worker %s (ID: %s) for %s.", workerToAssign.GetString("name"), workerToAssign.Id, todayYMD)
-			workerToAssign.Set("last_assigned_date", todayStart.Format(timeLayoutFull))
-			if err := dao.SaveRecord(workerToAssign); err != nil {
-				log.Printf("ensureDailyAssignmentGo: Error updating last_assigned_date for randomly assigned worker %s: %v", workerToAssign.GetString("name"), err)
+func formatDateToYMDGo(t time.Time) string {
+	return t.Format(timeLayoutYMD)
+}
+
+// getAppLocationGo returns the timezone used for calendar-day boundaries (APP_TIMEZONE, default UTC).
+func getAppLocationGo() *time.Location {
+	name := strings.TrimSpace(os.Getenv("APP_TIMEZONE"))
+	if name == "" {
+		return time.UTC
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Warning: invalid APP_TIMEZONE '%s': %v. Falling back to UTC.", name, err)
+		return time.UTC
+	}
+	return location
+}
+
+func getTodayYMDGo() string {
+	return formatDateToYMDGo(time.Now().In(getAppLocationGo()))
+}
+
+// getTodayStartGo returns today's date in the app timezone as UTC midnight, which is how assignment
+// dates are stored. Day-range queries should be built from this rather than from time.Now().UTC().
+func getTodayStartGo() time.Time {
+	todayStart, _ := parseYMDToGoTime(getTodayYMDGo())
+	return todayStart
+}
+
+// ymdToStoredDateGo converts a YYYY-MM-DD date to the full layout date fields are stored and queried in.
+func ymdToStoredDateGo(ymd string) string {
+	t, err := parseYMDToGoTime(ymd)
+	if err != nil {
+		return ymd
+	}
+	return t.Format(timeLayoutFull)
+}
+
+// storedDateColumns maps each table to its calendar date column.
+var storedDateColumns = map[string]string{
+	"assignments":      "date",
+	"assignment_queue": "start_date",
+	"workers":          "last_assigned_date",
+}
+
+// normalizeStoredDatesGo rewrites date columns stored as bare YYYY-MM-DD (e.g. written directly to the
+// database by older versions) to the full layout, so string range comparisons match every row.
+func normalizeStoredDatesGo(dao *daos.Dao) error {
+	for table, column := range storedDateColumns {
+		quoted := dao.DB().QuoteSimpleColumnName(column)
+		result, err := dao.DB().Update(
+			table,
+			dbx.Params{column: dbx.NewExp(quoted + " || ' 00:00:00.000Z'")},
+			dbx.NewExp("length("+quoted+") = 10"),
+		).Execute()
+		if err != nil {
+			return fmt.Errorf("failed to normalize %s.%s: %w", table, column, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			log.Printf("Normalized %d %s.%s value(s) to the full date layout.", n, table, column)
+		}
+	}
+	return nil
+}
+
+func parseYMDToGoTime(ymd string) (time.Time, error) {
+	return time.Parse(timeLayoutYMD, ymd)
+}
+
+// Calendar dates are kept as UTC midnights, which have no DST, so AddDate and day subtraction on them
+// always move by whole calendar days. The app timezone only decides which date "today" is.
+func addDaysToYMDGo(ymdString string, days int) (string, error) {
+	t, err := parseYMDToGoTime(ymdString)
+	if err != nil {
+		return "", err
+	}
+	t = t.AddDate(0, 0, days)
+	return formatDateToYMDGo(t), nil
+}
+
+// daysBetweenGo returns the number of calendar days from from to to. Both are reduced to their date first, so
+// a time of day or a zone offset on either side (e.g. across a DST change) can't round the result off by one.
+func daysBetweenGo(from time.Time, to time.Time) int {
+	fromDate := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDate := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(toDate.Sub(fromDate).Hours() / 24)
+}
+
+// dayRangeExpGo matches rows whose column falls on a calendar day from first to last (inclusive). The bounds
+// are bare YYYY-MM-DD dates and the upper one is exclusive, so they compare correctly as strings against both
+// the full stored layout and bare dates written directly to the database (e.g. "2025-01-05" on the last day,
+// or a range crossing into the next year).
+func dayRangeExpGo(column string, first time.Time, last time.Time) dbx.Expression {
+	return dbx.NewExp(column+" >= {:rangeFirst} AND "+column+" < {:rangeEnd}", dbx.Params{
+		"rangeFirst": first.Format(timeLayoutYMD),
+		"rangeEnd":   last.AddDate(0, 0, 1).Format(timeLayoutYMD),
+	})
+}
+
+// sameDayExpGo matches rows whose column falls on day, either by day range or by the exact value the write
+// paths store for it (the UTC midnight in timeLayoutFull). Existence checks that guard against creating a
+// second record for a day use it, so they still find the record if a stored value ever slips out of the
+// range, e.g. through a different timezone suffix.
+func sameDayExpGo(column string, day time.Time) dbx.Expression {
+	return dbx.Or(dayRangeExpGo(column, day, day), dbx.HashExp{column: day.Format(timeLayoutFull)})
+}
+
+var (
+	adminPassOnce   sync.Once
+	cachedAdminPass string
+	// previousPassLogOnce keeps the ADMIN_PASS_PREVIOUS notice to one line per run instead of one per request.
+	previousPassLogOnce sync.Once
+)
+
+// getAdminPassGo returns the admin password. It is resolved once: ADMIN_PASS_FILE (e.g. a Docker/Kubernetes
+// secrets mount, contents trimmed) takes precedence over the inline ADMIN_PASS.
+func getAdminPassGo() string {
+	adminPassOnce.Do(func() {
+		cachedAdminPass = os.Getenv("ADMIN_PASS")
+		passFile := strings.TrimSpace(os.Getenv("ADMIN_PASS_FILE"))
+		if passFile == "" {
+			return
+		}
+		content, err := os.ReadFile(passFile)
+		if err != nil {
+			log.Printf("Error: could not read ADMIN_PASS_FILE '%s': %v. Falling back to ADMIN_PASS.", passFile, err)
+			return
+		}
+		cachedAdminPass = strings.TrimSpace(string(content))
+		log.Printf("Admin password loaded from ADMIN_PASS_FILE '%s'.", passFile)
+	})
+	return cachedAdminPass
+}
+
+// isAdminGo checks the admin password. During a rotation ADMIN_PASS_PREVIOUS is accepted as well, so
+// clients can be switched over to the new ADMIN_PASS without downtime.
+func isAdminGo(providedPassword string) bool {
+	adminPass := getAdminPassGo()
+	previousPass := os.Getenv("ADMIN_PASS_PREVIOUS")
+	if adminPass == "" && previousPass == "" {
+		log.Println("Warning: ADMIN_PASS environment variable is not set. Admin actions will be blocked.")
+		return false
+	}
+	if providedPassword == "" {
+		return false
+	}
+	if adminPass != "" && subtle.ConstantTimeCompare([]byte(providedPassword), []byte(adminPass)) == 1 {
+		return true
+	}
+	if previousPass != "" && subtle.ConstantTimeCompare([]byte(providedPassword), []byte(previousPass)) == 1 {
+		previousPassLogOnce.Do(func() {
+			log.Println("Debug: admin request used ADMIN_PASS_PREVIOUS; password rotation is still in progress.")
+		})
+		return true
+	}
+	return false
+}
+
+// getBearerTokenGo extracts the token from an "Authorization: Bearer <token>" header, if present.
+func getBearerTokenGo(c echo.Context) string {
+	header := c.Request().Header.Get("Authorization")
+	if len(header) > len("Bearer ") && strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(header[len("Bearer "):])
+	}
+	return ""
+}
+
+// Admin scopes. A full admin can do anything; a read-only admin can only use the admin GET endpoints.
+const (
+	adminScopeFull     = "full"
+	adminScopeReadonly = "readonly"
+)
+
+// matchesTokenEnvGo reports whether providedToken equals the token configured in the env variable name.
+func matchesTokenEnvGo(providedToken string, name string) bool {
+	token := os.Getenv(name)
+	return token != "" && subtle.ConstantTimeCompare([]byte(providedToken), []byte(token)) == 1
+}
+
+// adminTokenScopeGo maps a bearer token to its scope: ADMIN_TOKEN_FULL (or the older ADMIN_TOKEN) grants
+// full access and ADMIN_TOKEN_READONLY read-only access. It returns "" for an unknown token.
+func adminTokenScopeGo(providedToken string) string {
+	if matchesTokenEnvGo(providedToken, "ADMIN_TOKEN_FULL") || matchesTokenEnvGo(providedToken, "ADMIN_TOKEN") {
+		return adminScopeFull
+	}
+	if matchesTokenEnvGo(providedToken, "ADMIN_TOKEN_READONLY") {
+		return adminScopeReadonly
+	}
+	return ""
+}
+
+// adminTokensConfiguredGo reports whether any bearer token is configured.
+func adminTokensConfiguredGo() bool {
+	return os.Getenv("ADMIN_TOKEN") != "" || os.Getenv("ADMIN_TOKEN_FULL") != "" || os.Getenv("ADMIN_TOKEN_READONLY") != ""
+}
+
+// adminPasswordHeader carries the admin password on requests without a JSON body, such as admin GETs.
+const adminPasswordHeader = "X-Admin-Password"
+
+// adminRequestContextKey marks a request as admin-authenticated once requireAdminScopeGo let it through.
+const adminRequestContextKey = "dishdutyAdmin"
+
+// adminRequestScopeGo authorizes an admin request either by the bearer token header or by the
+// admin_password from the request body, and returns the granted scope ("" if none). The header takes
+// precedence when present; the password always grants full access.
+func adminRequestScopeGo(c echo.Context, bodyPassword string) string {
+	if token := getBearerTokenGo(c); token != "" {
+		return adminTokenScopeGo(token)
+	}
+	if isAdminGo(bodyPassword) {
+		return adminScopeFull
+	}
+	return ""
+}
+
+// requireAdminGo checks full admin credentials for a request.
+func requireAdminGo(c echo.Context, bodyPassword string) error {
+	return requireAdminScopeGo(c, bodyPassword, adminScopeFull)
+}
+
+// requireAdminScopeGo checks that a request carries admin credentials with at least the given scope. It
+// returns 503 when neither an admin password nor a token is configured, since no credentials could ever
+// succeed, and 403 for wrong credentials or a read-only token on a full-scope endpoint.
+func requireAdminScopeGo(c echo.Context, bodyPassword string, scope string) error {
+	if getAdminPassGo() == "" && os.Getenv("ADMIN_PASS_PREVIOUS") == "" && !adminTokensConfiguredGo() {
+		log.Println("Warning: neither ADMIN_PASS nor an admin token is set. Admin actions are disabled.")
+		return apis.NewApiError(http.StatusServiceUnavailable, "Admin actions are disabled (server not configured).", nil)
+	}
+	granted := adminRequestScopeGo(c, bodyPassword)
+	if granted == "" {
+		return apis.NewForbiddenError("Forbidden: Invalid admin password.", nil)
+	}
+	if scope == adminScopeFull && granted != adminScopeFull {
+		return apis.NewForbiddenError("Forbidden: This token is read-only.", nil)
+	}
+	c.Set(adminRequestContextKey, true)
+	return nil
+}
+
+// bootstrapAdminGo creates a PocketBase admin from BOOTSTRAP_ADMIN_EMAIL/BOOTSTRAP_ADMIN_PASSWORD on a fresh
+// install. It does nothing when either variable is unset or an admin already exists, so it only ever runs once.
+func bootstrapAdminGo(app core.App) error {
+	email := strings.TrimSpace(os.Getenv("BOOTSTRAP_ADMIN_EMAIL"))
+	password := os.Getenv("BOOTSTRAP_ADMIN_PASSWORD")
+	if email == "" || password == "" {
+		return nil
+	}
+	total, err := app.Dao().TotalAdmins()
+	if err != nil {
+		return fmt.Errorf("failed to count admins: %w", err)
+	}
+	if total > 0 {
+		return nil
+	}
+	form := forms.NewAdminUpsert(app, &models.Admin{})
+	form.Email = email
+	form.Password = password
+	form.PasswordConfirm = password
+	if err := form.Submit(); err != nil {
+		return fmt.Errorf("failed to create admin %s: %w", email, err)
+	}
+	log.Printf("Bootstrap admin %s created.", email)
+	return nil
+}
+
+// getOnEmptyQueueModeGo returns the configured behavior for when the assignment queue runs out.
+func getOnEmptyQueueModeGo() string {
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("ON_EMPTY_QUEUE")))
+	switch mode {
+	case "", onEmptyQueueRandom:
+		return onEmptyQueueRandom
+	case onEmptyQueueStop:
+		return onEmptyQueueStop
+	default:
+		log.Printf("Warning: unknown ON_EMPTY_QUEUE value '%s'. Falling back to '%s'.", mode, onEmptyQueueRandom)
+		return onEmptyQueueRandom
+	}
+}
+
+func countQueueItemsGo(dao *daos.Dao, rosterID string) (int, error) {
+	var total int
+	err := dao.RecordQuery("assignment_queue").Select("count(*)").AndWhere(rosterExpGo(rosterID)).Row(&total)
+	return total, err
+}
+
+// getQueueAnchorYMDGo returns the date the first queue item of a roster should start on:
+// the day after the roster's latest assignment if that is today or later, otherwise today.
+func getQueueAnchorYMDGo(dao *daos.Dao, rosterID string) string {
+	todayYMD := getTodayYMDGo()
+	latestAssignment := &models.Record{}
+	err := dao.RecordQuery("assignments").AndWhere(rosterExpGo(rosterID)).OrderBy("date DESC").Limit(1).One(latestAssignment)
+	if err != nil || latestAssignment.Id == "" {
+		return todayYMD
+	}
+	latestAssignmentYMD := formatDateToYMDGo(latestAssignment.GetDateTime("date").Time())
+	parsedLatestAssignmentDate, _ := parseYMDToGoTime(latestAssignmentYMD)
+	parsedToday, _ := parseYMDToGoTime(todayYMD)
+	if parsedLatestAssignmentDate.After(parsedToday) || parsedLatestAssignmentDate.Equal(parsedToday) {
+		nextYMD, _ := addDaysToYMDGo(latestAssignmentYMD, 1)
+		return nextYMD
+	}
+	return todayYMD
+}
+
+// workersCache is an in-memory copy of the workers roster, which is read on nearly every code path.
+// It's loaded lazily and dropped by model hooks whenever a worker is created, updated or deleted.
+// Callers always get copies, so mutating a returned record never touches the cached one.
+type workersCache struct {
+	mu      sync.RWMutex
+	loaded  bool
+	byID    map[string]*models.Record
+	ordered []*models.Record // insertion (created) order, matching an unsorted table scan
+}
+
+var workersCacheGo = &workersCache{}
+
+func (wc *workersCache) invalidate() {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	wc.loaded = false
+	wc.byID = nil
+	wc.ordered = nil
+}
+
+func (wc *workersCache) ensureLoaded(dao *daos.Dao) error {
+	wc.mu.RLock()
+	loaded := wc.loaded
+	wc.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	if wc.loaded {
+		return nil
+	}
+	records := []*models.Record{}
+	if err := dao.RecordQuery("workers").OrderBy("created ASC", "id ASC").All(&records); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to load workers: %w", err)
+	}
+	wc.byID = make(map[string]*models.Record, len(records))
+	for _, record := range records {
+		wc.byID[record.Id] = record
+	}
+	wc.ordered = records
+	wc.loaded = true
+	return nil
+}
+
+// all returns copies of every worker in insertion order.
+func (wc *workersCache) all(dao *daos.Dao) ([]*models.Record, error) {
+	if err := wc.ensureLoaded(dao); err != nil {
+		return nil, err
+	}
+	wc.mu.RLock()
+	defer wc.mu.RUnlock()
+	result := make([]*models.Record, 0, len(wc.ordered))
+	for _, record := range wc.ordered {
+		result = append(result, record.CleanCopy())
+	}
+	return result, nil
+}
+
+// get returns a copy of the worker with the given id, or nil if it doesn't exist.
+func (wc *workersCache) get(dao *daos.Dao, id string) (*models.Record, error) {
+	if err := wc.ensureLoaded(dao); err != nil {
+		return nil, err
+	}
+	wc.mu.RLock()
+	defer wc.mu.RUnlock()
+	record, ok := wc.byID[id]
+	if !ok {
+		return nil, nil
+	}
+	return record.CleanCopy(), nil
+}
+
+func sortWorkersByNameGo(workers []*models.Record) {
+	sort.SliceStable(workers, func(i, j int) bool {
+		return workers[i].GetString("name") < workers[j].GetString("name")
+	})
+}
+
+// publicWorkerGo exports a worker without its notification targets, which shouldn't be world-readable.
+func publicWorkerGo(worker *models.Record) map[string]any {
+	export := worker.PublicExport()
+	delete(export, "email")
+	delete(export, "telegram_chat_id")
+	return export
+}
+
+// --- Rosters ---
+
+// defaultRosterName names the roster seeded at startup. Data from before rosters existed belongs to it, and
+// endpoints use it when no ?roster= is given.
+const defaultRosterName = "default"
+
+// rosterExpGo limits a query to the records of one roster. An empty rosterID doesn't filter at all. That
+// case is spelled out as 1=1 because dbx renders an empty expression as "()" when it is a query's only condition.
+func rosterExpGo(rosterID string) dbx.Expression {
+	if rosterID == "" {
+		return dbx.NewExp("1=1")
+	}
+	return dbx.HashExp{"roster_id": rosterID}
+}
+
+// findRosterGo looks a roster up by id or by name (case-insensitive). It returns nil if there is no such roster.
+func findRosterGo(dao *daos.Dao, ref string) (*models.Record, error) {
+	roster := &models.Record{}
+	err := dao.RecordQuery("rosters").
+		AndWhere(dbx.Or(dbx.HashExp{"id": ref}, dbx.NewExp("LOWER(name) = LOWER({:name})", dbx.Params{"name": ref}))).
+		Limit(1).
+		One(roster)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return roster, nil
+}
+
+// findActiveRostersGo returns every roster that isn't inactive, oldest first.
+func findActiveRostersGo(dao *daos.Dao) ([]*models.Record, error) {
+	rosters := []*models.Record{}
+	err := dao.RecordQuery("rosters").
+		AndWhere(dbx.HashExp{"inactive": false}).
+		OrderBy("created ASC", "id ASC").
+		All(&rosters)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to load rosters: %w", err)
+	}
+	return rosters, nil
+}
+
+// resolveRosterGo returns the roster named by the ?roster= query parameter, given as an id or a name. It
+// falls back to the default roster when the parameter is missing. An unknown roster is a 404.
+func resolveRosterGo(dao *daos.Dao, c echo.Context) (*models.Record, error) {
+	ref := strings.TrimSpace(c.QueryParam("roster"))
+	if ref == "" {
+		ref = defaultRosterName
+	}
+	roster, err := findRosterGo(dao, ref)
+	if err != nil {
+		log.Printf("Error looking up roster '%s': %v", ref, err)
+		return nil, apis.NewApiError(http.StatusInternalServerError, "Failed to look up roster.", err)
+	}
+	if roster == nil {
+		return nil, apis.NewNotFoundError(fmt.Sprintf("Not Found: Roster '%s' not found.", ref), nil)
+	}
+	return roster, nil
+}
+
+// rosterEntryGo is the API representation of a roster.
+func rosterEntryGo(roster *models.Record) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       roster.Id,
+		"name":     roster.GetString("name"),
+		"inactive": roster.GetBool("inactive"),
+	}
+}
+
+// rosterWorkersGo returns copies of a roster's workers in insertion order. An empty rosterID returns every worker.
+func rosterWorkersGo(dao *daos.Dao, rosterID string) ([]*models.Record, error) {
+	workers, err := workersCacheGo.all(dao)
+	if err != nil || rosterID == "" {
+		return workers, err
+	}
+	result := make([]*models.Record, 0, len(workers))
+	for _, worker := range workers {
+		if worker.GetString("roster_id") == rosterID {
+			result = append(result, worker)
+		}
+	}
+	return result, nil
+}
+
+// ensureDefaultRosterGo seeds the default roster. It then moves everything without a roster into it:
+// workers, assignments and queue items from before rosters existed. The round_robin cursor that used to
+// live in the settings record moves over too.
+func ensureDefaultRosterGo(dao *daos.Dao) error {
+	roster, err := findRosterGo(dao, defaultRosterName)
+	if err != nil {
+		return fmt.Errorf("failed to look up the default roster: %w", err)
+	}
+	if roster == nil {
+		collection, err := dao.FindCollectionByNameOrId("rosters")
+		if err != nil {
+			return fmt.Errorf("failed to find rosters collection: %w", err)
+		}
+		roster = models.NewRecord(collection)
+		roster.Set("name", defaultRosterName)
+		if settingsRecord, err := findSettingsRecordGo(dao); err == nil && settingsRecord != nil {
+			if cursor := settingsRecord.GetString("round_robin_cursor"); cursor != "" && cursor != "null" {
+				roster.Set("round_robin_cursor", cursor)
+			}
+		}
+		if err := dao.SaveRecord(roster); err != nil {
+			return fmt.Errorf("failed to seed the default roster: %w", err)
+		}
+		log.Println("Default roster seeded.")
+	}
+	for _, collection := range []string{"workers", "assignments", "assignment_queue"} {
+		result, err := dao.DB().Update(collection, dbx.Params{"roster_id": roster.Id}, dbx.NewExp("roster_id = '' OR roster_id IS NULL")).Execute()
+		if err != nil {
+			return fmt.Errorf("failed to move %s into the default roster: %w", collection, err)
+		}
+		if moved, _ := result.RowsAffected(); moved > 0 {
+			log.Printf("Moved %d %s record(s) into the default roster.", moved, collection)
+		}
+	}
+	workersCacheGo.invalidate()
+	return nil
+}
+
+// parseWorkerImportGo reads the rows of a worker import: a CSV document with a header row
+// (name, display_name, color, email) or a JSON array, optionally wrapped as {"admin_password", "workers"}.
+// It also returns the admin password found in a wrapped JSON body.
+func parseWorkerImportGo(contentType string, body []byte) ([]WorkerImportRow, string, error) {
+	if strings.HasPrefix(contentType, "text/csv") {
+		records, err := csv.NewReader(strings.NewReader(string(body))).ReadAll()
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid CSV: %w", err)
+		}
+		if len(records) == 0 {
+			return nil, "", nil
+		}
+		columns := map[string]int{}
+		for i, header := range records[0] {
+			columns[strings.ToLower(strings.TrimSpace(header))] = i
+		}
+		if _, ok := columns["name"]; !ok {
+			return nil, "", errors.New("CSV header must include a 'name' column")
+		}
+		cell := func(record []string, column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		rows := make([]WorkerImportRow, 0, len(records)-1)
+		for _, record := range records[1:] {
+			rows = append(rows, WorkerImportRow{
+				Name:        cell(record, "name"),
+				DisplayName: cell(record, "display_name"),
+				Color:       cell(record, "color"),
+				Email:       cell(record, "email"),
+			})
+		}
+		return rows, "", nil
+	}
+
+	trimmed := strings.TrimSpace(string(body))
+	if strings.HasPrefix(trimmed, "[") {
+		rows := []WorkerImportRow{}
+		if err := json.Unmarshal(body, &rows); err != nil {
+			return nil, "", fmt.Errorf("invalid JSON: %w", err)
+		}
+		return rows, "", nil
+	}
+	wrapped := struct {
+		Workers       []WorkerImportRow `json:"workers"`
+		AdminPassword string            `json:"admin_password"`
+	}{}
+	if err := json.Unmarshal(body, &wrapped); err != nil {
+		return nil, "", fmt.Errorf("invalid JSON: %w", err)
+	}
+	return wrapped.Workers, wrapped.AdminPassword, nil
+}
+
+// validateWorkerImportRowGo trims the row in place and returns a description of the first problem, if any.
+func validateWorkerImportRowGo(row *WorkerImportRow) string {
+	row.Name = strings.TrimSpace(row.Name)
+	row.DisplayName = strings.TrimSpace(row.DisplayName)
+	row.Color = strings.TrimSpace(row.Color)
+	row.Email = strings.TrimSpace(row.Email)
+	if row.Name == "" {
+		return "name is required"
+	}
+	if len([]rune(row.Name)) > workerNameMaxLength || len([]rune(row.DisplayName)) > workerNameMaxLength {
+		return fmt.Sprintf("name and display_name must be at most %d characters", workerNameMaxLength)
+	}
+	if row.Color != "" && !regexp.MustCompile(workerColorPattern).MatchString(row.Color) {
+		return "color must be a hex color like #ff8800"
+	}
+	if row.Email != "" {
+		if _, err := mail.ParseAddress(row.Email); err != nil {
+			return "email is invalid"
+		}
+	}
+	return ""
+}
+
+// standardMethods are the methods the 405 and 404 fallbacks answer for.
+var standardMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// dishdutyRoutesGo returns the /api/dishduty/ paths registered on router, in registration order, and the
+// sorted methods each one serves.
+func dishdutyRoutesGo(router *echo.Echo) ([]string, map[string][]string) {
+	allowed := map[string][]string{}
+	paths := []string{}
+	for _, route := range router.Router().Routes() {
+		path := route.Path()
+		if !strings.HasPrefix(path, "/api/dishduty/") {
+			continue
+		}
+		if _, ok := allowed[path]; !ok {
+			paths = append(paths, path)
+		}
+		allowed[path] = append(allowed[path], route.Method())
+	}
+	for _, methods := range allowed {
+		sort.Strings(methods)
+	}
+	return paths, allowed
+}
+
+// registerFallbackRoutesGo answers requests the dishduty routes don't serve with PocketBase's error
+// envelope: 405 with an Allow header for a known path and a wrong method, and 404 listing the available
+// endpoints for an unknown path under /api/dishduty/. Must run after all dishduty routes are added.
+func registerFallbackRoutesGo(router *echo.Echo) {
+	paths, allowed := dishdutyRoutesGo(router)
+
+	sortedPaths := append([]string{}, paths...)
+	sort.Strings(sortedPaths)
+	endpoints := []string{}
+	for _, path := range sortedPaths {
+		for _, method := range allowed[path] {
+			endpoints = append(endpoints, method+" "+path)
+		}
+	}
+	notFound := func(c echo.Context) error {
+		return c.JSON(http.StatusNotFound, struct {
+			*apis.ApiError
+			Endpoints []string `json:"endpoints"`
+		}{apis.NewApiError(http.StatusNotFound, "Unknown dishduty endpoint. See endpoints for the available ones.", nil), endpoints})
+	}
+	for _, method := range standardMethods {
+		if _, err := router.AddRoute(echo.Route{Method: method, Path: "/api/dishduty/*", Handler: notFound}); err != nil {
+			log.Printf("registerFallbackRoutesGo: failed to register %s /api/dishduty/*: %v", method, err)
+		}
+	}
+
+	for _, path := range paths {
+		methods := allowed[path]
+		allowHeader := strings.Join(methods, ", ")
+		handler := func(c echo.Context) error {
+			c.Response().Header().Set(echo.HeaderAllow, allowHeader)
+			return apis.NewApiError(http.StatusMethodNotAllowed, fmt.Sprintf("Method not allowed. Allowed: %s.", allowHeader), nil)
+		}
+		for _, method := range standardMethods {
+			if list.ExistInSlice(method, methods) {
+				continue
+			}
+			if _, err := router.AddRoute(echo.Route{Method: method, Path: path, Handler: handler}); err != nil {
+				log.Printf("registerFallbackRoutesGo: failed to register %s %s: %v", method, path, err)
+			}
+		}
+	}
+}
+
+// getEffectiveConfigGo returns the resolved runtime configuration for diagnostics. Secrets are reported
+// only as whether they are set.
+func getEffectiveConfigGo(dao *daos.Dao) map[string]interface{} {
+	settingsRecord, _ := findSettingsRecordGo(dao)
+	rosters := []map[string]interface{}{}
+	rosterRecords := []*models.Record{}
+	if err := dao.RecordQuery("rosters").OrderBy("created ASC", "id ASC").All(&rosterRecords); err == nil {
+		for _, roster := range rosterRecords {
+			entry := rosterEntryGo(roster)
+			entry["round_robin_cursor"], _ = readRoundRobinCursorGo(dao, roster.Id)
+			rosters = append(rosters, entry)
+		}
+	}
+	settingsSource := "environment"
+	if settingsRecord != nil {
+		settingsSource = "settings"
+	}
+	overlapPolicy := "reject"
+	if strings.ToLower(strings.TrimSpace(os.Getenv("QUEUE_OVERLAP_POLICY"))) == "shift" {
+		overlapPolicy = "shift"
+	}
+	overlimitPolicy := "clamp"
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("QUEUE_OVERLIMIT_POLICY")), "refuse") {
+		overlimitPolicy = "refuse"
+	}
+	sqliteInfo := map[string]interface{}{}
+	if journalMode, busyTimeout, err := readSQLitePragmasGo(dao); err == nil {
+		sqliteInfo["journal_mode"] = journalMode
+		sqliteInfo["busy_timeout_ms"] = busyTimeout
+	}
+	return map[string]interface{}{
+		"timezone":             getAppLocationGo().String(),
+		"today":                getTodayYMDGo(),
+		"settings":             getSettingsGo(dao),
+		"settings_source":      settingsSource,
+		"assignees_per_day":    1,
+		"selection_mode":       getSelectionModeGo(),
+		"fairness_reset":       getFairnessResetGo(),
+		"fairness_window_days": getFairnessWindowDaysGo(),
+		"queue": map[string]interface{}{
+			"max_days":           getQueueMaxDaysGo(),
+			"max_items":          getQueueMaxItemsGo(),
+			"require_acceptance": queueAcceptanceRequiredGo(),
+			"auto_ahead_days":    getAutoQueueAheadDaysGo(),
+			"overlap_policy":     overlapPolicy,
+			"overlimit_policy":   overlimitPolicy,
+		},
+		"rosters":                          rosters,
+		"calendar_max_days":                getMaxCalendarDaysGo(),
+		"overdue_days":                     getOverdueDaysGo(),
+		"reassign_on_not_done_immediately": !strings.EqualFold(strings.TrimSpace(os.Getenv("REASSIGN_ON_NOT_DONE_IMMEDIATELY")), "false"),
+		"forecast_max_days":                forecastMaxDays,
+		"fairness_diagnostics_max_days":    fairnessDiagnosticsMaxDays,
+		"repeat_offender": map[string]interface{}{
+			"max_not_done": getRepeatOffenderMaxNotDoneGo(),
+			"window_days":  getRepeatOffenderWindowDaysGo(),
+		},
+		"notifications": map[string]interface{}{
+			"telegram":               notifierGo.telegramToken() != "",
+			"email":                  notifierGo.emailEnabled(),
+			"remind_day_before":      strings.EqualFold(os.Getenv("REMIND_DAY_BEFORE"), "true"),
+			"remind_day_before_hour": getRemindDayBeforeHourGo(),
+			"admin_channel":          notifierGo.adminConfigured(),
+			"weekly_digest_day":      weeklyDigestDayNameGo(),
+			"weekly_digest_hour":     getWeeklyDigestHourGo(),
+			"announce_batch_seconds": int(getAnnounceBatchWindowGo().Seconds()),
+		},
+		"action_log": map[string]interface{}{
+			"dedupe_seconds": int(getActionLogDedupeWindowGo().Seconds()),
+			"retention_days": getActionLogRetentionDaysGo(),
+		},
+		"audit_request_bodies":          auditRequestBodiesEnabledGo(),
+		"on_assign_command":             strings.TrimSpace(os.Getenv("ON_ASSIGN_COMMAND")) != "",
+		"strict_query_params":           strictQueryParamsGo(),
+		"require_proof_for_done":        proofRequiredForDoneGo(),
+		"streak_ignore_unassigned_days": !strings.EqualFold(strings.TrimSpace(os.Getenv("STREAK_IGNORE_UNASSIGNED_DAYS")), "false"),
+		"sqlite":                        sqliteInfo,
+		"admin_auth": map[string]interface{}{
+			"password_set":          getAdminPassGo() != "",
+			"previous_password_set": os.Getenv("ADMIN_PASS_PREVIOUS") != "",
+			"token_set":             os.Getenv("ADMIN_TOKEN") != "",
+			"full_token_set":        os.Getenv("ADMIN_TOKEN_FULL") != "",
+			"readonly_token_set":    os.Getenv("ADMIN_TOKEN_READONLY") != "",
+			"bootstrap_admin_set":   os.Getenv("BOOTSTRAP_ADMIN_EMAIL") != "" && os.Getenv("BOOTSTRAP_ADMIN_PASSWORD") != "",
+		},
+	}
+}
+
+// Severities of a ConfigWarning.
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+	severityInfo    = "info"
+)
+
+// validateConfigurationGo collects common misconfigurations that keep the rotation or notifications from
+// working, so "why isn't it working" has one place to look.
+func validateConfigurationGo(dao *daos.Dao) (ConfigValidation, error) {
+	result := ConfigValidation{OK: true, Warnings: []ConfigWarning{}}
+	add := func(code string, severity string, message string, recordIDs []string) {
+		result.Warnings = append(result.Warnings, ConfigWarning{Code: code, Severity: severity, Message: message, RecordIDs: recordIDs})
+		if severity == severityError {
+			result.OK = false
+		}
+	}
+
+	workers, err := workersCacheGo.all(dao)
+	if err != nil {
+		return result, fmt.Errorf("failed to fetch workers: %w", err)
+	}
+	activeByRoster := map[string]int{}
+	workersWithChat := 0
+	for _, worker := range workers {
+		if !worker.GetBool("inactive") {
+			activeByRoster[worker.GetString("roster_id")]++
+		}
+		if worker.GetString("telegram_chat_id") != "" {
+			workersWithChat++
+		}
+	}
+	if len(workers) == 0 {
+		add("no_workers", severityError, "There are no workers, so nobody can be assigned.", nil)
+	} else if len(activeByRoster) == 0 {
+		add("all_workers_inactive", severityError, "All workers are inactive, so nobody can be assigned.", nil)
+	} else {
+		rosters, err := findActiveRostersGo(dao)
+		if err != nil {
+			return result, err
+		}
+		for _, roster := range rosters {
+			if activeByRoster[roster.Id] == 0 {
+				add("roster_without_active_workers", severityWarning, fmt.Sprintf("Roster %s has no active workers, so its days stay unassigned.", roster.GetString("name")), []string{roster.Id})
+			}
+		}
+	}
+
+	if getAdminPassGo() == "" {
+		add("admin_password_unset", severityWarning, "ADMIN_PASS is not set. Only admin tokens can perform admin actions.", nil)
+	}
+
+	adminChatID, adminEmail := notifierGo.adminTargets()
+	if token := notifierGo.telegramToken(); token != "" && workersWithChat == 0 && adminChatID == "" {
+		add("telegram_without_chat_id", severityWarning, "TELEGRAM_BOT_TOKEN is set, but neither a worker's telegram_chat_id nor ADMIN_TELEGRAM_CHAT_ID is, so no Telegram message can be sent.", nil)
+	} else if token == "" && (workersWithChat > 0 || adminChatID != "") {
+		add("telegram_chat_id_without_token", severityWarning, "Telegram chat ids are set, but TELEGRAM_BOT_TOKEN isn't, so no Telegram message can be sent.", nil)
+	}
+	if adminEmail != "" && !notifierGo.emailEnabled() {
+		add("admin_email_without_smtp", severityWarning, "ADMIN_EMAIL is set, but SMTP isn't enabled in the PocketBase settings.", nil)
+	}
+
+	orphanedItems := []struct {
+		ID string `db:"id"`
+	}{}
+	err = dao.DB().NewQuery(
+		"SELECT q.id FROM assignment_queue q LEFT JOIN workers w ON w.id = q.worker_id WHERE w.id IS NULL",
+	).All(&orphanedItems)
+	if err != nil {
+		return result, fmt.Errorf("failed to check queue items: %w", err)
+	}
+	if len(orphanedItems) > 0 {
+		ids := make([]string, len(orphanedItems))
+		for i, item := range orphanedItems {
+			ids[i] = item.ID
+		}
+		add("queue_missing_worker", severityError, fmt.Sprintf("%d queue item(s) reference workers that no longer exist and block the queue.", len(ids)), ids)
+	}
+
+	// Dates are checked in SQL and only counted, plus a sample of ids, so the check stays cheap however long
+	// the assignment history gets.
+	for _, table := range []string{"assignments", "assignment_queue", "workers"} {
+		column := storedDateColumns[table]
+		quoted := dao.DB().QuoteSimpleColumnName(column)
+		malformed := dbx.NewExp("date(" + quoted + ") IS NULL")
+		if table == "workers" {
+			// Only never-assigned workers may have no date.
+			malformed = dbx.And(malformed, dbx.NewExp(quoted+" != ''"))
+		}
+		var count int
+		if err := dao.DB().Select("count(*)").From(table).Where(malformed).Row(&count); err != nil {
+			return result, fmt.Errorf("failed to check %s.%s: %w", table, column, err)
+		}
+		if count == 0 {
+			continue
+		}
+		ids := []string{}
+		if err := dao.DB().Select("id").From(table).Where(malformed).OrderBy("id").Limit(configRecordIDsMax).Column(&ids); err != nil {
+			return result, fmt.Errorf("failed to check %s.%s: %w", table, column, err)
+		}
+		add("malformed_dates", severityWarning, fmt.Sprintf("%d record(s) in %s have a missing or malformed %s and are skipped.", count, table, column), ids)
+	}
+
+	return result, nil
+}
+
+// recordDateYMDGo formats a record's date field as YYYY-MM-DD. Malformed or empty dates read as the zero
+// time; those are logged with the record id and reported as not ok so callers can skip the record.
+func recordDateYMDGo(record *models.Record, field string) (string, bool) {
+	date := record.GetDateTime(field)
+	if date.IsZero() {
+		log.Printf("Warning: %s record %s has a missing or malformed %s '%s'. Skipping it.", record.Collection().Name, record.Id, field, record.GetString(field))
+		return "", false
+	}
+	return date.Time().Format(timeLayoutYMD), true
+}
+
+// findOverdueWorkersGo lists a roster's active workers whose last turn was more than days days before today,
+// most overdue first. Workers who were never assigned count as the most overdue.
+func findOverdueWorkersGo(dao *daos.Dao, rosterID string, days int) ([]OverdueWorker, error) {
+	workers, err := rosterWorkersGo(dao, rosterID)
+	if err != nil {
+		return nil, err
+	}
+	today := getTodayStartGo()
+	result := []OverdueWorker{}
+	for _, worker := range workers {
+		if worker.GetBool("inactive") {
+			continue
+		}
+		entry := OverdueWorker{WorkerID: worker.Id, WorkerName: worker.GetString("name")}
+		lastAssigned := worker.GetDateTime("last_assigned_date")
+		if !lastAssigned.IsZero() {
+			daysSince := daysBetweenGo(lastAssigned.Time(), today)
+			if daysSince <= days {
+				continue
+			}
+			entry.LastAssignedDate = lastAssigned.Time().Format(timeLayoutYMD)
+			entry.DaysSince = &daysSince
+		}
+		result = append(result, entry)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].DaysSince == nil || result[j].DaysSince == nil {
+			return result[i].DaysSince == nil && result[j].DaysSince != nil
+		}
+		return *result[i].DaysSince > *result[j].DaysSince
+	})
+	return result, nil
+}
+
+// simulateFairnessGo runs the selection core of a roster for days days starting today, without writing
+// anything, and reports how many days each worker would get. Every active worker is listed, even with zero
+// days, so a worker the rotation never reaches drags the standard deviation up.
+func simulateFairnessGo(dao *daos.Dao, rosterID string, days int) (FairnessDiagnostics, error) {
+	start := getTodayStartGo()
+	result := FairnessDiagnostics{
+		StartDate: start.Format(timeLayoutYMD),
+		EndDate:   start.AddDate(0, 0, days-1).Format(timeLayoutYMD),
+		Days:      days,
+		Workers:   []FairnessWorker{},
+	}
+	state, err := loadScheduleStateGo(dao, rosterID, start, days)
+	if err != nil {
+		return result, err
+	}
+
+	index := map[string]int{}
+	for _, worker := range state.active {
+		index[worker.Id] = len(result.Workers)
+		result.Workers = append(result.Workers, FairnessWorker{WorkerID: worker.Id, WorkerName: worker.GetString("name")})
+	}
+	for i := 0; i < days; i++ {
+		pick := state.pick(start.AddDate(0, 0, i))
+		state.apply(pick)
+		workerID, workerName := "", "Unknown"
+		if pick.Worker != nil {
+			workerID, workerName = pick.Worker.Id, pick.Worker.GetString("name")
+		} else if pick.Existing != nil {
+			workerID = pick.Existing.GetString("worker_id")
+		}
+		if workerID == "" {
+			result.Skipped++
+			continue
+		}
+		result.Assigned++
+		if _, ok := index[workerID]; !ok {
+			// An inactive or deleted worker still holding an existing assignment.
+			index[workerID] = len(result.Workers)
+			result.Workers = append(result.Workers, FairnessWorker{WorkerID: workerID, WorkerName: workerName})
+		}
+		result.Workers[index[workerID]].Assignments++
+	}
+
+	if len(result.Workers) > 0 {
+		result.Mean = float64(result.Assigned) / float64(len(result.Workers))
+		variance := 0.0
+		for _, w := range result.Workers {
+			diff := float64(w.Assignments) - result.Mean
+			variance += diff * diff
+		}
+		result.StdDev = math.Sqrt(variance / float64(len(result.Workers)))
+	}
+	sort.SliceStable(result.Workers, func(i, j int) bool {
+		return result.Workers[i].Assignments > result.Workers[j].Assignments
+	})
+	return result, nil
+}
+
+// rangeQueryParams are the query parameters understood by the date range endpoints.
+var rangeQueryParams = []string{"start_date", "end_date", "roster"}
+
+// strictQueryParamsGo reports whether the range endpoints reject unknown query parameters (STRICT_QUERY_PARAMS, default false).
+func strictQueryParamsGo() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("STRICT_QUERY_PARAMS")), "true")
+}
+
+// unknownQueryParamsGo lists the request's query parameters that aren't in allowed, sorted.
+func unknownQueryParamsGo(c echo.Context, allowed []string) []string {
+	unknown := []string{}
+	for name := range c.QueryParams() {
+		if !list.ExistInSlice(name, allowed) {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// queryParamsHintGo appends the unrecognized query parameters to a validation message, since a typo such
+// as startDate is the usual reason a required parameter looks missing.
+func queryParamsHintGo(c echo.Context, message string, allowed []string) string {
+	unknown := unknownQueryParamsGo(c, allowed)
+	if len(unknown) == 0 {
+		return message
+	}
+	return fmt.Sprintf("%s Unrecognized query parameters: %s. Supported: %s.", message, strings.Join(unknown, ", "), strings.Join(allowed, ", "))
+}
+
+// strictQueryParamsProblemGo returns a validation message when STRICT_QUERY_PARAMS is on and the request has
+// query parameters outside allowed, or "" if it's fine.
+func strictQueryParamsProblemGo(c echo.Context, allowed []string) string {
+	if !strictQueryParamsGo() || len(unknownQueryParamsGo(c, allowed)) == 0 {
+		return ""
+	}
+	return queryParamsHintGo(c, "Unknown query parameters are not allowed.", allowed)
+}
+
+// parseDateRangeQueryGo reads the required start_date and end_date query parameters (YYYY-MM-DD, inclusive)
+// and checks the range against MAX_CALENDAR_DAYS.
+func parseDateRangeQueryGo(c echo.Context) (time.Time, time.Time, error) {
+	start, errStart := parseYMDToGoTime(c.QueryParam("start_date"))
+	end, errEnd := parseYMDToGoTime(c.QueryParam("end_date"))
+	if errStart != nil || errEnd != nil {
+		return start, end, apis.NewBadRequestError("start_date and end_date are required. Use YYYY-MM-DD.", nil)
+	}
+	if end.Before(start) {
+		return start, end, apis.NewBadRequestError("end_date must not be before start_date.", nil)
+	}
+	if maxDays := getMaxCalendarDaysGo(); daysBetweenGo(start, end)+1 > maxDays {
+		return start, end, apis.NewBadRequestError(fmt.Sprintf("Date range must not exceed %d days.", maxDays), nil)
+	}
+	return start, end, nil
+}
+
+// findCoverageGapsGo returns the days from start to end (inclusive) that have no assignment at all in the
+// roster. Weekends are not expected to be covered while skip_weekends is on, and blackout dates never are, so
+// neither is reported as a gap.
+func findCoverageGapsGo(dao *daos.Dao, rosterID string, start time.Time, end time.Time) ([]string, error) {
+	assignments := []*models.Record{}
+	err := dao.RecordQuery("assignments").
+		AndWhere(dayRangeExpGo("date", start, end)).
+		AndWhere(rosterExpGo(rosterID)).
+		All(&assignments)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to fetch assignments: %w", err)
+	}
+	covered := make(map[string]bool, len(assignments))
+	for _, assignment := range assignments {
+		if ymd, ok := recordDateYMDGo(assignment, "date"); ok {
+			covered[ymd] = true
+		}
+	}
+
+	blackouts, err := findBlackoutsGo(dao, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, blackout := range blackouts {
+		covered[blackout.Date] = true
+	}
+
+	skipWeekends := getSettingsGo(dao).SkipWeekends
+	gaps := []string{}
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if skipWeekends && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) {
+			continue
+		}
+		if ymd := day.Format(timeLayoutYMD); !covered[ymd] {
+			gaps = append(gaps, ymd)
+		}
+	}
+	return gaps, nil
+}
+
+// findBlackoutsGo returns the blackout dates from start to end (inclusive), oldest first. Blackout dates are
+// household-wide: no roster gets an assignment on them.
+func findBlackoutsGo(dao *daos.Dao, start time.Time, end time.Time) ([]BlackoutEntry, error) {
+	records := []*models.Record{}
+	err := dao.RecordQuery("blackout_dates").
+		AndWhere(dayRangeExpGo("date", start, end)).
+		OrderBy("date ASC").
+		All(&records)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to fetch blackout dates: %w", err)
+	}
+	entries := make([]BlackoutEntry, 0, len(records))
+	for _, record := range records {
+		if ymd, ok := recordDateYMDGo(record, "date"); ok {
+			entries = append(entries, BlackoutEntry{Date: ymd, Reason: record.GetString("reason")})
+		}
+	}
+	return entries, nil
+}
+
+// backfillAssignmentsGo creates assignments, with source "backfill", for the roster's coverage gaps between
+// start and end. Days are picked in order by the selection core, so each backfilled day counts towards
+// fairness for the next one. Days that already have an assignment are left alone, and the whole backfill
+// is one transaction.
+func backfillAssignmentsGo(dao *daos.Dao, rosterID string, start time.Time, end time.Time) ([]*models.Record, error) {
+	gaps, err := findCoverageGapsGo(dao, rosterID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	created := []*models.Record{}
+	if len(gaps) == 0 {
+		return created, nil
+	}
+	missing := make(map[string]bool, len(gaps))
+	for _, ymd := range gaps {
+		missing[ymd] = true
+	}
+
+	err = dao.RunInTransaction(func(txDao *daos.Dao) error {
+		state, err := loadScheduleStateGo(txDao, rosterID, start, daysBetweenGo(start, end)+1)
+		if err != nil {
+			return fmt.Errorf("failed to load schedule state: %w", err)
+		}
+		assignmentsCollection, err := txDao.FindCollectionByNameOrId("assignments")
+		if err != nil {
+			return err
+		}
+		for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+			ymd := day.Format(timeLayoutYMD)
+			pick := state.pick(day)
+			if !missing[ymd] || pick.Worker == nil || pick.Existing != nil {
+				state.apply(pick)
+				continue
+			}
+			// Guard against a day assigned since the gaps were computed.
+			if existing, err := findAssignmentForDateGo(txDao, rosterID, ymd); err != nil {
+				return err
+			} else if existing != nil {
+				continue
+			}
+
+			worker, err := txDao.FindRecordById("workers", pick.Worker.Id)
+			if err != nil {
+				return fmt.Errorf("failed to load worker %s: %w", pick.Worker.Id, err)
+			}
+			assignment := models.NewRecord(assignmentsCollection)
+			assignment.Set("worker_id", worker.Id)
+			assignment.Set("date", day.Format(timeLayoutFull))
+			assignment.Set("status", "assigned")
+			assignment.Set("weight", 1)
+			assignment.Set("source", "backfill")
+			assignment.Set("roster_id", rosterID)
+			assignment.Set("previous_last_assigned_date", worker.GetString("last_assigned_date"))
+			if err := txDao.SaveRecord(assignment); err != nil {
+				return fmt.Errorf("failed to save assignment for %s: %w", ymd, err)
+			}
+			if lad := worker.GetDateTime("last_assigned_date"); lad.IsZero() || lad.Time().Before(day) {
+				worker.Set("last_assigned_date", day.Format(timeLayoutFull))
+				if err := txDao.SaveRecord(worker); err != nil {
+					return fmt.Errorf("failed to update last_assigned_date for worker %s: %w", worker.Id, err)
+				}
+			}
+			if pick.QueueItem != nil && pick.QueueItemDone {
+				if err := txDao.DeleteRecord(pick.QueueItem); err != nil {
+					return fmt.Errorf("failed to delete queue item %s: %w", pick.QueueItem.Id, err)
+				}
+			}
+			state.apply(pick)
+			created = append(created, assignment)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// errDayAlreadyAssigned means a day someone tried to claim already has an assignment in the roster.
+var errDayAlreadyAssigned = errors.New("day already has an assignment")
+
+// volunteerForDayGo lets a worker claim an unassigned day up to today in their roster, with source "volunteer".
+// A past day is recorded as done, unless REQUIRE_PROOF_FOR_DONE is on, in which case it stays assigned until
+// the worker proves it like any other day. Today is assigned. The worker's last_assigned_date moves forward
+// to the day if it is later, so the rotation counts the turn. Returns errDayAlreadyAssigned if the day is taken.
+func volunteerForDayGo(dao *daos.Dao, worker *models.Record, day time.Time) (*models.Record, error) {
+	ymd := day.Format(timeLayoutYMD)
+	rosterID := worker.GetString("roster_id")
+	status := "assigned"
+	if day.Before(getTodayStartGo()) && !proofRequiredForDoneGo() {
+		status = "done"
+	}
+
+	assignmentsCollection, err := dao.FindCollectionByNameOrId("assignments")
+	if err != nil {
+		return nil, err
+	}
+	assignment := models.NewRecord(assignmentsCollection)
+	err = dao.RunInTransaction(func(txDao *daos.Dao) error {
+		if existing, err := findAssignmentForDateGo(txDao, rosterID, ymd); err != nil {
+			return err
+		} else if existing != nil {
+			return errDayAlreadyAssigned
+		}
+		// Re-read inside the transaction so a concurrent rotation update isn't overwritten.
+		current, err := txDao.FindRecordById("workers", worker.Id)
+		if err != nil {
+			return fmt.Errorf("failed to load worker %s: %w", worker.Id, err)
+		}
+		assignment.Set("worker_id", current.Id)
+		assignment.Set("date", day.Format(timeLayoutFull))
+		assignment.Set("status", status)
+		assignment.Set("weight", 1)
+		assignment.Set("source", "volunteer")
+		assignment.Set("roster_id", rosterID)
+		assignment.Set("previous_last_assigned_date", current.GetString("last_assigned_date"))
+		if err := txDao.SaveRecord(assignment); err != nil {
+			return fmt.Errorf("failed to save assignment for %s: %w", ymd, err)
+		}
+		if lad := current.GetDateTime("last_assigned_date"); lad.IsZero() || lad.Time().Before(day) {
+			current.Set("last_assigned_date", day.Format(timeLayoutFull))
+			if err := txDao.SaveRecord(current); err != nil {
+				return fmt.Errorf("failed to update last_assigned_date for worker %s: %w", current.Id, err)
+			}
+		}
+		logActionGo(txDao, "volunteered", map[string]interface{}{
+			"assignment_id": assignment.Id,
+			"worker_id":     current.Id,
+			"worker_name":   current.GetString("name"),
+			"date":          ymd,
+			"status":        status,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return assignment, nil
+}
+
+// rebalanceFixedSources are assignment sources that were chosen on purpose. Rebalancing counts them towards a
+// worker's load but never moves them.
+var rebalanceFixedSources = []string{"manual", "recurring", "queue"}
+
+// rebalanceFutureAssignmentsGo evens out a roster's "assigned" days from tomorrow through the next days days
+// between its active workers, without changing which dates are covered. It repeatedly hands the latest movable day of
+// the busiest worker to the least busy one until no two workers differ by more than one day. Today and
+// earlier are never touched, and the moves are saved in one transaction.
+func rebalanceFutureAssignmentsGo(dao *daos.Dao, rosterID string, days int) ([]RebalanceMove, error) {
+	start := getTodayStartGo().AddDate(0, 0, 1)
+	end := start.AddDate(0, 0, days-1)
+	workers, err := rosterWorkersGo(dao, rosterID)
+	if err != nil {
+		return nil, err
+	}
+	active := []*models.Record{}
+	for _, worker := range workers {
+		if !worker.GetBool("inactive") {
+			active = append(active, worker)
+		}
+	}
+	moves := []RebalanceMove{}
+	if len(active) < 2 {
+		return moves, nil
+	}
+
+	err = dao.RunInTransaction(func(txDao *daos.Dao) error {
+		assignments := []*models.Record{}
+		err := txDao.RecordQuery("assignments").
+			AndWhere(dayRangeExpGo("date", start, end)).
+			AndWhere(dbx.HashExp{"status": "assigned"}).
+			AndWhere(rosterExpGo(rosterID)).
+			OrderBy("date ASC").
+			All(&assignments)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to fetch assignments: %w", err)
+		}
+		load := make(map[string]int, len(active))
+		movable := make(map[string][]*models.Record, len(active)) // per worker, in date order
+		for _, assignment := range assignments {
+			workerID := assignment.GetString("worker_id")
+			load[workerID]++
+			if !list.ExistInSlice(assignment.GetString("source"), rebalanceFixedSources) {
+				movable[workerID] = append(movable[workerID], assignment)
+			}
+		}
+
+		for {
+			var busiest, idlest *models.Record
+			for _, worker := range active {
+				if len(movable[worker.Id]) > 0 && (busiest == nil || load[worker.Id] > load[busiest.Id]) {
+					busiest = worker
+				}
+				if idlest == nil || load[worker.Id] < load[idlest.Id] {
+					idlest = worker
+				}
+			}
+			if busiest == nil || load[busiest.Id]-load[idlest.Id] <= 1 {
+				return nil
+			}
+			candidates := movable[busiest.Id]
+			assignment := candidates[len(candidates)-1]
+			movable[busiest.Id] = candidates[:len(candidates)-1]
+			assignment.Set("worker_id", idlest.Id)
+			assignment.Set("source", "rebalance")
+			if err := txDao.SaveRecord(assignment); err != nil {
+				return fmt.Errorf("failed to save assignment %s: %w", assignment.Id, err)
+			}
+			load[busiest.Id]--
+			load[idlest.Id]++
+			ymd, _ := recordDateYMDGo(assignment, "date")
+			moves = append(moves, RebalanceMove{
+				AssignmentID:   assignment.Id,
+				Date:           ymd,
+				FromWorkerID:   busiest.Id,
+				FromWorkerName: busiest.GetString("name"),
+				ToWorkerID:     idlest.Id,
+				ToWorkerName:   idlest.GetString("name"),
+			})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moves, nil
+}
+
+// repairLastAssignedDatesGo resets every worker's last_assigned_date to their latest assignment (empty if they
+// have none) and returns the workers that changed. With FAIRNESS_RESET=monthly only assignments in the current
+// period count, so a repair doesn't undo the reset.
+func repairLastAssignedDatesGo(dao *daos.Dao) (int, []LastAssignedRepair, error) {
+	checked := 0
+	repairs := []LastAssignedRepair{}
+	err := dao.RunInTransaction(func(txDao *daos.Dao) error {
+		// Workers are read in batches, and only their latest assignment comes back from SQL, so neither read
+		// grows with the assignment history.
+		for offset := int64(0); ; offset += historyBatchSize {
+			workers := []*models.Record{}
+			if err := txDao.RecordQuery("workers").OrderBy("id ASC").Limit(historyBatchSize).Offset(offset).All(&workers); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("failed to fetch workers: %w", err)
+			}
+			batchRepairs, err := repairLastAssignedBatchGo(txDao, workers)
+			if err != nil {
+				return err
+			}
+			checked += len(workers)
+			repairs = append(repairs, batchRepairs...)
+			if len(workers) < historyBatchSize {
+				return nil
+			}
+		}
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return checked, repairs, nil
+}
+
+// repairLastAssignedBatchGo is repairLastAssignedDatesGo for one batch of workers.
+func repairLastAssignedBatchGo(txDao *daos.Dao, workers []*models.Record) ([]LastAssignedRepair, error) {
+	repairs := []LastAssignedRepair{}
+	if len(workers) == 0 {
+		return repairs, nil
+	}
+	workerIDs := make([]interface{}, len(workers))
+	for i, worker := range workers {
+		workerIDs[i] = worker.Id
+	}
+	query := txDao.DB().
+		Select("worker_id", "MAX(substr(date, 1, 10)) AS latest").
+		From("assignments").
+		Where(dbx.In("worker_id", workerIDs...)).
+		GroupBy("worker_id")
+	if getFairnessResetGo() == fairnessResetMonthly {
+		query.AndWhere(dbx.NewExp("date >= {:periodStart}", dbx.Params{"periodStart": monthStartGo(getTodayStartGo()).Format(timeLayoutYMD)}))
+	}
+	rows := []struct {
+		WorkerID string `db:"worker_id"`
+		Latest   string `db:"latest"`
+	}{}
+	if err := query.All(&rows); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to find latest assignments: %w", err)
+	}
+	latestByWorker := make(map[string]string, len(rows))
+	for _, row := range rows {
+		latestByWorker[row.WorkerID] = row.Latest
+	}
+
+	for _, worker := range workers {
+		stored := ""
+		if lad := worker.GetDateTime("last_assigned_date"); !lad.IsZero() {
+			stored = lad.Time().Format(timeLayoutYMD)
+		}
+		latest := latestByWorker[worker.Id]
+		if stored == latest {
+			continue
+		}
+		worker.Set("last_assigned_date", ymdToStoredDateGo(latest))
+		if err := txDao.SaveRecord(worker); err != nil {
+			return nil, fmt.Errorf("failed to update worker %s: %w", worker.Id, err)
+		}
+		repairs = append(repairs, LastAssignedRepair{WorkerID: worker.Id, WorkerName: worker.GetString("name"), From: stored, To: latest})
+	}
+	return repairs, nil
+}
+
+// Calendar response formats, chosen by the Accept header or the calendar.ics/calendar.csv routes.
+const (
+	calendarFormatJSON = "json"
+	calendarFormatICS  = "ics"
+	calendarFormatCSV  = "csv"
+)
+
+// negotiateCalendarFormatGo picks the calendar format for an Accept header, honouring q-values. A missing
+// header or a wildcard means JSON; "" means nothing acceptable is supported.
+func negotiateCalendarFormatGo(accept string) string {
+	if strings.TrimSpace(accept) == "" {
+		return calendarFormatJSON
+	}
+	formats := map[string]string{
+		"application/json": calendarFormatJSON,
+		"text/calendar":    calendarFormatICS,
+		"text/csv":         calendarFormatCSV,
+		"application/*":    calendarFormatJSON,
+		"*/*":              calendarFormatJSON,
+	}
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		format, ok := formats[strings.ToLower(strings.TrimSpace(params[0]))]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			if name, value, found := strings.Cut(strings.TrimSpace(param), "="); found && name == "q" {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	return best
+}
+
+// escapeICSTextGo escapes a TEXT value for an iCalendar property.
+func escapeICSTextGo(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(value)
+}
+
+// renderCalendarICSGo renders a calendar as an iCalendar document with an all-day event per assignment and
+// per queued item.
+func renderCalendarICSGo(calendar CalendarResponse) []byte {
+	var b strings.Builder
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	writeEvent := func(uid string, entry CalendarEntry, days int, summary string) {
+		start, err := parseYMDToGoTime(entry.Date)
+		if err != nil {
+			return
+		}
+		b.WriteString("BEGIN:VEVENT\r\n")
+		fmt.Fprintf(&b, "UID:%s@dishduty\r\n", uid)
+		fmt.Fprintf(&b, "DTSTAMP:%s\r\n", stamp)
+		fmt.Fprintf(&b, "DTSTART;VALUE=DATE:%s\r\n", start.Format("20060102"))
+		fmt.Fprintf(&b, "DTEND;VALUE=DATE:%s\r\n", start.AddDate(0, 0, days).Format("20060102"))
+		fmt.Fprintf(&b, "SUMMARY:%s\r\n", escapeICSTextGo(summary))
+		fmt.Fprintf(&b, "DESCRIPTION:%s\r\n", escapeICSTextGo("Status: "+entry.Status))
+		b.WriteString("END:VEVENT\r\n")
+	}
+	b.WriteString("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//dishduty//calendar//EN\r\nCALSCALE:GREGORIAN\r\n")
+	for _, entry := range calendar.Assignments {
+		if entry.Status == "blackout" {
+			summary := "No dish duty"
+			if entry.Reason != "" {
+				summary += ": " + entry.Reason
+			}
+			writeEvent("blackout-"+entry.Date, entry, 1, summary)
+			continue
+		}
+		writeEvent("assignment-"+entry.Date, entry, 1, "Dish duty: "+entry.WorkerName)
+	}
+	for _, entry := range calendar.QueuedAssignments {
+		days := entry.DurationDays
+		if days < 1 {
+			days = 1
+		}
+		writeEvent("queued-"+entry.Date+"-"+entry.WorkerID, entry, days, "Dish duty (queued): "+entry.WorkerName)
+	}
+	b.WriteString("END:VCALENDAR\r\n")
+	return []byte(b.String())
+}
+
+// renderCalendarCSVGo renders a calendar as CSV, assignments first and then queued items.
+func renderCalendarCSVGo(calendar CalendarResponse) []byte {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Write([]string{"date", "worker_id", "worker_name", "status", "source", "duration_days", "external_ref"})
+	for _, entries := range [][]CalendarEntry{calendar.Assignments, calendar.QueuedAssignments} {
+		for _, entry := range entries {
+			days := entry.DurationDays
+			if days < 1 {
+				days = 1
+			}
+			w.Write([]string{entry.Date, entry.WorkerID, entry.WorkerName, entry.Status, entry.Source, strconv.Itoa(days), entry.ExternalRef})
+		}
+	}
+	w.Flush()
+	return b.Bytes()
+}
+
+// writeExportGo streams a backup document to w: the export time and one array of records per collection,
+// keyed by collection name. Records are read in batches and flushed as they go, so large histories are
+// never held in memory at once. A non-empty rosterID limits workers, assignments, queue items and recurring
+// rules to that roster, which is what an import takes.
+func writeExportGo(dao *daos.Dao, w io.Writer, includeActionLog bool, rosterID string) error {
+	collections := exportCollections
+	if includeActionLog {
+		collections = append(append([]string{}, exportCollections...), "action_log")
+	}
+	flusher, _ := w.(http.Flusher)
+
+	if _, err := fmt.Fprintf(w, "{\"exported_at\":%q", time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	for _, name := range collections {
+		if _, err := fmt.Fprintf(w, ",%q:[", name); err != nil {
+			return err
+		}
+		var filter dbx.Expression = dbx.NewExp("1=1")
+		if rosterID != "" {
+			switch name {
+			case "workers", "assignments", "assignment_queue":
+				filter = rosterExpGo(rosterID)
+			case "recurring_assignments":
+				filter = dbx.NewExp("worker_id IN (SELECT id FROM workers WHERE roster_id = {:roster})", dbx.Params{"roster": rosterID})
+			}
+		}
+		written := 0
+		for offset := int64(0); ; offset += historyBatchSize {
+			records := []*models.Record{}
+			err := dao.RecordQuery(name).
+				AndWhere(filter).
+				OrderBy("created ASC", "id ASC").
+				Limit(historyBatchSize).
+				Offset(offset).
+				All(&records)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("failed to read %s: %w", name, err)
+			}
+			for _, record := range records {
+				if name == "assignments" {
+					record.Set("acceptance_token", "") // it answers for the worker, so it stays out of exports
+				}
+				data, err := json.Marshal(record)
+				if err != nil {
+					return fmt.Errorf("failed to encode %s record %s: %w", name, record.Id, err)
+				}
+				if written > 0 {
+					data = append([]byte(","), data...)
+				}
+				if _, err := w.Write(data); err != nil {
+					return err
+				}
+				written++
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			if len(records) < historyBatchSize {
+				break
+			}
+		}
+		if _, err := io.WriteString(w, "]"); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "}\n")
+	return err
+}
+
+// importStringGo returns the string value of key in an imported record, or "" if it is missing or not a string.
+func importStringGo(data map[string]interface{}, key string) string {
+	value, _ := data[key].(string)
+	return value
+}
+
+// importDateYMDGo returns the YYYY-MM-DD day of a date value in an imported record, or "" if it isn't a date.
+func importDateYMDGo(data map[string]interface{}, key string) string {
+	date, err := types.ParseDateTime(importStringGo(data, key))
+	if err != nil || date.IsZero() {
+		return ""
+	}
+	return date.Time().Format(timeLayoutYMD)
+}
+
+// validateImportDocumentGo checks an import document before anything is written: every worker needs an id
+// and a unique name, every assignment and queue item must point at one of the document's workers, and all
+// records must come from a single roster.
+func validateImportDocumentGo(doc *ImportDocument) []string {
+	problems := []string{}
+	rosterIDs := map[string]bool{}
+	for _, records := range [][]map[string]interface{}{doc.Workers, doc.Assignments, doc.AssignmentQueue} {
+		for _, record := range records {
+			if rosterID := importStringGo(record, "roster_id"); rosterID != "" {
+				rosterIDs[rosterID] = true
+			}
+		}
+	}
+	if len(rosterIDs) > 1 {
+		problems = append(problems, fmt.Sprintf("The document holds %d rosters. Export one roster at a time with ?roster= and import each on its own.", len(rosterIDs)))
+	}
+	workerIDs := map[string]bool{}
+	names := map[string]bool{}
+	for i, worker := range doc.Workers {
+		id, name := importStringGo(worker, "id"), strings.TrimSpace(importStringGo(worker, "name"))
+		switch {
+		case id == "" || name == "":
+			problems = append(problems, fmt.Sprintf("workers[%d]: id and name are required.", i))
+		case workerIDs[id]:
+			problems = append(problems, fmt.Sprintf("workers[%d]: duplicate id %s.", i, id))
+		case names[strings.ToLower(name)]:
+			problems = append(problems, fmt.Sprintf("workers[%d]: duplicate name %s.", i, name))
+		}
+		workerIDs[id] = true
+		names[strings.ToLower(name)] = true
+	}
+
+	dates := map[string]bool{}
+	for i, assignment := range doc.Assignments {
+		if workerID := importStringGo(assignment, "worker_id"); !workerIDs[workerID] {
+			problems = append(problems, fmt.Sprintf("assignments[%d]: worker_id %q is not one of the imported workers.", i, workerID))
+		}
+		ymd := importDateYMDGo(assignment, "date")
+		key := ymd + "|" + importStringGo(assignment, "coverage") // a shared day has one assignment per half
+		if ymd == "" {
+			problems = append(problems, fmt.Sprintf("assignments[%d]: invalid date.", i))
+		} else if dates[key] {
+			problems = append(problems, fmt.Sprintf("assignments[%d]: duplicate date %s.", i, ymd))
+		}
+		dates[key] = true
+		if status := importStringGo(assignment, "status"); !list.ExistInSlice(status, assignmentStatuses) {
+			problems = append(problems, fmt.Sprintf("assignments[%d]: invalid status %q.", i, status))
+		}
+	}
+
+	for i, item := range doc.AssignmentQueue {
+		if workerID := importStringGo(item, "worker_id"); !workerIDs[workerID] {
+			problems = append(problems, fmt.Sprintf("assignment_queue[%d]: worker_id %q is not one of the imported workers.", i, workerID))
+		}
+		if importDateYMDGo(item, "start_date") == "" {
+			problems = append(problems, fmt.Sprintf("assignment_queue[%d]: invalid start_date.", i))
+		}
+		if days, ok := item["duration_days"].(float64); !ok || days < 1 {
+			problems = append(problems, fmt.Sprintf("assignment_queue[%d]: duration_days must be at least 1.", i))
+		}
+	}
+	return problems
+}
+
+// validateImportTargetGo checks an import document against the roster it goes into: an imported worker that
+// matches a local worker by name has to be in that roster already, since workers aren't moved by an import.
+func validateImportTargetGo(dao *daos.Dao, doc *ImportDocument, rosterID string) ([]string, error) {
+	localWorkers, err := workersCacheGo.all(dao)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workers: %w", err)
+	}
+	localByName := map[string]*models.Record{}
+	for _, worker := range localWorkers {
+		localByName[strings.ToLower(worker.GetString("name"))] = worker
+	}
+	problems := []string{}
+	for i, data := range doc.Workers {
+		name := strings.TrimSpace(importStringGo(data, "name"))
+		if local, ok := localByName[strings.ToLower(name)]; ok && local.GetString("roster_id") != rosterID {
+			problems = append(problems, fmt.Sprintf("workers[%d]: %s already exists in another roster.", i, name))
+		}
+	}
+	return problems, nil
+}
+
+// copyImportFieldsGo sets every schema field of record that data has a value for. Files aren't part of an
+// export, so file fields are left alone. Neither is the roster, which importStateGo sets to the target roster.
+func copyImportFieldsGo(record *models.Record, data map[string]interface{}) {
+	for _, field := range record.Collection().Schema.Fields() {
+		if field.Type == schema.FieldTypeFile || field.Name == "roster_id" {
+			continue
+		}
+		if value, ok := data[field.Name]; ok {
+			record.Set(field.Name, value)
+		}
+	}
+}
+
+// importStateGo restores a validated import document in a single transaction. Workers are matched to local
+// ones by name (case-insensitive) and every worker_id in the imported assignments and queue items is
+// remapped to the local record. Everything goes into the roster rosterID, and replace mode only touches
+// that roster: its assignments and queue items are deleted first, matched workers take the imported values,
+// and its workers missing from the import are deleted along with their recurring rules.
+func importStateGo(dao *daos.Dao, doc *ImportDocument, mode string, rosterID string) (ImportResult, error) {
+	result := ImportResult{Mode: mode}
+	err := dao.RunInTransaction(func(txDao *daos.Dao) error {
+		workersCollection, err := txDao.FindCollectionByNameOrId("workers")
+		if err != nil {
+			return err
+		}
+		assignmentsCollection, err := txDao.FindCollectionByNameOrId("assignments")
+		if err != nil {
+			return err
+		}
+		queueCollection, err := txDao.FindCollectionByNameOrId("assignment_queue")
+		if err != nil {
+			return err
+		}
+
+		if mode == importModeReplace {
+			// The roster's history is deleted a batch at a time; each pass picks up where the last one emptied.
+			for _, collection := range []string{"assignment_queue", "assignments"} {
+				for {
+					records := []*models.Record{}
+					if err := txDao.RecordQuery(collection).AndWhere(rosterExpGo(rosterID)).Limit(historyBatchSize).All(&records); err != nil && !errors.Is(err, sql.ErrNoRows) {
+						return fmt.Errorf("failed to load %s: %w", collection, err)
+					}
+					for _, record := range records {
+						if err := txDao.DeleteRecord(record); err != nil {
+							return fmt.Errorf("failed to delete %s record %s: %w", collection, record.Id, err)
+						}
+					}
+					if len(records) < historyBatchSize {
+						break
+					}
+				}
+			}
+		}
+
+		// Only the local workers the document names are needed to match it up.
+		names := make([]interface{}, 0, len(doc.Workers))
+		for _, data := range doc.Workers {
+			names = append(names, strings.ToLower(strings.TrimSpace(importStringGo(data, "name"))))
+		}
+		localByName := map[string]*models.Record{}
+		if len(names) > 0 {
+			localWorkers := []*models.Record{}
+			if err := txDao.RecordQuery("workers").AndWhere(dbx.In("LOWER([[name]])", names...)).All(&localWorkers); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("failed to load workers: %w", err)
+			}
+			for _, worker := range localWorkers {
+				localByName[strings.ToLower(worker.GetString("name"))] = worker
+			}
+		}
+
+		idMap := map[string]string{} // imported worker id -> local worker id
+		kept := map[string]bool{}
+		for _, data := range doc.Workers {
+			name := strings.TrimSpace(importStringGo(data, "name"))
+			worker, matched := localByName[strings.ToLower(name)]
+			if matched && worker.GetString("roster_id") != rosterID {
+				return fmt.Errorf("worker %s belongs to another roster", name)
+			}
+			if matched && mode == importModeMerge {
+				idMap[importStringGo(data, "id")] = worker.Id
+				kept[worker.Id] = true
+				result.WorkersMatched++
+				continue
+			}
+			if matched {
+				result.WorkersMatched++
+			} else {
+				worker = models.NewRecord(workersCollection)
+				result.WorkersCreated++
+			}
+			copyImportFieldsGo(worker, data)
+			worker.Set("name", name)
+			worker.Set("roster_id", rosterID)
+			if err := txDao.SaveRecord(worker); err != nil {
+				return fmt.Errorf("failed to save worker %s: %w", name, err)
+			}
+			idMap[importStringGo(data, "id")] = worker.Id
+			kept[worker.Id] = true
+		}
+
+		if mode == importModeReplace {
+			rosterWorkers := []*models.Record{}
+			if err := txDao.RecordQuery("workers").AndWhere(dbx.HashExp{"roster_id": rosterID}).All(&rosterWorkers); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("failed to load workers: %w", err)
+			}
+			for _, worker := range rosterWorkers {
+				if kept[worker.Id] {
+					continue
+				}
+				rules := []*models.Record{}
+				if err := txDao.RecordQuery("recurring_assignments").AndWhere(dbx.HashExp{"worker_id": worker.Id}).All(&rules); err != nil && !errors.Is(err, sql.ErrNoRows) {
+					return fmt.Errorf("failed to load recurring rules of worker %s: %w", worker.Id, err)
+				}
+				for _, rule := range rules {
+					if err := txDao.DeleteRecord(rule); err != nil {
+						return fmt.Errorf("failed to delete recurring rule %s: %w", rule.Id, err)
+					}
+				}
+				if err := txDao.DeleteRecord(worker); err != nil {
+					return fmt.Errorf("failed to delete worker %s: %w", worker.Id, err)
+				}
+				result.WorkersDeleted++
+			}
+		}
+
+		for _, data := range doc.Assignments {
+			ymd := importDateYMDGo(data, "date")
+			day, _ := parseYMDToGoTime(ymd)
+			workerID := idMap[importStringGo(data, "worker_id")]
+			// A local assignment for the same day (or the same half of it) wins.
+			taken := txDao.RecordQuery("assignments").Select("count(*)").AndWhere(rosterExpGo(rosterID)).AndWhere(sameDayExpGo("date", day))
+			if half := importStringGo(data, "coverage"); half == coverageAM || half == coveragePM {
+				taken = taken.AndWhere(dbx.In("coverage", half, coverageFull, ""))
+			}
+			var existing int
+			if err := taken.Row(&existing); err != nil {
+				return fmt.Errorf("failed to check for an assignment on %s: %w", ymd, err)
+			}
+			if existing > 0 {
+				result.AssignmentsSkipped++
+				continue
+			}
+			assignment := models.NewRecord(assignmentsCollection)
+			copyImportFieldsGo(assignment, data)
+			assignment.Set("worker_id", workerID)
+			assignment.Set("roster_id", rosterID)
+			if ref := importStringGo(data, "external_ref"); ref != "" {
+				if owner, err := findExternalRefOwnerGo(txDao, ref, ""); err != nil {
+					return err
+				} else if owner != "" {
+					assignment.Set("external_ref", "") // refs are unique and the local one wins
+				}
+			}
+			if err := txDao.SaveRecord(assignment); err != nil {
+				return fmt.Errorf("failed to save assignment for %s: %w", ymd, err)
+			}
+			result.AssignmentsImported++
+		}
+
+		// Imported items keep their relative order and go after anything already queued.
+		items := append([]map[string]interface{}{}, doc.AssignmentQueue...)
+		sort.SliceStable(items, func(i, j int) bool {
+			orderI, _ := items[i]["order"].(float64)
+			orderJ, _ := items[j]["order"].(float64)
+			return orderI < orderJ
+		})
+		var lastOrder int
+		if err := txDao.DB().Select("COALESCE(MAX([[order]]), -1)").From("assignment_queue").Row(&lastOrder); err != nil {
+			return fmt.Errorf("failed to load queue: %w", err)
+		}
+		nextOrder := lastOrder + 1
+		for _, data := range items {
+			// An item for the same worker and start day is already queued, locally or earlier in this import.
+			workerID := idMap[importStringGo(data, "worker_id")]
+			ymd := importDateYMDGo(data, "start_date")
+			start, _ := parseYMDToGoTime(ymd)
+			var existing int
+			if err := txDao.DB().Select("count(*)").From("assignment_queue").
+				Where(dbx.HashExp{"worker_id": workerID}).
+				AndWhere(sameDayExpGo("start_date", start)).
+				Row(&existing); err != nil {
+				return fmt.Errorf("failed to check the queue for %s: %w", ymd, err)
+			}
+			if existing > 0 {
+				result.QueueItemsSkipped++
+				continue
+			}
+			item := models.NewRecord(queueCollection)
+			copyImportFieldsGo(item, data)
+			item.Set("worker_id", workerID)
+			item.Set("roster_id", rosterID)
+			item.Set("order", nextOrder)
+			if ref := importStringGo(data, "external_ref"); ref != "" {
+				if owner, err := findExternalRefOwnerGo(txDao, ref, ""); err != nil {
+					return err
+				} else if owner != "" {
+					item.Set("external_ref", "")
+				}
+			}
+			if err := txDao.SaveRecord(item); err != nil {
+				return fmt.Errorf("failed to save queue item: %w", err)
+			}
+			nextOrder++
+			result.QueueItemsImported++
+		}
+		return nil
+	})
+	return result, err
+}
+
+// buildCalendarGo collects a roster's assignments and queued items between rangeStart and rangeEnd
+// (inclusive) for the calendar views.
+func buildCalendarGo(dao *daos.Dao, rosterID string, rangeStart time.Time, rangeEnd time.Time) (CalendarResponse, error) {
+	responseData := CalendarResponse{
+		Assignments:       make([]CalendarEntry, 0),
+		QueuedAssignments: make([]CalendarEntry, 0),
+	}
+
+	// Fetch actual assignments
+	assignmentFilterExp := dayRangeExpGo("date", rangeStart, rangeEnd)
+	assignmentRecords := []*models.Record{}
+	errAssignments := dao.RecordQuery("assignments").
+		AndWhere(assignmentFilterExp).
+		AndWhere(rosterExpGo(rosterID)).
+		OrderBy("date DESC").
+		All(&assignmentRecords)
+
+	if errAssignments != nil && !errors.Is(errAssignments, sql.ErrNoRows) {
+		return responseData, fmt.Errorf("failed to fetch calendar assignments: %w", errAssignments)
+	}
+
+	if errAssignments == nil { // Process if no error or if error is sql.ErrNoRows (records will be empty)
+		for _, record := range assignmentRecords {
+			dateYMD, ok := recordDateYMDGo(record, "date")
+			if !ok {
+				continue
+			}
+			workerName := getWorkerNameGo(dao, record.GetString("worker_id"))
+			// Determine status for calendar display (past_done, past_not_done, assigned)
+			assignmentDate := record.GetDateTime("date").Time()
+			today := getTodayStartGo()
+			status := record.GetString("status")
+			calendarStatus := status // Default to actual status
+
+			if assignmentDate.Before(today) {
+				if status == "done" {
+					calendarStatus = "past_done"
+				} else if status == "not_done" || status == "assigned" || status == "pending_acceptance" { // Treat past assigned as not_done for calendar
+					calendarStatus = "past_not_done"
+				}
+
+			} else if assignmentDate.Equal(today) {
+				calendarStatus = status // "assigned", "done", "not_done"
+			} else { // Future assignment
+				calendarStatus = "assigned" // Future assignments are just "assigned"
+			}
+
+			responseData.Assignments = append(responseData.Assignments, CalendarEntry{
+				Date:        dateYMD,
+				WorkerID:    record.GetString("worker_id"),
+				WorkerName:  workerName,
+				Status:      calendarStatus,
+				Source:      record.GetString("source"),
+				ExternalRef: record.GetString("external_ref"),
+			})
+		}
+	}
+
+	// Blackout dates nobody is assigned on show up as their own entries.
+	blackouts, err := findBlackoutsGo(dao, rangeStart, rangeEnd)
+	if err != nil {
+		return responseData, err
+	}
+	if len(blackouts) > 0 {
+		assigned := make(map[string]bool, len(responseData.Assignments))
+		for _, entry := range responseData.Assignments {
+			assigned[entry.Date] = true
+		}
+		for _, blackout := range blackouts {
+			if !assigned[blackout.Date] {
+				responseData.Assignments = append(responseData.Assignments, CalendarEntry{Date: blackout.Date, Status: "blackout", Reason: blackout.Reason})
+			}
+		}
+		sort.SliceStable(responseData.Assignments, func(i, j int) bool {
+			return responseData.Assignments[i].Date > responseData.Assignments[j].Date
+		})
+	}
+
+	// Fetch queued assignments
+	// Queued items are relevant if their start_date is within the requested calendar range OR
+	// if they don't have a specific end_date but are generally "upcoming".
+	// For simplicity, let's fetch queued items whose start_date is before or on the endDateStr of the calendar view.
+	// This might need refinement based on how "duration_days" for queued items should affect their visibility in the calendar.
+	// For now, we'll list them if their start_date is within the view.
+	// Items starting more than the longest allowed duration before the view can't reach into it.
+	queuedFilterExp := dbx.NewExp(
+		"start_date <= {:endDate} AND start_date >= {:earliestStart}", // Show if it starts before or on the last day of the calendar view
+		dbx.Params{"endDate": rangeEnd.Add(24*time.Hour - time.Nanosecond).Format(timeLayoutFull), "earliestStart": rangeStart.AddDate(0, 0, -getQueueMaxDaysGo()).Format(timeLayoutFull)},
+	)
+	queuedRecords := []*models.Record{}
+	errQueued := dao.RecordQuery("assignment_queue").
+		AndWhere(queuedFilterExp).
+		AndWhere(rosterExpGo(rosterID)).
+		OrderBy(queueOrderColumns...).
+		All(&queuedRecords)
+
+	if errQueued != nil && !errors.Is(errQueued, sql.ErrNoRows) {
+		log.Printf("Error fetching queued assignments: %v", errQueued)
+		// Potentially return error or just log and continue with empty queuedAssignments
+		// For now, let's log and continue, so assignments can still be shown.
+	}
+
+	if errQueued == nil {
+		for _, record := range queuedRecords {
+			startDate, ok := recordDateYMDGo(record, "start_date")
+			if !ok {
+				continue
+			}
+			workerName := getWorkerNameGo(dao, record.GetString("worker_id"))
+			// For queued items, the "date" is their start_date.
+			// Status is "queued"; duration_days lets clients draw the whole span.
+
+			responseData.QueuedAssignments = append(responseData.QueuedAssignments, CalendarEntry{
+				Date:         startDate,
+				WorkerID:     record.GetString("worker_id"),
+				WorkerName:   workerName,
+				Status:       "queued",
+				DurationDays: record.GetInt("duration_days"),
+				ExternalRef:  record.GetString("external_ref"),
+			})
+		}
+	}
+	return responseData, nil
+}
+
+// currentDuty is one of today's open assignments with its worker.
+type currentDuty struct {
+	Assignment *models.Record
+	Worker     *models.Record
+}
+
+// findCurrentDutiesGo makes sure today is assigned and returns the roster's open assignments for today
+// with their workers: one for a whole day, the am and the pm one for a shared day. The list is empty
+// when nobody is on duty today.
+func findCurrentDutiesGo(dao *daos.Dao, rosterID string) ([]currentDuty, error) {
+	if err := ensureDailyAssignmentGo(dao); err != nil {
+		log.Printf("Error during ensureDailyAssignmentGo: %v. Attempting to fetch current assignee anyway.", err)
+	}
+	return readCurrentDutiesGo(dao, rosterID)
+}
+
+// readCurrentDutiesGo is findCurrentDutiesGo without creating an assignment.
+func readCurrentDutiesGo(dao *daos.Dao, rosterID string) ([]currentDuty, error) {
+	todayStart := getTodayStartGo()
+	todayYMDForLog := todayStart.Format(timeLayoutYMD) // For logging if not found
+
+	filter := dbx.And(dayRangeExpGo("date", todayStart, todayStart), dbx.HashExp{"status": "assigned"}, rosterExpGo(rosterID))
+	assignmentRecords := []*models.Record{}
+	err := dao.RecordQuery("assignments").
+		AndWhere(filter).
+		OrderBy("coverage ASC"). // am before pm
+		All(&assignmentRecords)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error fetching current assignment for today (%s): %v", todayYMDForLog, err)
+		return nil, errors.New("Failed to fetch current assignment.")
+	}
+	if len(assignmentRecords) == 0 {
+		log.Printf("No current assignment found for today (%s).", todayYMDForLog)
+		return nil, nil
+	}
+
+	duties := make([]currentDuty, 0, len(assignmentRecords))
+	for _, assignmentRecord := range assignmentRecords {
+		workerID := assignmentRecord.GetString("worker_id")
+		assigneeRecord, errWorker := workersCacheGo.get(dao, workerID)
+		if errWorker != nil || assigneeRecord == nil {
+			log.Printf("Error fetching worker details for ID %s: %v", workerID, errWorker)
+			return nil, errors.New("Failed to fetch worker details.")
+		}
+		duties = append(duties, currentDuty{Assignment: assignmentRecord, Worker: assigneeRecord})
+	}
+	return duties, nil
+}
+
+// currentDutyNamesGo joins the names of today's workers for the plain-text views, e.g. "ann / bob".
+func currentDutyNamesGo(duties []currentDuty, preferDisplayName bool) string {
+	names := make([]string, 0, len(duties))
+	for _, duty := range duties {
+		name := duty.Worker.GetString("name")
+		if display := duty.Worker.GetString("display_name"); preferDisplayName && display != "" {
+			name = display
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, " / ")
+}
+
+// renderAssigneeBadgeGo draws a small "dish duty | name" SVG badge. The name half uses the worker's color
+// when set, with black or white text depending on which reads better on it.
+func renderAssigneeBadgeGo(name string, color string) string {
+	background := "#4c1"
+	textColor := "#fff"
+	if regexp.MustCompile(workerColorPattern).MatchString(color) {
+		background = color
+		hex := strings.TrimPrefix(color, "#")
+		r, _ := strconv.ParseUint(hex[0:2], 16, 8)
+		g, _ := strconv.ParseUint(hex[2:4], 16, 8)
+		b, _ := strconv.ParseUint(hex[4:6], 16, 8)
+		// Perceived brightness (ITU-R BT.601); light backgrounds get dark text.
+		if (299*r+587*g+114*b)/1000 > 150 {
+			textColor = "#000"
+		}
+	}
+	const label = "dish duty"
+	labelWidth := 10 + 7*len(label)
+	nameWidth := 10 + 7*utf8.RuneCountInString(name)
+	width := labelWidth + nameWidth
+	escaped := html.EscapeString(name)
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`+
+		`<title>%s: %s</title>`+
+		`<rect width="%d" height="20" fill="#555"/>`+
+		`<rect x="%d" width="%d" height="20" fill="%s"/>`+
+		`<g font-family="Verdana,DejaVu Sans,sans-serif" font-size="11" text-anchor="middle">`+
+		`<text x="%d" y="14" fill="#fff">%s</text>`+
+		`<text x="%d" y="14" fill="%s">%s</text>`+
+		`</g></svg>`,
+		width, label, escaped,
+		label, escaped,
+		labelWidth,
+		labelWidth, nameWidth, background,
+		labelWidth/2, label,
+		labelWidth+nameWidth/2, textColor, escaped)
+}
+
+// workerDeleteConflictGo returns a 409 if the worker still has assignments, which would be orphaned by
+// deleting it. Such workers should be deactivated instead.
+func workerDeleteConflictGo(dao *daos.Dao, worker *models.Record) error {
+	var count int
+	err := dao.RecordQuery("assignments").Select("count(*)").AndWhere(dbx.HashExp{"worker_id": worker.Id}).Row(&count)
+	if err != nil {
+		return fmt.Errorf("failed to count assignments of worker %s: %w", worker.Id, err)
+	}
+	if count == 0 {
+		return nil
+	}
+	return apis.NewApiError(http.StatusConflict, fmt.Sprintf("Worker %s has %d assignment(s) and can't be deleted. Deactivate the worker instead.", worker.GetString("name"), count), nil)
+}
+
+// cleanQueueForWorkerGo removes every queue item of a worker that was deactivated, moved to another roster
+// or is being deleted, then closes the gaps by recomputing the remaining start dates.
+func cleanQueueForWorkerGo(dao *daos.Dao, worker *models.Record, reason string) error {
+	items := []*models.Record{}
+	err := dao.RecordQuery("assignment_queue").AndWhere(dbx.HashExp{"worker_id": worker.Id}).All(&items)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to fetch queue items of worker %s: %w", worker.Id, err)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	for _, item := range items {
+		if err := dao.DeleteRecord(item); err != nil {
+			return fmt.Errorf("failed to delete queue item %s: %w", item.Id, err)
+		}
+	}
+	if _, _, err := recomputeQueueStartDatesGo(dao); err != nil {
+		return err
+	}
+	log.Printf("Removed %d queue item(s) of %s worker %s.", len(items), reason, worker.GetString("name"))
+	logActionGo(dao, "queue_cleaned_for_inactive", map[string]interface{}{
+		"worker_id":     worker.Id,
+		"worker_name":   worker.GetString("name"),
+		"items_removed": len(items),
+		"reason":        reason,
+	})
+	return nil
+}
+
+// assignmentDetailsGo renders a single assignment the way the by-date and today endpoints return it.
+func assignmentDetailsGo(dao *daos.Dao, assignment *models.Record) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           assignment.Id,
+		"roster_id":    assignment.GetString("roster_id"),
+		"worker_id":    assignment.GetString("worker_id"),
+		"worker_name":  getWorkerNameGo(dao, assignment.GetString("worker_id")),
+		"date":         assignment.GetDateTime("date").Time().Format(timeLayoutYMD),
+		"status":       assignment.GetString("status"),
+		"weight":       getAssignmentWeightGo(assignment),
+		"source":       assignment.GetString("source"),
+		"proof_url":    getProofURLGo(assignment),
+		"external_ref": assignment.GetString("external_ref"),
+		"done_note":    assignment.GetString("done_note"),
+	}
+}
+
+// findAssignmentByExternalRefGo returns the assignment holding ref, or nil if there is none.
+func findAssignmentByExternalRefGo(dao *daos.Dao, ref string) (*models.Record, error) {
+	assignment := &models.Record{}
+	err := dao.RecordQuery("assignments").
+		AndWhere(dbx.HashExp{"external_ref": ref}).
+		Limit(1).
+		One(assignment)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return assignment, nil
+}
+
+// findExternalRefOwnerGo returns the id of the assignment or queue item already holding ref, other than
+// exceptID, or "" if the ref is free.
+func findExternalRefOwnerGo(dao *daos.Dao, ref string, exceptID string) (string, error) {
+	for _, collection := range []string{"assignments", "assignment_queue"} {
+		owner := &models.Record{}
+		err := dao.RecordQuery(collection).
+			AndWhere(dbx.HashExp{"external_ref": ref}).
+			AndWhere(dbx.Not(dbx.HashExp{"id": exceptID})).
+			Limit(1).
+			One(owner)
+		if err == nil {
+			return owner.Id, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// validateExternalRefGo trims ref and checks its length, returning a problem description if it's invalid.
+func validateExternalRefGo(ref *string) string {
+	*ref = strings.TrimSpace(*ref)
+	if utf8.RuneCountInString(*ref) > externalRefMaxLength {
+		return fmt.Sprintf("external_ref must be at most %d characters.", externalRefMaxLength)
+	}
+	return ""
+}
+
+// broadcastAssignmentGo sends an assignment change to every realtime client subscribed to
+// assignmentRealtimeTopic.
+func broadcastAssignmentGo(app core.App, action string, assignment *models.Record) {
+	data, err := json.Marshal(map[string]interface{}{
+		"action":     action,
+		"assignment": assignmentDetailsGo(app.Dao(), assignment),
+	})
+	if err != nil {
+		log.Printf("Error encoding realtime message for assignment %s: %v", assignment.Id, err)
+		return
+	}
+	message := subscriptions.Message{Name: assignmentRealtimeTopic, Data: data}
+	for _, client := range app.SubscriptionsBroker().Clients() {
+		if client.HasSubscription(assignmentRealtimeTopic) {
+			go client.Send(message)
+		}
+	}
+}
+
+// getWorkerNameGo resolves a worker's name through the cache, returning "Unknown" for missing workers.
+func getWorkerNameGo(dao *daos.Dao, workerID string) string {
+	worker, _ := workersCacheGo.get(dao, workerID)
+	if worker == nil {
+		return "Unknown"
+	}
+	return worker.GetString("name")
+}
+
+// getSelectionModeGo returns how the fallback worker is chosen when no rule or queue item applies.
+func getSelectionModeGo() string {
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("SELECTION_MODE")))
+	switch mode {
+	case "", selectionModeOldest:
+		return selectionModeOldest
+	case selectionModeRoundRobin:
+		return selectionModeRoundRobin
+	default:
+		log.Printf("Warning: unknown SELECTION_MODE value '%s'. Falling back to '%s'.", mode, selectionModeOldest)
+		return selectionModeOldest
+	}
+}
+
+// getFairnessResetGo returns the FAIRNESS_RESET period.
+func getFairnessResetGo() string {
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("FAIRNESS_RESET")))
+	switch mode {
+	case "", fairnessResetNever:
+		return fairnessResetNever
+	case fairnessResetMonthly:
+		return fairnessResetMonthly
+	default:
+		log.Printf("Warning: unknown FAIRNESS_RESET value '%s'. Falling back to '%s'.", mode, fairnessResetNever)
+		return fairnessResetNever
+	}
+}
+
+// getFairnessWindowDaysGo returns how many days back the selector looks at a worker's last turn
+// (FAIRNESS_WINDOW_DAYS, default 0 = no limit).
+func getFairnessWindowDaysGo() int {
+	value := strings.TrimSpace(os.Getenv("FAIRNESS_WINDOW_DAYS"))
+	if value == "" {
+		return 0
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		log.Printf("Warning: invalid FAIRNESS_WINDOW_DAYS '%s'. Falling back to 0 (no window).", value)
+		return 0
+	}
+	return days
+}
+
+// proofRequiredForDoneGo reports whether an assignment can only be marked done with a proof photo or a note
+// (REQUIRE_PROOF_FOR_DONE, default false).
+func proofRequiredForDoneGo() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("REQUIRE_PROOF_FOR_DONE")), "true")
+}
+
+// doneProofProblemGo returns why assignment can't be marked done under REQUIRE_PROOF_FOR_DONE, or "" if it
+// can: it needs a proof photo (already attached or being uploaded) or a note.
+func doneProofProblemGo(assignment *models.Record, uploading bool, note string) string {
+	if !proofRequiredForDoneGo() || uploading || assignment.GetString("proof") != "" || note != "" {
+		return ""
+	}
+	return "A proof photo or a note is required to mark an assignment done."
+}
+
+// queueAcceptanceRequiredGo reports whether queued days wait for the worker to accept them
+// (REQUIRE_QUEUE_ACCEPTANCE, default false).
+func queueAcceptanceRequiredGo() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("REQUIRE_QUEUE_ACCEPTANCE")), "true")
+}
+
+// acceptanceTokenLength is the length of the random token sent to the worker of a pending_acceptance assignment.
+const acceptanceTokenLength = 32
+
+// acceptanceTokenMatchesGo reports whether token answers assignment, i.e. is its worker's acceptance token.
+func acceptanceTokenMatchesGo(assignment *models.Record, token string) bool {
+	if assignment == nil || assignment.GetString("status") != "pending_acceptance" {
+		return false
+	}
+	stored := assignment.GetString("acceptance_token")
+	return stored != "" && subtle.ConstantTimeCompare([]byte(token), []byte(stored)) == 1
+}
+
+// monthStartGo returns the first day of day's month as UTC midnight.
+func monthStartGo(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// getOverdueDaysGo returns after how many days without a turn a worker counts as overdue (OVERDUE_DAYS, default 7).
+func getOverdueDaysGo() int {
+	value := strings.TrimSpace(os.Getenv("OVERDUE_DAYS"))
+	if value == "" {
+		return 7
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		log.Printf("Warning: invalid OVERDUE_DAYS '%s'. Falling back to 7.", value)
+		return 7
+	}
+	return days
+}
+
+// getRepeatOffenderMaxNotDoneGo returns how many not_done markings a worker may collect within the window
+// before being flagged (REPEAT_OFFENDER_MAX_NOT_DONE, default 0 = never flag).
+func getRepeatOffenderMaxNotDoneGo() int {
+	value := strings.TrimSpace(os.Getenv("REPEAT_OFFENDER_MAX_NOT_DONE"))
+	if value == "" {
+		return 0
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		log.Printf("Warning: invalid REPEAT_OFFENDER_MAX_NOT_DONE '%s'. Falling back to 0 (disabled).", value)
+		return 0
+	}
+	return limit
+}
+
+// getRepeatOffenderWindowDaysGo returns how far back not_done markings count (REPEAT_OFFENDER_WINDOW_DAYS, default 30).
+func getRepeatOffenderWindowDaysGo() int {
+	value := strings.TrimSpace(os.Getenv("REPEAT_OFFENDER_WINDOW_DAYS"))
+	if value == "" {
+		return 30
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 {
+		log.Printf("Warning: invalid REPEAT_OFFENDER_WINDOW_DAYS '%s'. Falling back to 30.", value)
+		return 30
+	}
+	return days
+}
+
+// initialAssignCollections are the collections the first assignment check needs, and initialAssignTimeout
+// is how long to wait for them after startup.
+var initialAssignCollections = []string{"rosters", "workers", "assignments", "assignment_queue", "recurring_assignments", "settings"}
+
+const initialAssignTimeout = 30 * time.Second
+
+// getInitialAssignDelayGo returns the extra wait before the startup assignment check (INITIAL_ASSIGN_DELAY
+// seconds, default 0); the check itself already waits until the collections exist.
+func getInitialAssignDelayGo() time.Duration {
+	value := strings.TrimSpace(os.Getenv("INITIAL_ASSIGN_DELAY"))
+	if value == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		log.Printf("Warning: invalid INITIAL_ASSIGN_DELAY '%s'. Using no delay.", value)
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// waitForCollectionsGo polls until every named collection exists, giving up after timeout.
+func waitForCollectionsGo(dao *daos.Dao, names []string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		missing := []string{}
+		for _, name := range names {
+			if _, err := dao.FindCollectionByNameOrId(name); err != nil {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("collections %v still missing after %s", missing, timeout)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// getMaxCalendarDaysGo returns the widest range /calendar accepts, MAX_CALENDAR_DAYS (default 366).
+func getMaxCalendarDaysGo() int {
+	value := strings.TrimSpace(os.Getenv("MAX_CALENDAR_DAYS"))
+	if value == "" {
+		return 366
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 {
+		log.Printf("Warning: invalid MAX_CALENDAR_DAYS '%s'. Falling back to 366.", value)
+		return 366
+	}
+	return days
+}
+
+// getQueueMaxDaysGo returns the maximum allowed queue item duration (QUEUE_MAX_DAYS, default 7).
+func getQueueMaxDaysGo() int {
+	value := strings.TrimSpace(os.Getenv("QUEUE_MAX_DAYS"))
+	if value == "" {
+		return 7
+	}
+	maxDays, err := strconv.Atoi(value)
+	if err != nil || maxDays < 1 {
+		log.Printf("Warning: invalid QUEUE_MAX_DAYS '%s'. Falling back to 7.", value)
+		return 7
+	}
+	return maxDays
+}
+
+// getQueueMaxItemsGo returns how many items the queue may hold (QUEUE_MAX_ITEMS, default 0 = unlimited).
+func getQueueMaxItemsGo() int {
+	value := strings.TrimSpace(os.Getenv("QUEUE_MAX_ITEMS"))
+	if value == "" {
+		return 0
+	}
+	maxItems, err := strconv.Atoi(value)
+	if err != nil || maxItems < 0 {
+		log.Printf("Warning: invalid QUEUE_MAX_ITEMS '%s'. Falling back to 0 (unlimited).", value)
+		return 0
+	}
+	return maxItems
+}
+
+// getAutoQueueAheadDaysGo returns how many days each roster's queue is kept filled with auto items
+// (AUTO_QUEUE_AHEAD_DAYS, default 0 = no top-up).
+func getAutoQueueAheadDaysGo() int {
+	value := strings.TrimSpace(os.Getenv("AUTO_QUEUE_AHEAD_DAYS"))
+	if value == "" {
+		return 0
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		log.Printf("Warning: invalid AUTO_QUEUE_AHEAD_DAYS '%s'. Falling back to 0 (no top-up).", value)
+		return 0
+	}
+	return days
+}
+
+// topUpQueueGo keeps AUTO_QUEUE_AHEAD_DAYS days of a roster queued. When fewer are, it appends one-day auto
+// items for the workers the fairness rotation would pick next, continuing from where the current queue
+// leaves off. It returns how many items were added.
+func topUpQueueGo(dao *daos.Dao, rosterID string) (int, error) {
+	aheadDays := getAutoQueueAheadDaysGo()
+	if aheadDays == 0 {
+		return 0, nil
+	}
+	items := []*models.Record{}
+	err := dao.RecordQuery("assignment_queue").AndWhere(rosterExpGo(rosterID)).OrderBy(queueOrderColumns...).All(&items)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to fetch queue items: %w", err)
+	}
+	queuedDays := 0
+	for _, item := range items {
+		queuedDays += item.GetInt("duration_days")
+	}
+	needed := aheadDays - queuedDays
+	if maxItems := getQueueMaxItemsGo(); maxItems > 0 && len(items)+needed > maxItems {
+		needed = maxItems - len(items)
+	}
+	if needed <= 0 {
+		return 0, nil
+	}
+
+	today := getTodayStartGo()
+	// Room for existing assignments, recurring days and weekends while the current queue plays out.
+	horizon := queuedDays + needed + 31
+	state, err := loadScheduleStateGo(dao, rosterID, today, horizon)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load schedule state: %w", err)
+	}
+	if len(state.active) == 0 {
+		return 0, nil
+	}
+	day := today
+	for last := today.AddDate(0, 0, horizon); len(state.queue) > 0 && day.Before(last); day = day.AddDate(0, 0, 1) {
+		state.apply(state.pick(day))
+	}
+	picked := make([]*models.Record, 0, needed)
+	for ; len(picked) < needed; day = day.AddDate(0, 0, 1) {
+		worker := state.active[0]
+		if ranked := state.rankCandidates(day, state.active); len(ranked) > 0 {
+			worker = ranked[0].Worker
+		}
+		state.apply(dayPick{Date: day, Worker: worker, Source: "queue"})
+		picked = append(picked, worker)
+	}
+
+	queueCollection, err := dao.FindCollectionByNameOrId("assignment_queue")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find assignment_queue collection: %w", err)
+	}
+	names := make([]string, 0, len(picked))
+	err = dao.RunInTransaction(func(txDao *daos.Dao) error {
+		// Another run may have topped the queue up while this one was picking.
+		var count int
+		if err := txDao.RecordQuery("assignment_queue").Select("count(*)").AndWhere(rosterExpGo(rosterID)).Row(&count); err != nil {
+			return fmt.Errorf("failed to re-count queue items: %w", err)
+		}
+		if count != len(items) {
+			return errConcurrentUpdate
+		}
+		order := 1
+		lastQueueItem := &models.Record{}
+		if err := txDao.RecordQuery("assignment_queue").OrderBy("order DESC").Limit(1).One(lastQueueItem); err == nil {
+			order = lastQueueItem.GetInt("order") + 1
+		}
+		for i, worker := range picked {
+			item := models.NewRecord(queueCollection)
+			item.Set("worker_id", worker.Id)
+			item.Set("start_date", today.Format(timeLayoutFull)) // placed by the recompute below
+			item.Set("duration_days", 1)
+			item.Set("order", order+i)
+			item.Set("roster_id", rosterID)
+			item.Set("source", queueSourceAuto)
+			if err := txDao.SaveRecord(item); err != nil {
+				return fmt.Errorf("failed to save auto queue item: %w", err)
+			}
+			names = append(names, worker.GetString("name"))
+		}
+		return nil
+	})
+	if errors.Is(err, errConcurrentUpdate) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if _, _, err := recomputeQueueStartDatesGo(dao); err != nil {
+		return len(picked), err
+	}
+	logActionGo(dao, "queue_topped_up", map[string]interface{}{"roster_id": rosterID, "count": len(picked), "worker_names": names})
+	return len(picked), nil
+}
+
+// replanAutoQueueGo drops a roster's auto items and tops the queue up again, e.g. after a worker joined,
+// left or changed.
+func replanAutoQueueGo(dao *daos.Dao, rosterID string) error {
+	if getAutoQueueAheadDaysGo() == 0 {
+		return nil
+	}
+	autoItems := []*models.Record{}
+	err := dao.RecordQuery("assignment_queue").
+		AndWhere(rosterExpGo(rosterID)).
+		AndWhere(dbx.HashExp{"source": queueSourceAuto}).
+		All(&autoItems)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to fetch auto queue items: %w", err)
+	}
+	for _, item := range autoItems {
+		if err := dao.DeleteRecord(item); err != nil {
+			return fmt.Errorf("failed to delete auto queue item %s: %w", item.Id, err)
+		}
+	}
+	_, err = topUpQueueGo(dao, rosterID)
+	return err
+}
+
+// enforceQueueMaxDaysGo handles queue items whose duration_days exceeds the configured QUEUE_MAX_DAYS
+// (e.g. after the limit was lowered). With QUEUE_OVERLIMIT_POLICY=clamp (default) they are shortened to
+// the limit; with "refuse" an error is returned so the server doesn't start with an inconsistent queue.
+func enforceQueueMaxDaysGo(dao *daos.Dao) error {
+	maxDays := getQueueMaxDaysGo()
+	overLimit := []*models.Record{}
+	err := dao.RecordQuery("assignment_queue").
+		AndWhere(dbx.NewExp("duration_days > {:maxDays}", dbx.Params{"maxDays": maxDays})).
+		All(&overLimit)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check queue durations: %w", err)
+	}
+	if len(overLimit) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(overLimit))
+	for _, record := range overLimit {
+		ids = append(ids, record.Id)
+	}
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("QUEUE_OVERLIMIT_POLICY")), "refuse") {
+		return fmt.Errorf("%d queue item(s) exceed QUEUE_MAX_DAYS=%d: %v (set QUEUE_OVERLIMIT_POLICY=clamp to shorten them automatically)", len(ids), maxDays, ids)
+	}
+
+	for _, record := range overLimit {
+		log.Printf("Clamping queue item %s duration_days from %d to %d.", record.Id, record.GetInt("duration_days"), maxDays)
+		record.Set("duration_days", maxDays)
+		if err := dao.SaveRecord(record); err != nil {
+			return fmt.Errorf("failed to clamp queue item %s: %w", record.Id, err)
+		}
+	}
+	log.Println("Queue items were clamped; use POST /api/dishduty/queue/recompute to close any resulting gaps.")
+	logActionGo(dao, "queue_clamped", map[string]interface{}{"queue_item_ids": ids, "max_days": maxDays})
+	return nil
+}
+
+// repairQueueOrderGo gives queue items that share an `order` value distinct ones, keeping the order they
+// are read in (see queueOrderColumns): each item whose order isn't above its predecessor's is bumped to
+// one past it. Returns the number of renumbered items.
+func repairQueueOrderGo(dao *daos.Dao) (int, error) {
+	items := []*models.Record{}
+	if err := dao.RecordQuery("assignment_queue").OrderBy(queueOrderColumns...).All(&items); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to fetch queue items: %w", err)
+	}
+	renumbered := []string{}
+	for i := 1; i < len(items); i++ {
+		previous := items[i-1].GetInt("order")
+		if items[i].GetInt("order") > previous {
+			continue
+		}
+		items[i].Set("order", previous+1)
+		if err := dao.SaveRecord(items[i]); err != nil {
+			return 0, fmt.Errorf("failed to renumber queue item %s: %w", items[i].Id, err)
+		}
+		renumbered = append(renumbered, items[i].Id)
+	}
+	if len(renumbered) > 0 {
+		log.Printf("Renumbered %d queue item(s) with duplicate order values: %v", len(renumbered), renumbered)
+		logActionGo(dao, "queue_reordered", map[string]interface{}{"queue_item_ids": renumbered})
+	}
+	return len(renumbered), nil
+}
+
+// recomputeQueueStartDatesGo rewrites every unpinned queue item's start_date so each roster's items are
+// contiguous in `order`, with the first anchored at getQueueAnchorYMDGo. An am and a pm item next to each other
+// share a day. Pinned items keep their dates and
+// unpinned items are moved past any pinned span of their roster they would overlap. It is idempotent: items already on their
+// computed date are left untouched. Returns the number of changed items and the total number of items.
+func recomputeQueueStartDatesGo(dao *daos.Dao) (int, int, error) {
+	changed := 0
+	total := 0
+	err := dao.RunInTransaction(func(txDao *daos.Dao) error {
+		if _, err := repairQueueOrderGo(txDao); err != nil {
+			return err
+		}
+		queueRecords := []*models.Record{}
+		if err := txDao.RecordQuery("assignment_queue").OrderBy(queueOrderColumns...).All(&queueRecords); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to fetch queue items: %w", err)
+		}
+		total = len(queueRecords)
+
+		rosterIDs := []string{}
+		byRoster := map[string][]*models.Record{}
+		for _, record := range queueRecords {
+			rosterID := record.GetString("roster_id")
+			if _, seen := byRoster[rosterID]; !seen {
+				rosterIDs = append(rosterIDs, rosterID)
+			}
+			byRoster[rosterID] = append(byRoster[rosterID], record)
+		}
+
+		for _, rosterID := range rosterIDs {
+			pinned := []*models.Record{}
+			for _, record := range byRoster[rosterID] {
+				if record.GetBool("pinned") {
+					pinned = append(pinned, record)
+				}
+			}
+
+			nextStart, err := parseYMDToGoTime(getQueueAnchorYMDGo(txDao, rosterID))
+			if err != nil {
+				return fmt.Errorf("failed to parse queue anchor date: %w", err)
+			}
+			openHalf := "" // half of the day before nextStart still free for the next item
+			for _, record := range byRoster[rosterID] {
+				if record.GetBool("pinned") {
+					continue
+				}
+				var start time.Time
+				if half := halfDayGo(record); openHalf != "" && half == openHalf {
+					// Two half-day items in a row share a day.
+					start = nextStart.AddDate(0, 0, -1)
+					openHalf = ""
+				} else {
+					start = flowAroundPinnedGo(pinned, nextStart, record.GetInt("duration_days"))
+					nextStart = start.AddDate(0, 0, record.GetInt("duration_days"))
+					openHalf = ""
+					if half != "" {
+						openHalf = otherHalfGo(half)
+					}
+				}
+				if formatDateToYMDGo(record.GetDateTime("start_date").Time()) != formatDateToYMDGo(start) {
+					record.Set("start_date", start.Format(timeLayoutFull))
+					if err := txDao.SaveRecord(record); err != nil {
+						return fmt.Errorf("failed to update start_date of queue item %s: %w", record.Id, err)
+					}
+					changed++
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, total, err
+	}
+	return changed, total, nil
+}
+
+// queueItemSpanGo returns the first and last day covered by a queue item.
+func queueItemSpanGo(item *models.Record) (time.Time, time.Time) {
+	start, _ := parseYMDToGoTime(formatDateToYMDGo(item.GetDateTime("start_date").Time()))
+	return start, start.AddDate(0, 0, item.GetInt("duration_days")-1)
+}
+
+// flowAroundPinnedGo returns the earliest start on or after start at which a span of durationDays days
+// doesn't overlap any of the pinned queue items.
+func flowAroundPinnedGo(pinned []*models.Record, start time.Time, durationDays int) time.Time {
+	for moved := true; moved; {
+		moved = false
+		spanEnd := start.AddDate(0, 0, durationDays-1)
+		for _, item := range pinned {
+			itemStart, itemEnd := queueItemSpanGo(item)
+			if !start.After(itemEnd) && !itemStart.After(spanEnd) {
+				start = itemEnd.AddDate(0, 0, 1)
+				moved = true
+			}
+		}
+	}
+	return start
+}
+
+// findPinnedQueueItemsGo returns the roster's pinned queue items ordered by start_date.
+func findPinnedQueueItemsGo(dao *daos.Dao, rosterID string) ([]*models.Record, error) {
+	pinned := []*models.Record{}
+	err := dao.RecordQuery("assignment_queue").AndWhere(dbx.HashExp{"pinned": true}).AndWhere(rosterExpGo(rosterID)).OrderBy("start_date ASC").All(&pinned)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to fetch pinned queue items: %w", err)
+	}
+	return pinned, nil
+}
+
+// queueSpanOverlaps reports whether the span of durationDays days beginning at start overlaps the span
+// of any existing queue item of the roster. Adjacent spans (one ending the day before the other starts) don't overlap,
+// and neither do items for the two halves (half, "" = whole day) of one day.
+func queueSpanOverlaps(dao *daos.Dao, rosterID string, start time.Time, durationDays int, half string) (bool, string, error) {
+	queueRecords := []*models.Record{}
+	if err := dao.RecordQuery("assignment_queue").AndWhere(rosterExpGo(rosterID)).OrderBy("start_date ASC").All(&queueRecords); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, "", fmt.Errorf("failed to fetch queue items: %w", err)
+	}
+
+	spanStart, _ := parseYMDToGoTime(formatDateToYMDGo(start))
+	spanEnd := spanStart.AddDate(0, 0, durationDays-1)
+	for _, record := range queueRecords {
+		if half != "" && halfDayGo(record) == otherHalfGo(half) {
+			continue
+		}
+		itemStart, _ := parseYMDToGoTime(formatDateToYMDGo(record.GetDateTime("start_date").Time()))
+		itemEnd := itemStart.AddDate(0, 0, record.GetInt("duration_days")-1)
+		if !spanStart.After(itemEnd) && !itemStart.After(spanEnd) {
+			return true, record.Id, nil
+		}
+	}
+	return false, "", nil
+}
+
+// resolveQueueSpanOverlapGo applies the QUEUE_OVERLAP_POLICY to a new queue span. With "reject" (default)
+// it returns the id of the conflicting item; with "shift" it moves the span past any conflicting items
+// and returns the adjusted start date.
+func resolveQueueSpanOverlapGo(dao *daos.Dao, rosterID string, start time.Time, durationDays int, half string) (time.Time, string, error) {
+	shift := strings.ToLower(strings.TrimSpace(os.Getenv("QUEUE_OVERLAP_POLICY"))) == "shift"
+	for {
+		overlaps, conflictID, err := queueSpanOverlaps(dao, rosterID, start, durationDays, half)
+		if err != nil || !overlaps {
+			return start, "", err
+		}
+		if !shift {
+			return start, conflictID, nil
+		}
+		conflicting, err := dao.FindRecordById("assignment_queue", conflictID)
+		if err != nil {
+			return start, "", fmt.Errorf("failed to load conflicting queue item %s: %w", conflictID, err)
+		}
+		conflictStart, _ := parseYMDToGoTime(formatDateToYMDGo(conflicting.GetDateTime("start_date").Time()))
+		start = conflictStart.AddDate(0, 0, conflicting.GetInt("duration_days"))
+		log.Printf("Queue span overlapped item %s, shifting start to %s.", conflictID, formatDateToYMDGo(start))
+	}
+}
+
+// isWorkerOnDutyGo reports whether the worker has an assignment on the given day or a queue item spanning it.
+func isWorkerOnDutyGo(dao *daos.Dao, workerID string, day time.Time) (bool, error) {
+	assignment := &models.Record{}
+	err := dao.RecordQuery("assignments").
+		AndWhere(dayRangeExpGo("date", day, day)).
+		AndWhere(dbx.HashExp{"worker_id": workerID}).
+		Limit(1).
+		One(assignment)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	queueItems := []*models.Record{}
+	err = dao.RecordQuery("assignment_queue").
+		AndWhere(dbx.HashExp{"worker_id": workerID}).
+		AndWhere(dbx.NewExp("start_date <= {:day}", dbx.Params{"day": day.Add(24*time.Hour - time.Nanosecond).Format(timeLayoutFull)})).
+		All(&queueItems)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	for _, item := range queueItems {
+		itemStart := item.GetDateTime("start_date").Time()
+		if itemStart.IsZero() {
+			continue
+		}
+		if !day.After(itemStart.AddDate(0, 0, item.GetInt("duration_days")-1)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// countConsecutiveDutyDaysBeforeGo counts how many days in a row the worker is on duty right before
+// the given day, looking back at most limit days.
+func countConsecutiveDutyDaysBeforeGo(dao *daos.Dao, workerID string, day time.Time, limit int) (int, error) {
+	count := 0
+	for count < limit {
+		onDuty, err := isWorkerOnDutyGo(dao, workerID, day.AddDate(0, 0, -(count+1)))
+		if err != nil {
+			return count, err
+		}
+		if !onDuty {
+			break
+		}
+		count++
+	}
+	return count, nil
+}
+
+// getAssignmentWeightGo returns the weight of an assignment, defaulting to 1 when unset.
+func getAssignmentWeightGo(record *models.Record) float64 {
+	if weight := record.GetFloat("weight"); weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+// getLatestAssignmentWeightGo returns the weight of the worker's most recent assignment (1 if none).
+func getLatestAssignmentWeightGo(dao *daos.Dao, workerID string) float64 {
+	latest := &models.Record{}
+	err := dao.RecordQuery("assignments").
+		AndWhere(dbx.HashExp{"worker_id": workerID}).
+		OrderBy("date DESC").
+		Limit(1).
+		One(latest)
+	if err != nil || latest.Id == "" {
+		return 1
+	}
+	return getAssignmentWeightGo(latest)
+}
+
+// getWorkerStatsGo aggregates assignment counts and weighted totals per worker of a roster (every worker
+// when rosterID is empty), optionally limited to the [startYMD, endYMD] range (empty strings mean
+// unbounded). Workers without assignments get zero rows.
+func getWorkerStatsGo(dao *daos.Dao, rosterID string, startYMD string, endYMD string) ([]WorkerStats, error) {
+	query := dao.DB().
+		Select(
+			"worker_id",
+			"COUNT(*) AS total",
+			"COALESCE(SUM(CASE WHEN status = 'done' THEN 1 ELSE 0 END), 0) AS done",
+			"COALESCE(SUM(CASE WHEN status = 'not_done' THEN 1 ELSE 0 END), 0) AS not_done",
+			"COALESCE(SUM(CASE WHEN weight > 0 THEN weight ELSE 1 END), 0) AS weighted_total",
+			"COALESCE(SUM(CASE WHEN status = 'done' THEN (CASE WHEN weight > 0 THEN weight ELSE 1 END) ELSE 0 END), 0) AS weighted_done",
+		).
+		From("assignments").
+		AndWhere(rosterExpGo(rosterID)).
+		GroupBy("worker_id")
+	if startYMD != "" {
+		startTime, err := parseYMDToGoTime(startYMD)
+		if err != nil {
+			return nil, err
+		}
+		query.AndWhere(dbx.NewExp("date >= {:startDate}", dbx.Params{"startDate": startTime.Format(timeLayoutYMD)}))
+	}
+	if endYMD != "" {
+		endTime, err := parseYMDToGoTime(endYMD)
+		if err != nil {
+			return nil, err
+		}
+		query.AndWhere(dbx.NewExp("date < {:endDate}", dbx.Params{"endDate": endTime.AddDate(0, 0, 1).Format(timeLayoutYMD)}))
+	}
+
+	rows := []WorkerStats{}
+	if err := query.All(&rows); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to aggregate assignments: %w", err)
+	}
+	statsByWorker := make(map[string]WorkerStats, len(rows))
+	for _, row := range rows {
+		statsByWorker[row.WorkerID] = row
+	}
+
+	workers, err := rosterWorkersGo(dao, rosterID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch workers: %w", err)
+	}
+	sortWorkersByNameGo(workers)
+	result := make([]WorkerStats, 0, len(workers))
+	for _, worker := range workers {
+		entry := statsByWorker[worker.Id]
+		entry.WorkerID = worker.Id
+		entry.WorkerName = worker.GetString("name")
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+// summarizeWorkerStatsGo adds up the per-worker stats rows. Ties for busiest and least busy go to the
+// first worker in the given order.
+func summarizeWorkerStatsGo(workerStats []WorkerStats) StatsSummary {
+	summary := StatsSummary{}
+	var busiest, leastBusy *WorkerStats
+	for i := range workerStats {
+		stats := &workerStats[i]
+		summary.TotalAssignments += stats.Total
+		summary.Done += stats.Done
+		summary.NotDone += stats.NotDone
+		if busiest == nil || stats.Total > busiest.Total {
+			busiest = stats
+		}
+		if leastBusy == nil || stats.Total < leastBusy.Total {
+			leastBusy = stats
+		}
+	}
+	if summary.TotalAssignments == 0 {
+		return summary
+	}
+	summary.CompletionRate = float64(summary.Done) / float64(summary.TotalAssignments)
+	summary.AveragePerWorker = float64(summary.TotalAssignments) / float64(len(workerStats))
+	summary.Busiest = &StatsExtreme{WorkerID: busiest.WorkerID, WorkerName: busiest.WorkerName, Total: busiest.Total}
+	summary.LeastBusy = &StatsExtreme{WorkerID: leastBusy.WorkerID, WorkerName: leastBusy.WorkerName, Total: leastBusy.Total}
+	return summary
+}
+
+// buildLeaderboardGo ranks workers by done assignments (descending), breaking ties alphabetically.
+// Workers with the same done count share a rank ("1, 1, 3"), so with no history at all every worker
+// is listed with rank 1 and zero done.
+func buildLeaderboardGo(workerStats []WorkerStats) []LeaderboardEntry {
+	sort.SliceStable(workerStats, func(i, j int) bool {
+		if workerStats[i].Done != workerStats[j].Done {
+			return workerStats[i].Done > workerStats[j].Done
+		}
+		return strings.ToLower(workerStats[i].WorkerName) < strings.ToLower(workerStats[j].WorkerName)
+	})
+
+	leaderboard := make([]LeaderboardEntry, 0, len(workerStats))
+	for i, stats := range workerStats {
+		rank := i + 1
+		if i > 0 && stats.Done == workerStats[i-1].Done {
+			rank = leaderboard[i-1].Rank
+		}
+		leaderboard = append(leaderboard, LeaderboardEntry{
+			Rank:       rank,
+			WorkerID:   stats.WorkerID,
+			WorkerName: stats.WorkerName,
+			Done:       stats.Done,
+		})
+	}
+	return leaderboard
+}
+
+// collectionSpec describes a collection the app relies on. ensureCollection uses it to create the
+// collection or to bring an existing one up to date.
+type collectionSpec struct {
+	Name       string
+	ListRule   *string
+	ViewRule   *string
+	CreateRule *string
+	UpdateRule *string
+	DeleteRule *string
+	Fields     []*schema.SchemaField
+	// Indexes are CREATE INDEX statements, matched by index name. ensureCollectionIndexesGo applies them
+	// once the data migrations have run.
+	Indexes []string
+}
+
+// reconcileCollectionRules resets any API rule of collection that drifted from spec (e.g. edited in the
+// admin UI) and reports whether anything changed.
+func reconcileCollectionRules(collection *models.Collection, spec collectionSpec) bool {
+	changed := false
+	for _, rule := range []struct {
+		current **string
+		desired *string
+	}{
+		{&collection.ListRule, spec.ListRule},
+		{&collection.ViewRule, spec.ViewRule},
+		{&collection.CreateRule, spec.CreateRule},
+		{&collection.UpdateRule, spec.UpdateRule},
+		{&collection.DeleteRule, spec.DeleteRule},
+	} {
+		if equalStringPointersGo(*rule.current, rule.desired) {
+			continue
+		}
+		if rule.desired == nil {
+			*rule.current = nil
+		} else {
+			*rule.current = types.Pointer(*rule.desired)
+		}
+		changed = true
+	}
+	return changed
+}
+
+func equalStringPointersGo(a *string, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func equalFloatPointersGo(a *float64, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// ensureCollectionIndexesGo adds the indexes of spec that the collection lacks and replaces those whose
+// definition changed. Indexes not in spec are left alone. A unique index fails if existing rows violate it.
+func ensureCollectionIndexesGo(dao *daos.Dao, spec collectionSpec) error {
+	if len(spec.Indexes) == 0 {
+		return nil
+	}
+	collection, err := dao.FindCollectionByNameOrId(spec.Name)
+	if err != nil {
+		return fmt.Errorf("collection '%s': %w", spec.Name, err)
+	}
+	indexes := append(types.JsonArray[string]{}, collection.Indexes...)
+	changed := []string{}
+	for _, desired := range spec.Indexes {
+		name := dbutils.ParseIndex(desired).IndexName
+		position := -1
+		for i, existing := range indexes {
+			if strings.EqualFold(dbutils.ParseIndex(existing).IndexName, name) {
+				position = i
+				break
+			}
+		}
+		switch {
+		case position < 0:
+			indexes = append(indexes, desired)
+		case indexes[position] == desired:
+			continue
+		default:
+			indexes[position] = desired
+		}
+		changed = append(changed, name)
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	collection.Indexes = indexes
+	if err := dao.SaveCollection(collection); err != nil {
+		return fmt.Errorf("collection '%s': failed to create index %s: %w", spec.Name, strings.Join(changed, ", "), err)
+	}
+	log.Printf("'%s' collection indexes updated: %s.", spec.Name, strings.Join(changed, ", "))
+	return nil
+}
+
+// ensureCollection creates the collection described by spec if it doesn't exist yet. For an existing
+// collection it adds missing fields, missing select values (so new statuses/action types can be
+// introduced on older installs), syncs number bounds and restores drifted API rules, without touching anything else. Every invalid field is reported in the
+// returned error, each wrapped with the collection and field name.
+func ensureCollection(dao *daos.Dao, spec collectionSpec) error {
+	collection, _ := dao.FindCollectionByNameOrId(spec.Name)
+	isNew := collection == nil
+	changed := []string{}
+
+	if isNew {
+		collection = &models.Collection{
+			Name:       spec.Name,
+			Type:       models.CollectionTypeBase,
+			ListRule:   spec.ListRule,
+			ViewRule:   spec.ViewRule,
+			CreateRule: spec.CreateRule,
+			UpdateRule: spec.UpdateRule,
+			DeleteRule: spec.DeleteRule,
+			Schema:     schema.NewSchema(spec.Fields...),
+		}
+	} else {
+		for _, field := range spec.Fields {
+			existing := collection.Schema.GetFieldByName(field.Name)
+			if existing == nil {
+				collection.Schema.AddField(field)
+				changed = append(changed, field.Name)
+				continue
+			}
+			if existing.InitOptions() != nil {
+				continue
+			}
+			if desiredNumber, isNumber := field.Options.(*schema.NumberOptions); isNumber {
+				existingNumber, ok := existing.Options.(*schema.NumberOptions)
+				if ok && (!equalFloatPointersGo(existingNumber.Min, desiredNumber.Min) || !equalFloatPointersGo(existingNumber.Max, desiredNumber.Max)) {
+					existingNumber.Min = desiredNumber.Min
+					existingNumber.Max = desiredNumber.Max
+					changed = append(changed, field.Name+" bounds")
+				}
+				continue
+			}
+			desiredOptions, isSelect := field.Options.(*schema.SelectOptions)
+			if !isSelect {
+				continue
+			}
+			existingOptions, ok := existing.Options.(*schema.SelectOptions)
+			if !ok {
+				continue
+			}
+			for _, value := range desiredOptions.Values {
+				if !list.ExistInSlice(value, existingOptions.Values) {
+					existingOptions.Values = append(existingOptions.Values, value)
+					changed = append(changed, field.Name+"="+value)
+				}
+			}
+		}
+		if reconcileCollectionRules(collection, spec) {
+			changed = append(changed, "rules")
+		}
+		if len(changed) == 0 {
+			log.Printf("'%s' collection already exists.", spec.Name)
+			return nil
+		}
+	}
+
+	var fieldErrs []error
+	for _, field := range collection.Schema.Fields() {
+		if err := field.Validate(); err != nil {
+			fieldErrs = append(fieldErrs, fmt.Errorf("collection '%s': field '%s': %w", spec.Name, field.Name, err))
+		}
+	}
+	if len(fieldErrs) > 0 {
+		return errors.Join(fieldErrs...)
+	}
+
+	if err := dao.SaveCollection(collection); err != nil {
+		return fmt.Errorf("collection '%s': failed to save: %w", spec.Name, err)
+	}
+	if isNew {
+		log.Printf("'%s' collection created successfully.", spec.Name)
+	} else {
+		log.Printf("'%s' collection updated: %v", spec.Name, changed)
+	}
+	return nil
+}
+
+func rostersCollectionSpecGo() collectionSpec {
+	adminOnly := types.Pointer("@request.auth.id != '' && @request.auth.admin = true")
+	return collectionSpec{
+		Name:     "rosters",
+		ListRule: nil, ViewRule: nil, CreateRule: adminOnly, UpdateRule: adminOnly, DeleteRule: adminOnly,
+		Fields: []*schema.SchemaField{
+			{Name: "name", Type: schema.FieldTypeText, Required: true, Options: &schema.TextOptions{Min: types.Pointer(1), Max: types.Pointer(workerNameMaxLength)}},
+			// Inactive rosters keep their history but get no new daily assignments.
+			{Name: "inactive", Type: schema.FieldTypeBool, Required: false, Options: &schema.BoolOptions{}},
+			// Persisted round_robin position of this roster (see roundRobinCursor); written by the daily assignment.
+			{Name: "round_robin_cursor", Type: schema.FieldTypeJson, Required: false, Options: &schema.JsonOptions{}},
+		},
+	}
+}
+
+// rosterRelationFieldGo is the roster_id field shared by workers, assignments and queue items. It is left
+// optional so records created elsewhere (admin UI, hooks) can fall back to their worker's roster or the default one.
+func rosterRelationFieldGo(rostersCollectionID string) *schema.SchemaField {
+	return &schema.SchemaField{
+		Name: "roster_id", Type: schema.FieldTypeRelation, Required: false,
+		Options: &schema.RelationOptions{CollectionId: rostersCollectionID, CascadeDelete: false, MaxSelect: types.Pointer(1)},
+	}
+}
+
+func workersCollectionSpecGo(rostersCollectionID string) collectionSpec {
+	return collectionSpec{
+		Name:       "workers",
+		ListRule:   nil,
+		ViewRule:   nil,
+		CreateRule: types.Pointer("@request.auth.id != '' && @request.auth.admin = true"),
+		UpdateRule: types.Pointer("@request.auth.id != '' && @request.auth.admin = true"),
+		DeleteRule: types.Pointer("@request.auth.id != '' && @request.auth.admin = true"),
+		Fields: []*schema.SchemaField{
+			{
+				Name:     "name",
+				Type:     schema.FieldTypeText,
+				Required: true,
+				Unique:   true,
+				System:   false,
+				Options:  &schema.TextOptions{Min: types.Pointer(1), Max: nil, Pattern: ""},
+			},
+			{
+				Name:     "last_assigned_date",
+				Type:     schema.FieldTypeDate,
+				Required: false,
+				System:   false,
+				Options:  &schema.DateOptions{},
+			},
+			// Optional notification targets; a worker without either simply isn't notified.
+			{
+				Name:     "email",
+				Type:     schema.FieldTypeEmail,
+				Required: false,
+				System:   false,
+				Options:  &schema.EmailOptions{},
+			},
+			{
+				Name:     "telegram_chat_id",
+				Type:     schema.FieldTypeText,
+				Required: false,
+				System:   false,
+				Options:  &schema.TextOptions{},
+			},
+			// Inactive workers are kept for history but never picked; false (the default) means active.
+			{
+				Name:     "inactive",
+				Type:     schema.FieldTypeBool,
+				Required: false,
+				System:   false,
+				Options:  &schema.BoolOptions{},
+			},
+			// Volunteers for weekend duty. When weekends aren't skipped, only they are picked on Saturdays and Sundays.
+			{
+				Name:     "weekend_ok",
+				Type:     schema.FieldTypeBool,
+				Required: false,
+				System:   false,
+				Options:  &schema.BoolOptions{},
+			},
+			// Tiebreaker for equally eligible workers; lower goes first. 0 (the default) keeps insertion order.
+			{
+				Name:     "priority",
+				Type:     schema.FieldTypeNumber,
+				Required: false,
+				System:   false,
+				Options:  &schema.NumberOptions{NoDecimal: true},
+			},
+			// Channels the worker wants to be notified on; empty means every configured channel.
+			{
+				Name:     "notify_channels",
+				Type:     schema.FieldTypeSelect,
+				Required: false,
+				System:   false,
+				Options:  &schema.SelectOptions{MaxSelect: len(notificationChannels), Values: notificationChannels},
+			},
+			// Temporary nudge in days: positive moves the worker's last turn earlier (picked sooner), negative
+			// later. Ignored after selection_bias_until.
+			{
+				Name:     "selection_bias",
+				Type:     schema.FieldTypeNumber,
+				Required: false,
+				System:   false,
+				Options:  &schema.NumberOptions{Min: types.Pointer(float64(-selectionBiasMaxDays)), Max: types.Pointer(float64(selectionBiasMaxDays)), NoDecimal: true},
+			},
+			{
+				Name:     "selection_bias_until",
+				Type:     schema.FieldTypeDate,
+				Required: false,
+				System:   false,
+				Options:  &schema.DateOptions{},
+			},
+			// Optional presentation fields for the frontend.
+			{
+				Name:     "display_name",
+				Type:     schema.FieldTypeText,
+				Required: false,
+				System:   false,
+				Options:  &schema.TextOptions{Max: types.Pointer(workerNameMaxLength)},
+			},
+			{
+				Name:     "color",
+				Type:     schema.FieldTypeText,
+				Required: false,
+				System:   false,
+				Options:  &schema.TextOptions{Pattern: workerColorPattern},
+			},
+			// Optional; empty or 0 means the worker can be on duty any number of days in a row.
+			{
+				Name:     "max_consecutive_days",
+				Type:     schema.FieldTypeNumber,
+				Required: false,
+				System:   false,
+				Options:  &schema.NumberOptions{Min: types.Pointer(0.0), NoDecimal: true},
+			},
+			rosterRelationFieldGo(rostersCollectionID),
+		},
+	}
+}
+
+func assignmentsCollectionSpecGo(workersCollectionID string, rostersCollectionID string) collectionSpec {
+	return collectionSpec{
+		Name:       "assignments",
+		ListRule:   nil,
+		ViewRule:   nil,
+		CreateRule: types.Pointer("@request.auth.id != ''"),
+		UpdateRule: types.Pointer("@request.auth.id != ''"),
+		DeleteRule: types.Pointer("@request.auth.id != ''"),
+		Fields: []*schema.SchemaField{
+			{
+				Name:     "worker_id",
+				Type:     schema.FieldTypeRelation,
+				Required: true,
+				Options: &schema.RelationOptions{
+					CollectionId:  workersCollectionID,
+					CascadeDelete: false,
+					MinSelect:     types.Pointer(1),
+					MaxSelect:     types.Pointer(1),
+				},
+			},
+			{
+				Name:     "date",
+				Type:     schema.FieldTypeDate,
+				Required: true,
+				Options:  &schema.DateOptions{},
+			},
+			{
+				Name:     "status",
+				Type:     schema.FieldTypeSelect,
+				Required: true,
+				Options: &schema.SelectOptions{
+					MaxSelect: 1,
+					Values:    assignmentStatuses,
+				},
+			},
+			// Optional; missing or zero weights count as 1.
+			{
+				Name:     "weight",
+				Type:     schema.FieldTypeNumber,
+				Required: false,
+				Options:  &schema.NumberOptions{Min: types.Pointer(0.0)},
+			},
+			{
+				Name:     "source",
+				Type:     schema.FieldTypeSelect,
+				Required: false,
+				Options:  &schema.SelectOptions{MaxSelect: 1, Values: assignmentSources},
+			},
+			// Optional handle for an external scheduling system; non-empty refs are unique.
+			{
+				Name:     "external_ref",
+				Type:     schema.FieldTypeText,
+				Required: false,
+				Options:  &schema.TextOptions{Max: types.Pointer(externalRefMaxLength)},
+			},
+			// The worker's last_assigned_date before this assignment overwrote it, so a reset can roll it back.
+			{
+				Name:     "previous_last_assigned_date",
+				Type:     schema.FieldTypeDate,
+				Required: false,
+				Options:  &schema.DateOptions{},
+			},
+			// Optional photo attached when marking the assignment done.
+			{
+				Name:     "proof",
+				Type:     schema.FieldTypeFile,
+				Required: false,
+				Options:  &schema.FileOptions{MaxSelect: 1, MaxSize: proofMaxBytes, MimeTypes: proofMimeTypes},
+			},
+			// Optional note left when marking the assignment done; REQUIRE_PROOF_FOR_DONE accepts it instead of a photo.
+			{
+				Name:     "done_note",
+				Type:     schema.FieldTypeText,
+				Required: false,
+				Options:  &schema.TextOptions{Max: types.Pointer(doneNoteMaxLength)},
+			},
+			// The queue item a pending_acceptance assignment came from; consumed or dropped once the worker answers.
+			{
+				Name:     "queue_item_id",
+				Type:     schema.FieldTypeText,
+				Required: false,
+				Options:  &schema.TextOptions{},
+			},
+			// Lets the worker of a pending_acceptance assignment answer it without the admin password.
+			{
+				Name:     "acceptance_token",
+				Type:     schema.FieldTypeText,
+				Required: false,
+				Options:  &schema.TextOptions{},
+			},
+			rosterRelationFieldGo(rostersCollectionID),
+			// The whole day, or the half of a day shared with another assignment.
+			{
+				Name:     "coverage",
+				Type:     schema.FieldTypeSelect,
+				Required: false,
+				Options:  &schema.SelectOptions{MaxSelect: 1, Values: coverageValues},
+			},
+		},
+		// One assignment per roster, day and half. Every write stores the day as its UTC midnight in
+		// timeLayoutFull, which normalizeStoredDatesGo also rewrites older values to. That a whole day isn't
+		// also split into halves is up to the writers.
+		Indexes: []string{
+			"CREATE UNIQUE INDEX `idx_assignments_roster_date` ON `assignments` (`roster_id`, `date`, `coverage`)",
+		},
+	}
+}
+
+func assignmentQueueCollectionSpecGo(workersCollectionID string, rostersCollectionID string) collectionSpec {
+	return collectionSpec{
+		Name:       "assignment_queue",
+		ListRule:   nil,
+		ViewRule:   nil,
+		CreateRule: types.Pointer("@request.auth.id != '' && @request.auth.admin = true"),
+		UpdateRule: types.Pointer("@request.auth.id != '' && @request.auth.admin = true"),
+		DeleteRule: types.Pointer("@request.auth.id != '' && @request.auth.admin = true"),
+		Fields: []*schema.SchemaField{
+			{
+				Name: "worker_id", Type: schema.FieldTypeRelation, Required: true,
+				Options: &schema.RelationOptions{CollectionId: workersCollectionID, CascadeDelete: false, MinSelect: types.Pointer(1), MaxSelect: types.Pointer(1)},
+			},
+			{Name: "start_date", Type: schema.FieldTypeDate, Required: true, Options: &schema.DateOptions{}},
+			{Name: "duration_days", Type: schema.FieldTypeNumber, Required: true, Options: &schema.NumberOptions{Min: types.Pointer(1.0), Max: types.Pointer(float64(getQueueMaxDaysGo())), NoDecimal: true}},
+			{Name: "order", Type: schema.FieldTypeNumber, Required: true, Options: &schema.NumberOptions{NoDecimal: true}},
+			// Pinned items keep their admin-set start_date; recomputes flow unpinned items around them.
+			{Name: "pinned", Type: schema.FieldTypeBool, Required: false, Options: &schema.BoolOptions{}},
+			// Handed on to the assignment for the item's first day.
+			{Name: "external_ref", Type: schema.FieldTypeText, Required: false, Options: &schema.TextOptions{Max: types.Pointer(externalRefMaxLength)}},
+			rosterRelationFieldGo(rostersCollectionID),
+			{Name: "source", Type: schema.FieldTypeSelect, Required: false, Options: &schema.SelectOptions{MaxSelect: 1, Values: queueItemSources}},
+			{Name: "coverage", Type: schema.FieldTypeSelect, Required: false, Options: &schema.SelectOptions{MaxSelect: 1, Values: coverageValues}},
+		},
+	}
+}
+
+func recurringAssignmentsCollectionSpecGo(workersCollectionID string) collectionSpec {
+	adminOnly := types.Pointer("@request.auth.id != '' && @request.auth.admin = true")
+	return collectionSpec{
+		Name:     "recurring_assignments",
+		ListRule: nil, ViewRule: nil, CreateRule: adminOnly, UpdateRule: adminOnly, DeleteRule: adminOnly,
+		Fields: []*schema.SchemaField{
+			{
+				Name: "worker_id", Type: schema.FieldTypeRelation, Required: true,
+				Options: &schema.RelationOptions{CollectionId: workersCollectionID, CascadeDelete: true, MinSelect: types.Pointer(1), MaxSelect: types.Pointer(1)},
+			},
+			{Name: "weekday", Type: schema.FieldTypeSelect, Required: true, Options: &schema.SelectOptions{MaxSelect: 1, Values: weekdayNames}},
+			// Lower wins when several rules share a weekday.
+			{Name: "priority", Type: schema.FieldTypeNumber, Required: false, Options: &schema.NumberOptions{NoDecimal: true}},
+		},
+	}
+}
+
+func actionLogCollectionSpecGo() collectionSpec {
+	return collectionSpec{
+		Name:     "action_log",
+		ListRule: types.Pointer("@request.auth.id != '' && @request.auth.admin = true"), ViewRule: types.Pointer("@request.auth.id != '' && @request.auth.admin = true"),
+		CreateRule: types.Pointer("@request.auth.id != ''"), UpdateRule: types.Pointer(""), DeleteRule: types.Pointer(""),
+		Fields: []*schema.SchemaField{
+			{Name: "timestamp", Type: schema.FieldTypeDate, Required: true, Options: &schema.DateOptions{}},
+			{Name: "action_type", Type: schema.FieldTypeSelect, Required: true, Options: &schema.SelectOptions{MaxSelect: 1, Values: actionLogTypes}},
+			{Name: "details", Type: schema.FieldTypeJson, Required: false, Options: &schema.JsonOptions{}},
+		},
+	}
+}
+
+// deletedWorkersCollectionSpecGo holds a tombstone per deleted worker, so /api/dishduty/workers?since= can
+// tell polling clients which cached workers to drop. The record's created time is the deletion time.
+func deletedWorkersCollectionSpecGo() collectionSpec {
+	adminOnly := types.Pointer("@request.auth.id != '' && @request.auth.admin = true")
+	return collectionSpec{
+		Name:     "deleted_workers",
+		ListRule: nil, ViewRule: nil, CreateRule: adminOnly, UpdateRule: adminOnly, DeleteRule: adminOnly,
+		Fields: []*schema.SchemaField{
+			{Name: "worker_id", Type: schema.FieldTypeText, Required: true, Options: &schema.TextOptions{}},
+			{Name: "name", Type: schema.FieldTypeText, Required: false, Options: &schema.TextOptions{}},
+			{Name: "roster_id", Type: schema.FieldTypeText, Required: false, Options: &schema.TextOptions{}},
+		},
+	}
+}
+
+// blackoutDatesCollectionSpecGo holds household-wide days off, e.g. holidays, on which no roster gets an
+// assignment. There is at most one record per date; /api/dishduty/blackout enforces that.
+func blackoutDatesCollectionSpecGo() collectionSpec {
+	adminOnly := types.Pointer("@request.auth.id != '' && @request.auth.admin = true")
+	return collectionSpec{
+		Name:     "blackout_dates",
+		ListRule: nil, ViewRule: nil, CreateRule: adminOnly, UpdateRule: adminOnly, DeleteRule: adminOnly,
+		Fields: []*schema.SchemaField{
+			{Name: "date", Type: schema.FieldTypeDate, Required: true, Options: &schema.DateOptions{}},
+			{Name: "reason", Type: schema.FieldTypeText, Required: false, Options: &schema.TextOptions{Max: types.Pointer(blackoutReasonMaxLength)}},
+		},
+	}
+}
+
+func settingsCollectionSpecGo() collectionSpec {
+	adminOnly := types.Pointer("@request.auth.id != '' && @request.auth.admin = true")
+	return collectionSpec{
+		Name:     "settings",
+		ListRule: adminOnly, ViewRule: adminOnly, CreateRule: adminOnly, UpdateRule: adminOnly, DeleteRule: adminOnly,
+		Fields: []*schema.SchemaField{
+			// Empty means "use ON_EMPTY_QUEUE".
+			{Name: "on_empty_queue", Type: schema.FieldTypeSelect, Required: false, Options: &schema.SelectOptions{MaxSelect: 1, Values: []string{onEmptyQueueRandom, onEmptyQueueStop}}},
+			{Name: "fairness_use_weights", Type: schema.FieldTypeBool, Required: false, Options: &schema.BoolOptions{}},
+			{Name: "skip_weekends", Type: schema.FieldTypeBool, Required: false, Options: &schema.BoolOptions{}},
+			{Name: "paused", Type: schema.FieldTypeBool, Required: false, Options: &schema.BoolOptions{}},
+			// A select rather than a bool so records created before the field existed (empty) keep allowing duplicates.
+			{Name: "duplicate_queue_entries", Type: schema.FieldTypeSelect, Required: false, Options: &schema.SelectOptions{MaxSelect: 1, Values: []string{"allow", "reject"}}},
+			// Set by POST /api/dishduty/snooze; the daily assignment isn't created through this date.
+			{Name: "snooze_until", Type: schema.FieldTypeDate, Required: false, Options: &schema.DateOptions{}},
+		},
+	}
+}
+
+// getEnvSettingsGo returns the settings derived from the environment, with hardcoded defaults for
+// anything the environment doesn't cover. Used to seed the settings record and when it can't be read.
+func getEnvSettingsGo() AppSettings {
+	return AppSettings{
+		OnEmptyQueue:               getOnEmptyQueueModeGo(),
+		FairnessUseWeights:         strings.EqualFold(os.Getenv("FAIRNESS_USE_WEIGHTS"), "true"),
+		SkipWeekends:               false,
+		Paused:                     false,
+		AllowDuplicateQueueEntries: true,
+	}
+}
+
+// findSettingsRecordGo returns the singleton settings record, or nil if it hasn't been seeded.
+func findSettingsRecordGo(dao *daos.Dao) (*models.Record, error) {
+	record := &models.Record{}
+	err := dao.RecordQuery("settings").OrderBy("created ASC").Limit(1).One(record)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// ensureSettingsRecordGo seeds the settings record from the environment if it doesn't exist yet.
+func ensureSettingsRecordGo(dao *daos.Dao) error {
+	existing, err := findSettingsRecordGo(dao)
+	if err != nil {
+		return fmt.Errorf("failed to look up settings: %w", err)
+	}
+	if existing != nil {
+		return nil
+	}
+	collection, err := dao.FindCollectionByNameOrId("settings")
+	if err != nil {
+		return fmt.Errorf("failed to find settings collection: %w", err)
+	}
+	defaults := getEnvSettingsGo()
+	record := models.NewRecord(collection)
+	record.Set("on_empty_queue", defaults.OnEmptyQueue)
+	record.Set("fairness_use_weights", defaults.FairnessUseWeights)
+	record.Set("skip_weekends", defaults.SkipWeekends)
+	record.Set("paused", defaults.Paused)
+	if err := dao.SaveRecord(record); err != nil {
+		return fmt.Errorf("failed to seed settings: %w", err)
+	}
+	log.Println("Settings seeded from environment defaults.")
+	return nil
+}
+
+// getSettingsGo returns the effective settings: the settings record where set, falling back to the
+// environment and then to hardcoded defaults.
+func getSettingsGo(dao *daos.Dao) AppSettings {
+	settings := getEnvSettingsGo()
+	record, err := findSettingsRecordGo(dao)
+	if err != nil {
+		log.Printf("Error reading settings, using environment defaults: %v", err)
+		return settings
+	}
+	if record == nil {
+		return settings
+	}
+	if mode := record.GetString("on_empty_queue"); mode != "" {
+		settings.OnEmptyQueue = mode
+	}
+	settings.FairnessUseWeights = record.GetBool("fairness_use_weights")
+	settings.SkipWeekends = record.GetBool("skip_weekends")
+	settings.Paused = record.GetBool("paused")
+	settings.AllowDuplicateQueueEntries = record.GetString("duplicate_queue_entries") != "reject"
+	if snoozeUntil := record.GetDateTime("snooze_until"); !snoozeUntil.IsZero() {
+		settings.SnoozeUntil = snoozeUntil.Time().Format(timeLayoutYMD)
+	}
+	return settings
+}
+
+func recurringAssignmentEntryGo(dao *daos.Dao, rule *models.Record) RecurringAssignmentEntry {
+	return RecurringAssignmentEntry{
+		ID:         rule.Id,
+		WorkerID:   rule.GetString("worker_id"),
+		WorkerName: getWorkerNameGo(dao, rule.GetString("worker_id")),
+		Weekday:    rule.GetString("weekday"),
+		Priority:   rule.GetInt("priority"),
+	}
+}
+
+// getProofURLGo returns the download path of the assignment's proof photo, or "" if there is none.
+func getProofURLGo(assignment *models.Record) string {
+	filename := assignment.GetString("proof")
+	if filename == "" {
+		return ""
+	}
+	return fmt.Sprintf("/api/files/assignments/%s/%s", assignment.Id, filename)
+}
+
+// computeStreakGo walks the assignment history and returns the current run of consecutive done days
+// (ending today, or yesterday while today is still open) and the longest run ever. Days without any
+// assignment (weekends off, pauses) don't break a run unless STREAK_IGNORE_UNASSIGNED_DAYS=false.
+func computeStreakGo(dao *daos.Dao, rosterID string, todayYMD string) (StreakResponse, error) {
+	ignoreGaps := !strings.EqualFold(strings.TrimSpace(os.Getenv("STREAK_IGNORE_UNASSIGNED_DAYS")), "false")
+	today, err := parseYMDToGoTime(todayYMD)
+	if err != nil {
+		return StreakResponse{}, err
+	}
+
+	result := StreakResponse{}
+	run := 0
+	var lastDone time.Time
+	// The longest run needs the whole history, so it is read in batches rather than all at once.
+	for offset := int64(0); ; offset += historyBatchSize {
+		records := []*models.Record{}
+		err = dao.RecordQuery("assignments").
+			AndWhere(dbx.NewExp("date < {:tomorrow}", dbx.Params{"tomorrow": today.AddDate(0, 0, 1).Format(timeLayoutYMD)})).
+			AndWhere(rosterExpGo(rosterID)).
+			OrderBy("date ASC", "id ASC").
+			Limit(historyBatchSize).
+			Offset(offset).
+			All(&records)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return StreakResponse{}, err
+		}
+		for _, record := range records {
+			day := record.GetDateTime("date").Time()
+			if day.IsZero() {
+				continue
+			}
+			if record.GetString("status") != "done" {
+				if day.Equal(today) && (record.GetString("status") == "assigned" || record.GetString("status") == "pending_acceptance") {
+					continue // today is still open
+				}
+				run = 0
+				continue
+			}
+			if run > 0 && !ignoreGaps && !lastDone.AddDate(0, 0, 1).Equal(day) {
+				run = 0
+			}
+			run++
+			lastDone = day
+			if run > result.Longest {
+				result.Longest = run
+			}
+		}
+		if len(records) < historyBatchSize {
+			break
+		}
+	}
+	if run > 0 && !ignoreGaps && lastDone.Before(today.AddDate(0, 0, -1)) {
+		run = 0
+	}
+	result.Current = run
+	return result, nil
+}
+
+// findAssignmentForDateGo returns the roster's assignment stored for the given YMD date, or nil if there is
+// none. It matches the whole day as a range as well as the exact stored value, so it works regardless of
+// the time part PocketBase normalizes the stored date to.
+func findAssignmentForDateGo(dao *daos.Dao, rosterID string, ymd string) (*models.Record, error) {
+	day, err := parseYMDToGoTime(ymd)
+	if err != nil {
+		return nil, err
+	}
+
+	assignment := &models.Record{}
+	err = dao.RecordQuery("assignments").
+		AndWhere(sameDayExpGo("date", day)).
+		AndWhere(rosterExpGo(rosterID)).
+		Limit(1).
+		One(assignment)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return assignment, nil
+}
+
+// minSQLiteBusyTimeoutMs is the lowest busy_timeout accepted without a warning. Below it, the daily
+// assignment and queue writes racing with API writes start failing with "database is locked".
+const minSQLiteBusyTimeoutMs = 5000
+
+// readSQLitePragmasGo returns the journal mode and busy timeout (in milliseconds) of a pooled connection.
+func readSQLitePragmasGo(dao *daos.Dao) (string, int, error) {
+	var journalMode string
+	if err := dao.DB().NewQuery("PRAGMA journal_mode").Row(&journalMode); err != nil {
+		return "", 0, fmt.Errorf("failed to read journal_mode: %w", err)
+	}
+	var busyTimeout int
+	if err := dao.DB().NewQuery("PRAGMA busy_timeout").Row(&busyTimeout); err != nil {
+		return "", 0, fmt.Errorf("failed to read busy_timeout: %w", err)
+	}
+	return strings.ToLower(journalMode), busyTimeout, nil
+}
+
+// checkSQLiteTuningGo makes sure the database runs in WAL mode, so the scheduler's writes don't block API
+// reads, and warns when the busy timeout is too short to ride out concurrent writes. PocketBase opens every
+// connection with both already set; this catches a database file or driver build where that didn't stick.
+// WAL is stored in the database file, so switching it on here covers all connections. busy_timeout is
+// per connection and can only be reported.
+func checkSQLiteTuningGo(dao *daos.Dao) error {
+	journalMode, busyTimeout, err := readSQLitePragmasGo(dao)
+	if err != nil {
+		return err
+	}
+	if journalMode != "wal" {
+		log.Printf("SQLite journal_mode is '%s'. Switching to WAL.", journalMode)
+		if err := dao.DB().NewQuery("PRAGMA journal_mode = WAL").Row(&journalMode); err != nil {
+			return fmt.Errorf("failed to enable WAL mode: %w", err)
+		}
+		if !strings.EqualFold(journalMode, "wal") {
+			return fmt.Errorf("SQLite refused WAL mode and stayed in '%s'", journalMode)
+		}
+	}
+	if busyTimeout < minSQLiteBusyTimeoutMs {
+		log.Printf("Warning: SQLite busy_timeout is %dms (recommended at least %dms). Concurrent writes may fail with 'database is locked'.", busyTimeout, minSQLiteBusyTimeoutMs)
+	}
+	return nil
+}
+
+// getActionLogRetentionDaysGo returns ACTION_LOG_RETENTION_DAYS (default 0 = keep everything).
+func getActionLogRetentionDaysGo() int {
+	value := strings.TrimSpace(os.Getenv("ACTION_LOG_RETENTION_DAYS"))
+	if value == "" {
+		return 0
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		log.Printf("Warning: invalid ACTION_LOG_RETENTION_DAYS '%s'. Retention disabled.", value)
+		return 0
+	}
+	return days
+}
+
+// purgeOldActionLogsGo deletes action_log entries older than the retention period in batches, so a
+// large backlog doesn't hold a single long write lock. Returns the number of removed entries.
+func purgeOldActionLogsGo(dao *daos.Dao) (int64, error) {
+	retentionDays := getActionLogRetentionDaysGo()
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	const batchSize = 500
+	cutoff := time.Now().UTC().AddDate(0, 0, -retentionDays).Format(timeLayoutFull)
+
+	var removed int64
+	for {
+		result, err := dao.DB().Delete("action_log", dbx.NewExp(
+			"id IN (SELECT id FROM action_log WHERE timestamp < {:cutoff} LIMIT {:batchSize})",
+			dbx.Params{"cutoff": cutoff, "batchSize": batchSize},
+		)).Execute()
+		if err != nil {
+			return removed, fmt.Errorf("failed to delete old action_log entries: %w", err)
+		}
+		affected, _ := result.RowsAffected()
+		removed += affected
+		if affected < batchSize {
+			break
+		}
+	}
+	log.Printf("Action log retention: removed %d entries older than %d days.", removed, retentionDays)
+	return removed, nil
+}
+
+// getActionLogDedupeWindowGo returns how far back logActionGo looks for an identical "assigned" entry
+// (ACTION_LOG_DEDUPE_SECONDS, default 0 = dedupe disabled).
+func getActionLogDedupeWindowGo() time.Duration {
+	value := strings.TrimSpace(os.Getenv("ACTION_LOG_DEDUPE_SECONDS"))
+	if value == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		log.Printf("Warning: invalid ACTION_LOG_DEDUPE_SECONDS '%s'. Dedupe disabled.", value)
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// isDuplicateAssignedActionGo reports whether an "assigned" entry for the same worker_id and date was
+// already logged within the dedupe window, e.g. when two triggers ran ensureDailyAssignmentGo concurrently.
+func isDuplicateAssignedActionGo(dao *daos.Dao, details map[string]interface{}) bool {
+	window := getActionLogDedupeWindowGo()
+	if window <= 0 || details == nil {
+		return false
+	}
+	var count int
+	err := dao.RecordQuery("action_log").
+		Select("count(*)").
+		AndWhere(dbx.HashExp{"action_type": "assigned"}).
+		AndWhere(dbx.NewExp("timestamp >= {:since}", dbx.Params{"since": time.Now().UTC().Add(-window).Format(timeLayoutFull)})).
+		AndWhere(dbx.NewExp("json_extract(details, '$.worker_id') = {:workerId}", dbx.Params{"workerId": fmt.Sprint(details["worker_id"])})).
+		AndWhere(dbx.NewExp("json_extract(details, '$.date') = {:date}", dbx.Params{"date": fmt.Sprint(details["date"])})).
+		Row(&count)
+	if err != nil {
+		log.Printf("Error checking for duplicate 'assigned' action: %v", err)
+		return false
+	}
+	return count > 0
+}
+
+func logActionGo(dao *daos.Dao, actionType string, details map[string]interface{}) error {
+	if actionType == "assigned" && isDuplicateAssignedActionGo(dao, details) {
+		log.Printf("Skipping duplicate 'assigned' action log for worker %v on %v.", details["worker_id"], details["date"])
+		return nil
+	}
+
+	actionLogCollection, err := dao.FindCollectionByNameOrId("action_log")
+	if err != nil {
+		logActionFallbackGo(actionType, details)
+		return fmt.Errorf("failed to find action_log collection: %w", err)
+	}
+
+	record := models.NewRecord(actionLogCollection)
+	record.Set("action_type", actionType)
+	record.Set("timestamp", time.Now().UTC().Format(timeLayoutFull)) // Use timeLayoutFull
+
+	if details != nil {
+		detailsJSON, jsonErr := json.Marshal(details)
+		if jsonErr != nil {
+			log.Printf("Error marshalling details for action log '%s': %v", actionType, jsonErr)
+			record.Set("details", fmt.Sprintf(`{"error": "failed to marshal details: %s"}`, jsonErr.Error()))
+		} else {
+			record.Set("details", string(detailsJSON))
+		}
+	}
+
+	if err := dao.SaveRecord(record); err != nil {
+		log.Printf("Error saving action_log record for action '%s': %v", actionType, err)
+		logActionFallbackGo(actionType, details)
+		return fmt.Errorf("failed to save action_log record: %w", err)
+	}
+	return nil
+}
+
+// logActionFallbackGo writes an action that couldn't be stored in action_log to the server log as a
+// single JSON line, so the audit trail isn't lost entirely.
+func logActionFallbackGo(actionType string, details map[string]interface{}) {
+	entry, err := json.Marshal(map[string]interface{}{
+		"timestamp":   time.Now().UTC().Format(timeLayoutFull),
+		"action_type": actionType,
+		"details":     details,
+	})
+	if err != nil {
+		log.Printf("action_log fallback: action_type=%s (details not serializable: %v)", actionType, err)
+		return
+	}
+	log.Printf("action_log fallback: %s", entry)
+}
+
+func main() {
+	app := pocketbase.New()
+	configureAppGo(app)
+
+	if err := app.Start(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// configureAppGo registers every hook of the app: the model hooks that keep derived data in sync and, once
+// the server starts, the data setup, the API routes and the background jobs. Tests call the steps directly.
+func configureAppGo(app core.App) {
+	getAdminPassGo() // resolve ADMIN_PASS/ADMIN_PASS_FILE once at startup
+	notifierGo.app = app
+
+	// Keep the workers cache in sync no matter how a worker is changed (API, admin UI, hooks).
+	invalidateWorkersCache := func(e *core.ModelEvent) error {
+		workersCacheGo.invalidate()
+		return nil
+	}
+	app.OnModelAfterCreate("workers").Add(invalidateWorkersCache)
+	app.OnModelAfterUpdate("workers").Add(invalidateWorkersCache)
+	app.OnModelAfterDelete("workers").Add(invalidateWorkersCache)
+
+	// Records created without a roster join their worker's roster, or the default one (workers, or a
+	// worker from before rosters existed), so code that predates rosters keeps working unchanged.
+	app.OnModelBeforeCreate("workers", "assignments", "assignment_queue").Add(func(e *core.ModelEvent) error {
+		record, ok := e.Model.(*models.Record)
+		if !ok || record.GetString("roster_id") != "" {
+			return nil
+		}
+		if workerID := record.GetString("worker_id"); workerID != "" {
+			if worker, err := workersCacheGo.get(e.Dao, workerID); err == nil && worker != nil && worker.GetString("roster_id") != "" {
+				record.Set("roster_id", worker.GetString("roster_id"))
+				return nil
+			}
+		}
+		if roster, err := findRosterGo(e.Dao, defaultRosterName); err == nil && roster != nil {
+			record.Set("roster_id", roster.Id)
+		}
+		return nil
+	})
+
+	// Likewise records created without a coverage cover the whole day, so the (roster, day, half) index of
+	// assignments also holds for writers that predate half days.
+	app.OnModelBeforeCreate("assignments", "assignment_queue").Add(func(e *core.ModelEvent) error {
+		if record, ok := e.Model.(*models.Record); ok && record.GetString("coverage") == "" {
+			record.Set("coverage", coverageFull)
+		}
+		return nil
+	})
+
+	// Drop queue items of workers that can no longer be assigned, however the worker was changed.
+	app.OnModelAfterUpdate("workers").Add(func(e *core.ModelEvent) error {
+		worker, ok := e.Model.(*models.Record)
+		if !ok || !worker.GetBool("inactive") {
+			return nil
+		}
+		if err := cleanQueueForWorkerGo(e.Dao, worker, "inactive"); err != nil {
+			log.Printf("Error cleaning queue for inactive worker %s: %v", worker.Id, err)
+		}
+		return nil
+	})
+	// Workers with history can't be deleted: assignments.worker_id is required and doesn't cascade, and
+	// dropping the history would skew everyone's stats. They are deactivated instead. The model hook covers
+	// every code path; the request hook turns the admin API's generic 400 into a 409 that says why.
+	app.OnRecordBeforeDeleteRequest("workers").Add(func(e *core.RecordDeleteEvent) error {
+		return workerDeleteConflictGo(app.Dao(), e.Record)
+	})
+	app.OnModelBeforeDelete("workers").Add(func(e *core.ModelEvent) error {
+		worker, ok := e.Model.(*models.Record)
+		if !ok {
+			return nil
+		}
+		return workerDeleteConflictGo(e.Dao, worker)
+	})
+	app.OnModelBeforeDelete("workers").Add(func(e *core.ModelEvent) error {
+		worker, ok := e.Model.(*models.Record)
+		if !ok {
+			return nil
+		}
+		return cleanQueueForWorkerGo(e.Dao, worker, "deleted")
+	})
+
+	// Auto-queued days are planned for the roster as it was, so they are planned again when it changes.
+	replanAutoQueue := func(dao *daos.Dao, rosterIDs ...string) {
+		for _, rosterID := range rosterIDs {
+			if err := replanAutoQueueGo(dao, rosterID); err != nil {
+				log.Printf("Error replanning auto queue items after a worker change: %v", err)
+			}
+		}
+	}
+	replanAutoQueueForWorker := func(e *core.ModelEvent) error {
+		if worker, ok := e.Model.(*models.Record); ok {
+			replanAutoQueue(e.Dao, worker.GetString("roster_id"))
+		}
+		return nil
+	}
+	app.OnModelAfterCreate("workers").Add(replanAutoQueueForWorker)
+	app.OnModelAfterDelete("workers").Add(replanAutoQueueForWorker)
+
+	// Leave a tombstone so incremental worker lists can report the deletion.
+	app.OnModelAfterDelete("workers").Add(func(e *core.ModelEvent) error {
+		worker, ok := e.Model.(*models.Record)
+		if !ok {
+			return nil
+		}
+		collection, err := e.Dao.FindCollectionByNameOrId("deleted_workers")
+		if err != nil {
+			log.Printf("Warning: no tombstone recorded for deleted worker %s: %v", worker.Id, err)
+			return nil
+		}
+		tombstone := models.NewRecord(collection)
+		tombstone.Set("worker_id", worker.Id)
+		tombstone.Set("name", worker.GetString("name"))
+		tombstone.Set("roster_id", worker.GetString("roster_id"))
+		if err := e.Dao.SaveRecord(tombstone); err != nil {
+			log.Printf("Error recording tombstone for deleted worker %s: %v", worker.Id, err)
+		}
+		return nil
+	})
+	app.OnModelAfterUpdate("workers").Add(func(e *core.ModelEvent) error {
+		worker, ok := e.Model.(*models.Record)
+		if !ok {
+			return nil
+		}
+		// Most updates only move last_assigned_date, which the top-up already accounts for.
+		original := worker.OriginalCopy()
+		changed := false
+		for _, field := range autoQueueWorkerFields {
+			changed = changed || original.GetString(field) != worker.GetString(field)
+		}
+		if !changed {
+			return nil
+		}
+		rosterIDs := []string{worker.GetString("roster_id")}
+		if previous := original.GetString("roster_id"); previous != rosterIDs[0] {
+			rosterIDs = append(rosterIDs, previous)
+		}
+		replanAutoQueue(e.Dao, rosterIDs...)
+		return nil
+	})
+
+	// Push assignment changes to realtime subscribers, whichever code path wrote them.
+	broadcastAssignment := func(action string) func(e *core.ModelEvent) error {
+		return func(e *core.ModelEvent) error {
+			if assignment, ok := e.Model.(*models.Record); ok {
+				broadcastAssignmentGo(app, action, assignment)
+			}
+			return nil
+		}
+	}
+	app.OnModelAfterCreate("assignments").Add(broadcastAssignment("create"))
+	app.OnModelAfterUpdate("assignments").Add(broadcastAssignment("update"))
+	app.OnModelAfterDelete("assignments").Add(broadcastAssignment("delete"))
+
+	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
+		if err := setupDataGo(app); err != nil {
+			return err
+		}
+		registerRoutesGo(app, e)
+		startBackgroundJobsGo(app)
+		return nil
+	})
+}
+
+// setupDataGo creates or updates the collections, migrates old data and seeds the settings, the default
+// roster and the initial workers.
+func setupDataGo(app core.App) error {
+	dao := app.Dao()
+
+	if err := checkSQLiteTuningGo(dao); err != nil {
+		log.Printf("SQLite tuning error: %v", err)
+	}
+
+	// --- Define Collections ---
+	// Problems are collected rather than returned immediately so the boot log lists every broken
+	// collection/field at once instead of stopping at the first one.
+	var setupErrs []error
+
+	if err := ensureCollection(dao, rostersCollectionSpecGo()); err != nil {
+		setupErrs = append(setupErrs, err)
+	}
+	rostersCollection, _ := dao.FindCollectionByNameOrId("rosters")
+	if rostersCollection == nil || rostersCollection.Id == "" {
+		log.Println("Critical error: 'rosters' collection could not be initialized.")
+		return errors.Join(append(setupErrs, errors.New("rosters collection not found and could not be created"))...)
+	}
+	if err := ensureCollection(dao, workersCollectionSpecGo(rostersCollection.Id)); err != nil {
+		setupErrs = append(setupErrs, err)
+	}
+	workersCollection, _ := dao.FindCollectionByNameOrId("workers")
+	if workersCollection == nil || workersCollection.Id == "" {
+		log.Println("Critical error: 'workers' collection could not be initialized.")
+		setupErrs = append(setupErrs, errors.New("workers collection not found and could not be created"))
+	} else {
+		for _, spec := range []collectionSpec{
+			assignmentsCollectionSpecGo(workersCollection.Id, rostersCollection.Id),
+			assignmentQueueCollectionSpecGo(workersCollection.Id, rostersCollection.Id),
+			recurringAssignmentsCollectionSpecGo(workersCollection.Id),
+		} {
+			if err := ensureCollection(dao, spec); err != nil {
+				setupErrs = append(setupErrs, err)
+			}
+		}
+	}
+
+	if err := ensureCollection(dao, settingsCollectionSpecGo()); err != nil {
+		setupErrs = append(setupErrs, err)
+	}
+	if err := ensureCollection(dao, blackoutDatesCollectionSpecGo()); err != nil {
+		setupErrs = append(setupErrs, err)
+	}
+
+	// The audit trail is nice to have but shouldn't keep the rotation from running.
+	if err := ensureCollection(dao, actionLogCollectionSpecGo()); err != nil {
+		log.Printf("Collection setup error: %v", err)
+	}
+	if _, err := dao.FindCollectionByNameOrId("action_log"); err != nil {
+		log.Println("Warning: 'action_log' collection is unavailable. Actions will be written to the server log instead.")
+	}
+	// Only incremental worker lists need the tombstones.
+	if err := ensureCollection(dao, deletedWorkersCollectionSpecGo()); err != nil {
+		log.Printf("Collection setup error: %v", err)
+	}
+
+	if len(setupErrs) > 0 {
+		for _, err := range setupErrs {
+			log.Printf("Collection setup error: %v", err)
+		}
+		return fmt.Errorf("collection setup failed with %d problem(s): %w", len(setupErrs), errors.Join(setupErrs...))
+	}
+
+	// Assignments created before the source field existed can't be attributed reliably.
+	if _, err := dao.DB().Update("assignments", dbx.Params{"source": "unknown"}, dbx.NewExp("source = '' OR source IS NULL")).Execute(); err != nil {
+		log.Printf("Error migrating assignments without a source: %v", err)
+	}
+	// Everything from before coverage existed covers the whole day. The assignments index relies on it.
+	for _, collection := range []string{"assignments", "assignment_queue"} {
+		if _, err := dao.DB().Update(collection, dbx.Params{"coverage": coverageFull}, dbx.NewExp("coverage = '' OR coverage IS NULL")).Execute(); err != nil {
+			log.Printf("Error migrating %s without a coverage: %v", collection, err)
+		}
+	}
+
+	if err := normalizeStoredDatesGo(dao); err != nil {
+		log.Printf("Error normalizing stored dates: %v", err)
+	}
+
+	if err := ensureSettingsRecordGo(dao); err != nil {
+		log.Printf("Error seeding settings: %v", err)
+	}
+
+	if err := ensureDefaultRosterGo(dao); err != nil {
+		log.Printf("Roster setup error: %v", err)
+		return err
+	}
+
+	// Needs every assignment in a roster first. Older installs may hold two assignments for a day, which
+	// keeps the index out until one of them is deleted; the app-side checks still apply meanwhile.
+	if err := ensureCollectionIndexesGo(dao, assignmentsCollectionSpecGo(workersCollection.Id, rostersCollection.Id)); err != nil {
+		log.Printf("Index setup error: %v. Delete the duplicate assignments and restart.", err)
+	}
+
+	if err := bootstrapAdminGo(app); err != nil {
+		log.Printf("Error bootstrapping admin: %v", err)
+	}
+
+	if err := enforceQueueMaxDaysGo(dao); err != nil {
+		log.Printf("Queue validation error: %v", err)
+		return err
+	}
+	if _, err := repairQueueOrderGo(dao); err != nil {
+		log.Printf("Error repairing queue order: %v", err)
+	}
+
+	// --- Seed Initial Workers ---
+	if workersCollection != nil && workersCollection.Id != "" {
+		workerNames := []string{"keromag", "megatorg", "baby-ch"}
+		for _, workerName := range workerNames {
+			var existingRecord models.Record   // Important to declare it to receive the result
+			err := dao.RecordQuery("workers"). // Using dao which is app.Dao()
+								AndWhere(dbx.NewExp("LOWER(name) = LOWER({:workerName})", dbx.Params{"workerName": workerName})).
+								Limit(1).
+								One(&existingRecord) // Use One to fetch into existingRecord
+
+			if err == nil && existingRecord.Id != "" {
+				log.Printf("Worker '%s' already exists. Skipping.", workerName)
+				continue
+			}
+			// Check specifically for "no rows" or other "not found" variations
+			if err != nil && !(errors.Is(err, sql.ErrNoRows) || strings.Contains(strings.ToLower(err.Error()), "no record found") || strings.Contains(strings.ToLower(err.Error()), "no rows in result set")) {
+				log.Printf("Error checking if worker '%s' exists: %v", workerName, err)
+				continue
+			}
+			// If err is sql.ErrNoRows (or similar) or (err == nil && existingRecord.Id is empty), proceed to create
+			log.Printf("Worker '%s' does not exist or error was 'no rows'. Creating...", workerName)
+			record := models.NewRecord(workersCollection)
+			record.Set("name", workerName)
+			if errSave := dao.SaveRecord(record); errSave != nil {
+				log.Printf("Error seeding worker '%s': %v", workerName, errSave)
+			} else {
+				log.Printf("Worker '%s' seeded successfully.", workerName)
+			}
+		}
+	} else {
+		log.Println("'workers' collection not found or invalid, cannot seed workers.")
+	}
+
+	return nil
+}
+
+// registerRoutesGo adds the /api/dishduty routes to the server's router.
+func registerRoutesGo(app core.App, e *core.ServeEvent) {
+	dao := app.Dao()
+
+	// --- API Routes ---
+
+	// Compress the dishduty responses (calendar and assignment ranges get large) for clients that
+	// accept gzip; small bodies aren't worth the overhead and PocketBase's own routes are left alone.
+	e.Router.Use(middleware.GzipWithConfig(middleware.GzipConfig{
+		Skipper: func(c echo.Context) bool {
+			return !strings.HasPrefix(c.Request().URL.Path, "/api/dishduty/")
+		},
+		MinLength: 1024,
+	}))
+	if auditRequestBodiesEnabledGo() {
+		e.Router.Use(auditRequestBodyMiddlewareGo(dao))
+	}
+
+	// GET /api/dishduty/rosters
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/rosters",
+		Handler: func(c echo.Context) error {
+			records := []*models.Record{}
+			if err := dao.RecordQuery("rosters").OrderBy("created ASC", "id ASC").All(&records); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				log.Printf("Error fetching rosters: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to fetch rosters.", err)
+			}
+			rosters := make([]map[string]interface{}, 0, len(records))
+			for _, record := range records {
+				rosters = append(rosters, rosterEntryGo(record))
+			}
+			return c.JSON(http.StatusOK, rosters)
+		},
+	})
+
+	// POST /api/dishduty/rosters
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/dishduty/rosters",
+		Handler: func(c echo.Context) error {
+			requestData := struct {
+				Name          string `json:"name"`
+				AdminPassword string `json:"admin_password"`
+			}{}
+			if err := c.Bind(&requestData); err != nil {
+				return apis.NewBadRequestError("Failed to parse request data.", err)
+			}
+			if err := requireAdminGo(c, requestData.AdminPassword); err != nil {
+				return err
+			}
+			name := strings.TrimSpace(requestData.Name)
+			if name == "" {
+				return apis.NewBadRequestError("name is required.", nil)
+			}
+			existing, err := findRosterGo(dao, name)
+			if err != nil {
+				log.Printf("Error looking up roster '%s': %v", name, err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to create roster.", err)
+			}
+			if existing != nil {
+				return c.JSON(http.StatusConflict, map[string]interface{}{"error": fmt.Sprintf("Roster '%s' already exists.", existing.GetString("name"))})
+			}
+
+			collection, err := dao.FindCollectionByNameOrId("rosters")
+			if err != nil {
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to create roster.", err)
+			}
+			roster := models.NewRecord(collection)
+			roster.Set("name", name)
+			if err := dao.SaveRecord(roster); err != nil {
+				log.Printf("Error creating roster '%s': %v", name, err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to create roster.", err)
+			}
+			logActionGo(dao, "roster_created", map[string]interface{}{"roster_id": roster.Id, "name": name})
+			return c.JSON(http.StatusCreated, rosterEntryGo(roster))
+		},
+	})
+
+	// PATCH /api/dishduty/rosters/:id
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPatch,
+		Path:   "/api/dishduty/rosters/:id",
+		Handler: func(c echo.Context) error {
+			requestData := struct {
+				Name          *string `json:"name"`
+				Inactive      *bool   `json:"inactive"`
+				AdminPassword string  `json:"admin_password"`
+			}{}
+			if err := c.Bind(&requestData); err != nil {
+				return apis.NewBadRequestError("Failed to parse request data.", err)
+			}
+			if err := requireAdminGo(c, requestData.AdminPassword); err != nil {
+				return err
+			}
+			roster, err := findRosterGo(dao, c.PathParam("id"))
+			if err != nil {
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to look up roster.", err)
+			}
+			if roster == nil {
+				return apis.NewNotFoundError("Not Found: Roster not found.", nil)
+			}
+
+			changes := map[string]interface{}{}
+			if requestData.Name != nil {
+				name := strings.TrimSpace(*requestData.Name)
+				if name == "" {
+					return apis.NewBadRequestError("name must not be empty.", nil)
+				}
+				if name != roster.GetString("name") {
+					// Endpoints fall back to the default roster by name.
+					if roster.GetString("name") == defaultRosterName {
+						return apis.NewBadRequestError("The default roster can't be renamed.", nil)
+					}
+					if existing, err := findRosterGo(dao, name); err == nil && existing != nil && existing.Id != roster.Id {
+						return c.JSON(http.StatusConflict, map[string]interface{}{"error": fmt.Sprintf("Roster '%s' already exists.", existing.GetString("name"))})
+					}
+					changes["name"] = name
+				}
+			}
+			if requestData.Inactive != nil {
+				changes["inactive"] = *requestData.Inactive
+			}
+			for field, value := range changes {
+				roster.Set(field, value)
+			}
+			if err := dao.SaveRecord(roster); err != nil {
+				log.Printf("Error updating roster %s: %v", roster.Id, err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to update roster.", err)
+			}
+			logActionGo(dao, "roster_updated", map[string]interface{}{"roster_id": roster.Id, "name": roster.GetString("name"), "changes": changes})
+			return c.JSON(http.StatusOK, rosterEntryGo(roster))
+		},
+	})
+
+	// GET /api/dishduty/workers
+	// With ?since=<timestamp> only workers created or updated after it are returned, together with the ids
+	// of workers deleted since then and a server_time to pass as the next since.
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/workers", // New dedicated endpoint
+		Handler: func(c echo.Context) error {
+			serverTime := types.NowDateTime() // taken before reading, so nothing written meanwhile is skipped next time
+			var since types.DateTime
+			if sinceStr := c.QueryParam("since"); sinceStr != "" {
+				parsed, err := types.ParseDateTime(sinceStr)
+				if err != nil || parsed.IsZero() {
+					return apis.NewBadRequestError("since must be a timestamp, e.g. 2024-01-31T12:00:00Z or a previous server_time.", nil)
+				}
+				since = parsed
+			}
+			// Every worker unless ?roster= narrows the list down.
+			rosterID := ""
+			if c.QueryParam("roster") != "" {
+				roster, err := resolveRosterGo(dao, c)
+				if err != nil {
+					return err
+				}
+				rosterID = roster.Id
+			}
+			records, err := rosterWorkersGo(app.Dao(), rosterID)
+			if err != nil {
+				log.Printf("Error fetching workers for API: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to fetch workers.", err)
+			}
+			sortWorkersByNameGo(records) // Sort by name ascending
+			workers := make([]map[string]any, 0, len(records))
+			for _, record := range records {
+				if !since.IsZero() && !record.Updated.Time().After(since.Time()) {
+					continue
+				}
+				workers = append(workers, publicWorkerGo(record))
+			}
+			if since.IsZero() {
+				return c.JSON(http.StatusOK, workers)
+			}
+
+			tombstones := []*models.Record{}
+			err = dao.RecordQuery("deleted_workers").
+				AndWhere(rosterExpGo(rosterID)).
+				AndWhere(dbx.NewExp("created > {:since}", dbx.Params{"since": since.String()})).
+				OrderBy("created ASC").
+				All(&tombstones)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				log.Printf("Error fetching deleted workers: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to fetch workers.", err)
+			}
+			deleted := make([]string, 0, len(tombstones))
+			for _, tombstone := range tombstones {
+				deleted = append(deleted, tombstone.GetString("worker_id"))
+			}
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"workers":     workers,
+				"deleted":     deleted,
+				"server_time": serverTime.String(),
+			})
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			// No admin auth middleware here, this is public
+		},
+	})
+
+	// PATCH /api/dishduty/workers/:id
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPatch,
+		Path:   "/api/dishduty/workers/:id",
+		Handler: func(c echo.Context) error {
+			requestData := struct {
+				Inactive           *bool     `json:"inactive"`
+				DisplayName        *string   `json:"display_name"`
+				Color              *string   `json:"color"`
+				Email              *string   `json:"email"`
+				TelegramChatID     *string   `json:"telegram_chat_id"`
+				MaxConsecutiveDays *int      `json:"max_consecutive_days"`
+				Priority           *int      `json:"priority"`
+				WeekendOK          *bool     `json:"weekend_ok"`
+				NotifyChannels     *[]string `json:"notify_channels"` // [] means every configured channel
+				Roster             *string   `json:"roster"`          // id or name; moving drops the worker's queue items
+				AdminPassword      string    `json:"admin_password"`
+			}{}
+			if err := c.Bind(&requestData); err != nil {
+				return apis.NewBadRequestError("Failed to parse request data.", err)
+			}
+			if err := requireAdminGo(c, requestData.AdminPassword); err != nil {
+				return err
+			}
+			worker, err := dao.FindRecordById("workers", c.PathParam("id"))
+			if err != nil {
+				return apis.NewNotFoundError("Not Found: Worker not found.", err)
+			}
+
+			// Reuse the import validation for the presentation and contact fields.
+			check := WorkerImportRow{Name: worker.GetString("name"), DisplayName: worker.GetString("display_name"), Color: worker.GetString("color"), Email: worker.GetString("email")}
+			if requestData.DisplayName != nil {
+				check.DisplayName = *requestData.DisplayName
+			}
+			if requestData.Color != nil {
+				check.Color = *requestData.Color
+			}
+			if requestData.Email != nil {
+				check.Email = *requestData.Email
+			}
+			if problem := validateWorkerImportRowGo(&check); problem != "" {
+				return apis.NewBadRequestError(problem, nil)
+			}
+			if requestData.MaxConsecutiveDays != nil && *requestData.MaxConsecutiveDays < 0 {
+				return apis.NewBadRequestError("max_consecutive_days must not be negative.", nil)
+			}
+			if requestData.NotifyChannels != nil {
+				for _, channel := range *requestData.NotifyChannels {
+					if !list.ExistInSlice(channel, notificationChannels) {
+						return apis.NewBadRequestError(fmt.Sprintf("notify_channels may only contain %s.", strings.Join(notificationChannels, ", ")), nil)
+					}
+				}
+			}
+			var roster *models.Record
+			if requestData.Roster != nil {
+				roster, err = findRosterGo(dao, strings.TrimSpace(*requestData.Roster))
+				if err != nil {
+					return apis.NewApiError(http.StatusInternalServerError, "Failed to look up roster.", err)
+				}
+				if roster == nil {
+					return apis.NewBadRequestError(fmt.Sprintf("Roster '%s' not found.", *requestData.Roster), nil)
+				}
+			}
+
+			changes := map[string]interface{}{}
+			if requestData.Inactive != nil {
+				changes["inactive"] = *requestData.Inactive
+			}
+			if requestData.DisplayName != nil {
+				changes["display_name"] = check.DisplayName
+			}
+			if requestData.Color != nil {
+				changes["color"] = check.Color
+			}
+			if requestData.Email != nil {
+				changes["email"] = check.Email
+			}
+			if requestData.TelegramChatID != nil {
+				changes["telegram_chat_id"] = strings.TrimSpace(*requestData.TelegramChatID)
+			}
+			if requestData.MaxConsecutiveDays != nil {
+				changes["max_consecutive_days"] = *requestData.MaxConsecutiveDays
+			}
+			if requestData.Priority != nil {
+				changes["priority"] = *requestData.Priority
+			}
+			if requestData.WeekendOK != nil {
+				changes["weekend_ok"] = *requestData.WeekendOK
+			}
+			if requestData.NotifyChannels != nil {
+				changes["notify_channels"] = list.ToUniqueStringSlice(*requestData.NotifyChannels)
+			}
+			moved := roster != nil && roster.Id != worker.GetString("roster_id")
+			if moved {
+				changes["roster_id"] = roster.Id
+			}
+			for field, value := range changes {
+				worker.Set(field, value)
+			}
+			if err := dao.SaveRecord(worker); err != nil {
+				log.Printf("Error updating worker %s: %v", worker.Id, err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to update worker.", err)
+			}
+			// Queue items were planned in the old roster's rotation.
+			if moved {
+				if err := cleanQueueForWorkerGo(dao, worker, "moved"); err != nil {
+					log.Printf("Error cleaning queue for moved worker %s: %v", worker.Id, err)
+				}
+			}
+			delete(changes, "email")
+			delete(changes, "telegram_chat_id")
+			logActionGo(dao, "worker_updated", map[string]interface{}{"worker_id": worker.Id, "worker_name": worker.GetString("name"), "changes": changes})
+			return c.JSON(http.StatusOK, publicWorkerGo(worker))
+		},
+	})
+
+	// POST /api/dishduty/workers/:id/weekend-ok
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/dishduty/workers/:id/weekend-ok",
+		Handler: func(c echo.Context) error {
+			requestData := struct {
+				WeekendOK     *bool  `json:"weekend_ok"` // omitted flips the current value
+				AdminPassword string `json:"admin_password"`
+			}{}
+			if err := c.Bind(&requestData); err != nil {
+				return apis.NewBadRequestError("Failed to parse request data.", err)
+			}
+			if err := requireAdminGo(c, requestData.AdminPassword); err != nil {
+				return err
+			}
+			worker, err := dao.FindRecordById("workers", c.PathParam("id"))
+			if err != nil {
+				return apis.NewNotFoundError("Not Found: Worker not found.", err)
+			}
+
+			weekendOK := !worker.GetBool("weekend_ok")
+			if requestData.WeekendOK != nil {
+				weekendOK = *requestData.WeekendOK
+			}
+			worker.Set("weekend_ok", weekendOK)
+			if err := dao.SaveRecord(worker); err != nil {
+				log.Printf("Error updating worker %s: %v", worker.Id, err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to update worker.", err)
+			}
+			logActionGo(dao, "worker_updated", map[string]interface{}{"worker_id": worker.Id, "worker_name": worker.GetString("name"), "changes": map[string]interface{}{"weekend_ok": weekendOK}})
+			return c.JSON(http.StatusOK, publicWorkerGo(worker))
+		},
+	})
+
+	// POST /api/dishduty/workers/:id/selection-bias
+	// Sets a temporary selection_bias (days, positive = picked sooner) until a date; 0 clears it.
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/dishduty/workers/:id/selection-bias",
+		Handler: func(c echo.Context) error {
+			requestData := struct {
+				SelectionBias int    `json:"selection_bias"`
+				Until         string `json:"until"` // YYYY-MM-DD, last day the bias applies
+				AdminPassword string `json:"admin_password"`
+			}{}
+			if err := c.Bind(&requestData); err != nil {
+				return apis.NewBadRequestError("Failed to parse request data.", err)
+			}
+			if err := requireAdminGo(c, requestData.AdminPassword); err != nil {
+				return err
+			}
+			if requestData.SelectionBias < -selectionBiasMaxDays || requestData.SelectionBias > selectionBiasMaxDays {
+				return apis.NewBadRequestError(fmt.Sprintf("selection_bias must be between %d and %d.", -selectionBiasMaxDays, selectionBiasMaxDays), nil)
+			}
+			until, untilYMD := "", ""
+			if requestData.SelectionBias != 0 {
+				untilDate, err := parseYMDToGoTime(requestData.Until)
+				if err != nil {
+					return apis.NewBadRequestError("until is required with a non-zero selection_bias. Use YYYY-MM-DD.", nil)
+				}
+				if untilDate.Before(getTodayStartGo()) {
+					return apis.NewBadRequestError("until must not be in the past.", nil)
+				}
+				until, untilYMD = untilDate.Format(timeLayoutFull), untilDate.Format(timeLayoutYMD)
+			}
+			worker, err := dao.FindRecordById("workers", c.PathParam("id"))
+			if err != nil {
+				return apis.NewNotFoundError("Not Found: Worker not found.", err)
+			}
+
+			worker.Set("selection_bias", requestData.SelectionBias)
+			worker.Set("selection_bias_until", until)
+			if err := dao.SaveRecord(worker); err != nil {
+				log.Printf("Error updating worker %s: %v", worker.Id, err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to update worker.", err)
+			}
+			logActionGo(dao, "worker_updated", map[string]interface{}{"worker_id": worker.Id, "worker_name": worker.GetString("name"), "changes": map[string]interface{}{"selection_bias": requestData.SelectionBias, "selection_bias_until": untilYMD}})
+			return c.JSON(http.StatusOK, publicWorkerGo(worker))
+		},
+	})
+
+	// POST /api/dishduty/workers/repair-last-assigned
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/dishduty/workers/repair-last-assigned",
+		Handler: func(c echo.Context) error {
+			requestData := struct {
+				AdminPassword string `json:"admin_password"`
+			}{}
+			if err := c.Bind(&requestData); err != nil {
+				return apis.NewBadRequestError("Failed to parse request data.", err)
+			}
+			if err := requireAdminGo(c, requestData.AdminPassword); err != nil {
+				return err
+			}
+			checked, repairs, err := repairLastAssignedDatesGo(dao)
+			if err != nil {
+				log.Printf("Error repairing last_assigned_date: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to repair last_assigned_date; nothing was changed.", err)
+			}
+			logActionGo(dao, "last_assigned_repaired", map[string]interface{}{"workers_checked": checked, "workers_updated": len(repairs), "repairs": repairs})
+			return c.JSON(http.StatusOK, map[string]interface{}{"workers_checked": checked, "workers_updated": len(repairs), "repairs": repairs})
+		},
+	})
+
+	// POST /api/dishduty/workers/import
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/dishduty/workers/import",
+		Handler: func(c echo.Context) error {
+			body, err := io.ReadAll(io.LimitReader(c.Request().Body, 1<<20))
+			if err != nil {
+				return apis.NewBadRequestError("Failed to read request body.", err)
+			}
+			rows, bodyPassword, err := parseWorkerImportGo(c.Request().Header.Get(echo.HeaderContentType), body)
+			if err := requireAdminGo(c, bodyPassword); err != nil {
+				return err
+			}
+			if err != nil {
+				return apis.NewBadRequestError("Invalid import data.", err)
+			}
+
+			existing, err := workersCacheGo.all(dao)
+			if err != nil {
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to fetch workers.", err)
+			}
+			seen := map[string]bool{}
+			for _, worker := range existing {
+				seen[strings.ToLower(worker.GetString("name"))] = true
+			}
+
+			results := make([]WorkerImportResult, 0, len(rows))
+			counts := map[string]int{"created": 0, "skipped": 0, "error": 0}
+			err = dao.RunInTransaction(func(txDao *daos.Dao) error {
+				workersCollection, err := txDao.FindCollectionByNameOrId("workers")
+				if err != nil {
+					return err
+				}
+				for i, row := range rows {
+					result := WorkerImportResult{Row: i + 1, Name: row.Name}
+					if problem := validateWorkerImportRowGo(&row); problem != "" {
+						result.Status, result.Error = "error", problem
+					} else if seen[strings.ToLower(row.Name)] {
+						result.Name, result.Status = row.Name, "skipped"
+					} else {
+						record := models.NewRecord(workersCollection)
+						record.Set("name", row.Name)
+						record.Set("display_name", row.DisplayName)
+						record.Set("color", row.Color)
+						record.Set("email", row.Email)
+						if err := txDao.SaveRecord(record); err != nil {
+							return fmt.Errorf("row %d: %w", i+1, err)
+						}
+						seen[strings.ToLower(row.Name)] = true
+						result.Name, result.Status, result.WorkerID = row.Name, "created", record.Id
+					}
+					counts[result.Status]++
+					results = append(results, result)
+				}
+				return nil
+			})
+			if err != nil {
+				log.Printf("Error importing workers: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to import workers; nothing was imported.", err)
+			}
+			logActionGo(dao, "workers_imported", map[string]interface{}{"created": counts["created"], "skipped": counts["skipped"], "errors": counts["error"]})
+			return c.JSON(http.StatusOK, map[string]interface{}{"results": results, "created": counts["created"], "skipped": counts["skipped"], "errors": counts["error"]})
+		},
+	})
+
+	// POST /api/dishduty/queue/add
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/dishduty/queue/add",
+		Handler: func(c echo.Context) error {
+			var req AddToQueueRequest // Use the new struct type
+
+			if err := c.Bind(&req); err != nil {
+				log.Printf("Error binding request for add to queue: %v", err)
+				return apis.NewBadRequestError("Invalid request body.", err)
+			}
+
+			if err := requireAdminGo(c, req.AdminPassword); err != nil {
+				return err
+			}
+
+			// Validate DurationDays
+			maxDays := getQueueMaxDaysGo()
+			if req.DurationDays < 1 || req.DurationDays > maxDays {
+				log.Printf("Validation error: duration_days %d out of range", req.DurationDays)
+				return apis.NewBadRequestError(fmt.Sprintf("duration_days must be between 1 and %d.", maxDays), nil)
+			}
+			if req.Coverage == "" {
+				req.Coverage = coverageFull
+			}
+			if !list.ExistInSlice(req.Coverage, coverageValues) {
+				return apis.NewBadRequestError("coverage must be one of full, am or pm.", nil)
+			}
+			half := ""
+			if req.Coverage != coverageFull {
+				half = req.Coverage
+				if req.DurationDays != 1 {
+					return apis.NewBadRequestError("A half-day item (coverage am or pm) must last exactly 1 day.", nil)
+				}
+			}
+
+			var worker *models.Record
+			var errFindWorker error
+			// Note: The AddToQueueRequest struct only has WorkerID. If WorkerName is also needed,
+			// the struct and frontend payload should be updated. For now, assuming WorkerID is primary.
+			if req.WorkerID != "" {
+				worker, errFindWorker = workersCacheGo.get(dao, req.WorkerID)
+			} else {
+				// If WorkerID is not provided, and WorkerName was an option, this logic would need adjustment.
+				// Based on current struct, WorkerID is expected.
+				return apis.NewBadRequestError("Bad Request: worker_id is required.", nil)
+			}
+			if errFindWorker != nil || worker == nil {
+				log.Printf("Error finding worker (id: %s): %v", req.WorkerID, errFindWorker)
+				return apis.NewNotFoundError("Not Found: Worker not found.", errFindWorker)
+			}
+
+			// The item goes into the worker's roster, and only that roster's queue is checked.
+			rosterID := worker.GetString("roster_id")
+
+			if problem := validateExternalRefGo(&req.ExternalRef); problem != "" {
+				return apis.NewBadRequestError(problem, nil)
+			}
+			if req.ExternalRef != "" {
+				ownerID, err := findExternalRefOwnerGo(dao, req.ExternalRef, "")
+				if err != nil {
+					log.Printf("Error checking external_ref '%s': %v", req.ExternalRef, err)
+					return apis.NewApiError(http.StatusInternalServerError, "Could not validate queue entry.", err)
+				}
+				if ownerID != "" {
+					return c.JSON(http.StatusConflict, map[string]interface{}{
+						"error":       "This external_ref is already in use.",
+						"conflict_id": ownerID,
+					})
+				}
+			}
+
+			if !getSettingsGo(dao).AllowDuplicateQueueEntries {
+				existingItem := &models.Record{}
+				err := dao.RecordQuery("assignment_queue").
+					AndWhere(dbx.HashExp{"worker_id": worker.Id}).
+					OrderBy(queueOrderColumns...).
+					Limit(1).
+					One(existingItem)
+				if err != nil && !errors.Is(err, sql.ErrNoRows) {
+					log.Printf("Error checking existing queue items for worker %s: %v", worker.Id, err)
+					return apis.NewApiError(http.StatusInternalServerError, "Could not validate queue entry.", err)
+				}
+				if err == nil && existingItem.Id != "" {
+					return c.JSON(http.StatusConflict, map[string]interface{}{
+						"error":       "This worker already has a pending queue item.",
+						"conflict_id": existingItem.Id,
+					})
+				}
+			}
+
+			if maxItems := getQueueMaxItemsGo(); maxItems > 0 {
+				queueLength, err := countQueueItemsGo(dao, rosterID)
+				if err != nil {
+					log.Printf("Error counting queue items: %v", err)
+					return apis.NewApiError(http.StatusInternalServerError, "Could not validate queue entry.", err)
+				}
+				if queueLength >= maxItems {
+					return c.JSON(http.StatusConflict, map[string]interface{}{
+						"error":           fmt.Sprintf("The queue is full (%d of %d items).", queueLength, maxItems),
+						"queue_length":    queueLength,
+						"queue_max_items": maxItems,
+					})
+				}
+			}
+
+			var startDateYMD string
+			order := 1
+			todayYMD := getTodayYMDGo()
+			pinned := req.PinnedStartDate != ""
+
+			pinnedItems, errPinned := findPinnedQueueItemsGo(dao, rosterID)
+			if errPinned != nil {
+				log.Printf("Error fetching pinned queue items: %v", errPinned)
+				return apis.NewApiError(http.StatusInternalServerError, "Could not validate queue span.", errPinned)
+			}
+			lastQueueItem, _ := dao.FindFirstRecordByFilter("assignment_queue", "1=1 ORDER BY {{order}} DESC")
+			if lastQueueItem != nil {
+				order = lastQueueItem.GetInt("order") + 1
+			}
+			// Unpinned items follow the last unpinned item; pinned ones sit outside the contiguous run.
+			lastUnpinnedItem := &models.Record{}
+			errLastUnpinned := dao.RecordQuery("assignment_queue").
+				AndWhere(dbx.NewExp("pinned IS NOT TRUE")).
+				AndWhere(rosterExpGo(rosterID)).
+				OrderBy("order DESC", "start_date DESC", "id DESC").
+				Limit(1).
+				One(lastUnpinnedItem)
+			if errLastUnpinned == nil && lastUnpinnedItem.Id != "" {
+				lastQueueItemStartDate := lastUnpinnedItem.GetDateTime("start_date").Time()
+				lastQueueItemDuration := lastUnpinnedItem.GetInt("duration_days")
+				lastQueueItemEndDate := formatDateToYMDGo(lastQueueItemStartDate.AddDate(0, 0, lastQueueItemDuration-1))
+				startDateYMD, _ = addDaysToYMDGo(lastQueueItemEndDate, 1)
+				if half != "" && halfDayGo(lastUnpinnedItem) == otherHalfGo(half) {
+					// Takes the other half of the last item's day; the overlap check below refuses it if that's taken.
+					startDateYMD = lastQueueItemEndDate
+				}
+			} else {
+				startDateYMD = getQueueAnchorYMDGo(dao, rosterID)
+			}
+
+			parsedStartDate, _ := parseYMDToGoTime(startDateYMD)
+			parsedToday, _ := parseYMDToGoTime(todayYMD)
+			if parsedStartDate.Before(parsedToday) {
+				startDateYMD = todayYMD
+			}
+
+			finalStartDateForRecord, errParseFinal := time.Parse(timeLayoutYMD, startDateYMD)
+			if errParseFinal != nil {
+				log.Printf("Error parsing final startDateYMD '%s' for queue: %v", startDateYMD, errParseFinal)
+				return apis.NewApiError(http.StatusInternalServerError, "Error formatting start date for DB.", errParseFinal)
+			}
+
+			if pinned {
+				pinnedStart, errPinnedDate := parseYMDToGoTime(req.PinnedStartDate)
+				if errPinnedDate != nil {
+					return apis.NewBadRequestError("Invalid pinned_start_date. Use YYYY-MM-DD.", errPinnedDate)
+				}
+				if pinnedStart.Before(parsedToday) {
+					return apis.NewBadRequestError("pinned_start_date must not be in the past.", nil)
+				}
+				pinnedEnd := pinnedStart.AddDate(0, 0, req.DurationDays-1)
+				for _, item := range pinnedItems {
+					itemStart, itemEnd := queueItemSpanGo(item)
+					if half != "" && halfDayGo(item) == otherHalfGo(half) {
+						continue
+					}
+					if !pinnedStart.After(itemEnd) && !itemStart.After(pinnedEnd) {
+						return c.JSON(http.StatusConflict, map[string]interface{}{
+							"error":       "The requested span overlaps a pinned queue item.",
+							"conflict_id": item.Id,
+						})
+					}
+				}
+				finalStartDateForRecord = pinnedStart
+			} else {
+				finalStartDateForRecord = flowAroundPinnedGo(pinnedItems, finalStartDateForRecord, req.DurationDays)
+				var conflictID string
+				var errOverlap error
+				finalStartDateForRecord, conflictID, errOverlap = resolveQueueSpanOverlapGo(dao, rosterID, finalStartDateForRecord, req.DurationDays, half)
+				if errOverlap != nil {
+					log.Printf("Error checking queue span overlap: %v", errOverlap)
+					return apis.NewApiError(http.StatusInternalServerError, "Could not validate queue span.", errOverlap)
+				}
+				if conflictID != "" {
+					return c.JSON(http.StatusConflict, map[string]interface{}{
+						"error":       "The requested span overlaps an existing queue item.",
+						"conflict_id": conflictID,
+					})
+				}
+			}
+			startDateYMD = finalStartDateForRecord.Format(timeLayoutYMD)
+
+			if maxConsecutive := worker.GetInt("max_consecutive_days"); maxConsecutive > 0 {
+				if req.DurationDays > maxConsecutive {
+					return apis.NewBadRequestError(fmt.Sprintf("duration_days exceeds this worker's limit of %d consecutive days.", maxConsecutive), nil)
+				}
+				daysBefore, errRun := countConsecutiveDutyDaysBeforeGo(dao, worker.Id, finalStartDateForRecord, maxConsecutive)
+				if errRun != nil {
+					log.Printf("Error checking consecutive duty days for worker %s: %v", worker.Id, errRun)
+					return apis.NewApiError(http.StatusInternalServerError, "Could not validate queue span.", errRun)
+				}
+				if daysBefore+req.DurationDays > maxConsecutive {
+					return apis.NewBadRequestError(fmt.Sprintf("This span would put the worker on duty %d days in a row, over their limit of %d.", daysBefore+req.DurationDays, maxConsecutive), nil)
+				}
+			}
+
+			queueCollection, _ := dao.FindCollectionByNameOrId("assignment_queue")
+			newQueueRecord := models.NewRecord(queueCollection)
+			newQueueRecord.Set("worker_id", worker.Id)
+			newQueueRecord.Set("start_date", finalStartDateForRecord.Format(timeLayoutFull))
+			newQueueRecord.Set("duration_days", req.DurationDays) // Use req.DurationDays
+			newQueueRecord.Set("order", order)
+			newQueueRecord.Set("pinned", pinned)
+			newQueueRecord.Set("external_ref", req.ExternalRef)
+			newQueueRecord.Set("roster_id", rosterID)
+			newQueueRecord.Set("source", queueSourceManual)
+			newQueueRecord.Set("coverage", req.Coverage)
+
+			if err := dao.SaveRecord(newQueueRecord); err != nil {
+				log.Printf("Error saving new queue record: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Could not add worker to queue.", err)
+			}
+			if pinned {
+				// Move unpinned items out of the newly pinned span.
+				if _, _, err := recomputeQueueStartDatesGo(dao); err != nil {
+					log.Printf("Error recomputing queue start dates after pinning: %v", err)
+				}
+			}
+			logActionGo(dao, "added_to_queue", map[string]interface{}{"worker_id": worker.Id, "worker_name": worker.GetString("name"), "duration_days": req.DurationDays, "start_date": startDateYMD, "order": order, "pinned": pinned, "coverage": req.Coverage})
+			return c.JSON(http.StatusCreated, map[string]interface{}{"message": "Worker added to queue.", "data": newQueueRecord})
+		},
+	})
+
+	// GET /api/dishduty/current-assignee
+	// By default this still makes sure today is assigned before answering, for compatibility with
+	// existing clients. Monitoring should pass ?ensure=false to read without creating an assignment or
+	// consuming the queue. The plan is to flip the default to false and leave assigning to the scheduler.
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/current-assignee",
+		Handler: func(c echo.Context) error {
+			ensure := true
+			if value := c.QueryParam("ensure"); value != "" {
+				parsed, err := strconv.ParseBool(value)
+				if err != nil {
+					return apis.NewBadRequestError("ensure must be true or false.", nil)
+				}
+				ensure = parsed
+			}
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+			lookup := readCurrentDutiesGo
+			if ensure {
+				lookup = findCurrentDutiesGo
+			}
+			duties, err := lookup(dao, roster.Id)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			if len(duties) == 0 {
+				// Return 404 or a specific structure indicating N/A
+				return c.JSON(http.StatusNotFound, map[string]string{"message": "No assignee found for today."})
+			}
+			dateYMD, ok := recordDateYMDGo(duties[0].Assignment, "date")
+			if !ok {
+				return c.JSON(http.StatusNotFound, map[string]string{"message": "No assignee found for today."})
+			}
+
+			// The top-level fields describe the first assignee, as before half days existed; a shared day
+			// lists both halves in assignees.
+			assignees := make([]map[string]interface{}, 0, len(duties))
+			for _, duty := range duties {
+				assignees = append(assignees, map[string]interface{}{
+					"worker_id":   duty.Worker.Id,
+					"worker_name": duty.Worker.GetString("name"),
+					"source":      duty.Assignment.GetString("source"),
+					"coverage":    recordCoverageGo(duty.Assignment),
+					"proof_url":   getProofURLGo(duty.Assignment),
+				})
+			}
+			response := map[string]interface{}{"date": dateYMD, "assignees": assignees}
+			for key, value := range assignees[0] {
+				response[key] = value
+			}
+			return c.JSON(http.StatusOK, response)
+		},
+	})
+
+	// GET /api/dishduty/current-assignee/badge.svg
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/current-assignee/badge.svg",
+		Handler: func(c echo.Context) error {
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+			label := "nobody"
+			color := ""
+			duties, err := findCurrentDutiesGo(dao, roster.Id)
+			if err != nil {
+				label = "unavailable"
+			} else if len(duties) > 0 {
+				label = currentDutyNamesGo(duties, true)
+				if len(duties) == 1 {
+					color = duties[0].Worker.GetString("color")
+				}
+			}
+			c.Response().Header().Set("Cache-Control", "public, max-age=300")
+			return c.Blob(http.StatusOK, "image/svg+xml; charset=utf-8", []byte(renderAssigneeBadgeGo(label, color)))
+		},
+	})
+
+	// GET /api/dishduty/current-assignee.txt
+	// Just the worker's name as plain text, for shell scripts and low-power displays. Only reads.
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/current-assignee.txt",
+		Handler: func(c echo.Context) error {
+			c.Response().Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+			roster, err := resolveRosterGo(dao, c)
+			if apiErr := (*apis.ApiError)(nil); errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+				return c.String(http.StatusNotFound, "unknown roster")
+			} else if err != nil {
+				return c.String(http.StatusInternalServerError, "unavailable")
+			}
+			duties, err := readCurrentDutiesGo(dao, roster.Id)
+			if err != nil {
+				return c.String(http.StatusInternalServerError, "unavailable")
+			}
+			if len(duties) == 0 {
+				return c.String(http.StatusOK, "nobody")
+			}
+			return c.String(http.StatusOK, currentDutyNamesGo(duties, false))
+		},
+	})
+
+	// GET /api/dishduty/assignments
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/assignments",
+		Handler: func(c echo.Context) error {
+			startDateStr := c.QueryParam("start_date")
+			endDateStr := c.QueryParam("end_date")
+			if startDateStr == "" || endDateStr == "" {
+				return apis.NewBadRequestError(queryParamsHintGo(c, "start_date and end_date query parameters are required.", rangeQueryParams), nil)
+			}
+			dateRegex := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+			if !dateRegex.MatchString(startDateStr) || !dateRegex.MatchString(endDateStr) {
+				return apis.NewBadRequestError(queryParamsHintGo(c, "Invalid date format. Use YYYY-MM-DD.", rangeQueryParams), nil)
+			}
+			if problem := strictQueryParamsProblemGo(c, rangeQueryParams); problem != "" {
+				return apis.NewBadRequestError(problem, nil)
+			}
+
+			startDateTime, errStart := time.Parse(timeLayoutYMD, startDateStr)
+			endDateTime, errEnd := time.Parse(timeLayoutYMD, endDateStr)
+			if errStart != nil || errEnd != nil {
+				return apis.NewBadRequestError("Invalid date.", nil)
+			}
+			// Bounded like /calendar, so a wide range can't load the whole history at once.
+			if endDateTime.Before(startDateTime) {
+				return apis.NewBadRequestError("end_date must not be before start_date.", nil)
+			}
+			if maxDays := getMaxCalendarDaysGo(); daysBetweenGo(startDateTime, endDateTime)+1 > maxDays {
+				return apis.NewBadRequestError(fmt.Sprintf("Date range must not exceed %d days.", maxDays), nil)
+			}
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+
+			records := []*models.Record{}
+			err = dao.RecordQuery("assignments").
+				AndWhere(dayRangeExpGo("date", startDateTime, endDateTime)).
+				AndWhere(rosterExpGo(roster.Id)).
+				OrderBy("date DESC").
+				All(&records)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				log.Printf("Error fetching assignments: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to fetch assignments.", err)
+			}
+			result := []map[string]interface{}{}
+			for _, record := range records {
+				dateYMD, ok := recordDateYMDGo(record, "date")
+				if !ok {
+					continue
+				}
+				workerName := getWorkerNameGo(dao, record.GetString("worker_id"))
+				result = append(result, map[string]interface{}{
+					"id": record.Id, "worker_name": workerName,
+					"date": dateYMD, "status": record.GetString("status"),
+					"weight": getAssignmentWeightGo(record), "source": record.GetString("source"),
+					"proof_url": getProofURLGo(record), "external_ref": record.GetString("external_ref"),
+				})
+			}
+			return c.JSON(http.StatusOK, result)
+		},
+	})
+
+	// GET /api/dishduty/assignments/by-date/:date
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/assignments/by-date/:date",
+		Handler: func(c echo.Context) error {
+			dateStr := c.PathParam("date")
+			dateRegex := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+			if !dateRegex.MatchString(dateStr) {
+				return apis.NewBadRequestError("Invalid date format. Use YYYY-MM-DD.", nil)
+			}
+			if _, err := parseYMDToGoTime(dateStr); err != nil {
+				return apis.NewBadRequestError("Invalid date.", err)
+			}
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+
+			assignment, err := findAssignmentForDateGo(dao, roster.Id, dateStr)
+			if err != nil {
+				log.Printf("Error fetching assignment for %s: %v", dateStr, err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to fetch assignment.", err)
+			}
+			if assignment == nil {
+				return apis.NewNotFoundError("No assignment found for this date.", nil)
+			}
+
+			if _, ok := recordDateYMDGo(assignment, "date"); !ok {
+				return apis.NewNotFoundError("No assignment found for this date.", nil)
+			}
+			return c.JSON(http.StatusOK, assignmentDetailsGo(dao, assignment))
+		},
+	})
+
+	// GET /api/dishduty/assignments/by-ref/:ref
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/assignments/by-ref/:ref",
+		Handler: func(c echo.Context) error {
+			ref, err := url.PathUnescape(c.PathParam("ref"))
+			if err != nil || strings.TrimSpace(ref) == "" {
+				return apis.NewBadRequestError("Invalid ref.", err)
+			}
+			assignment, err := findAssignmentByExternalRefGo(dao, strings.TrimSpace(ref))
+			if err != nil {
+				log.Printf("Error fetching assignment by external_ref '%s': %v", ref, err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to fetch assignment.", err)
+			}
+			if assignment == nil {
+				return apis.NewNotFoundError("Not Found: No assignment with this ref.", nil)
+			}
+			return c.JSON(http.StatusOK, assignmentDetailsGo(dao, assignment))
+		},
+	})
+
+	// GET /api/dishduty/assignments/:id/audit
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/assignments/:id/audit",
+		Handler: func(c echo.Context) error {
+			if err := requireAdminScopeGo(c, c.Request().Header.Get(adminPasswordHeader), adminScopeReadonly); err != nil {
+				return err
+			}
+			assignmentID := c.PathParam("id")
+			// Entries name the assignment directly, or (like "assigned" and "reassigned_coverage") only
+			// its worker and date. An assignment replaced after not_done is deleted, so only its id is left.
+			match := dbx.Or(
+				dbx.NewExp("json_extract(details, '$.assignment_id') = {:assignmentId}", dbx.Params{"assignmentId": assignmentID}),
+				dbx.NewExp("json_extract(details, '$.original_assignment_id') = {:assignmentId}", dbx.Params{"assignmentId": assignmentID}),
+			)
+			assignment, err := dao.FindRecordById("assignments", assignmentID)
+			if err == nil {
+				workerID := assignment.GetString("worker_id")
+				match = dbx.Or(match, dbx.And(
+					dbx.NewExp("json_extract(details, '$.date') = {:date}", dbx.Params{"date": assignment.GetDateTime("date").Time().Format(timeLayoutYMD)}),
+					dbx.Or(
+						dbx.NewExp("json_extract(details, '$.worker_id') = {:workerId}", dbx.Params{"workerId": workerID}),
+						dbx.NewExp("json_extract(details, '$.original_worker_id') = {:workerId}", dbx.Params{"workerId": workerID}),
+						dbx.NewExp("json_extract(details, '$.previous_worker_id') = {:workerId}", dbx.Params{"workerId": workerID}),
+					),
+				))
+			}
+			entries := []*models.Record{}
+			errEntries := dao.RecordQuery("action_log").
+				AndWhere(match).
+				OrderBy("timestamp ASC", "created ASC").
+				Limit(500).
+				All(&entries)
+			if errEntries != nil && !errors.Is(errEntries, sql.ErrNoRows) {
+				log.Printf("Error fetching audit trail for assignment %s: %v", assignmentID, errEntries)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to fetch audit trail.", errEntries)
+			}
+			if assignment == nil && len(entries) == 0 {
+				return apis.NewNotFoundError("Assignment not found.", err)
+			}
+			return c.JSON(http.StatusOK, entries)
+		},
+	})
+
+	// GET /api/dishduty/recent?limit=10
+	// The latest assignments of any status, newest first, for widgets that don't want a date range.
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/recent",
+		Handler: func(c echo.Context) error {
+			if problem := strictQueryParamsProblemGo(c, []string{"limit", "roster"}); problem != "" {
+				return apis.NewBadRequestError(problem, nil)
+			}
+			limit := recentDefaultLimit
+			if value := c.QueryParam("limit"); value != "" {
+				parsed, err := strconv.Atoi(value)
+				if err != nil || parsed < 1 || parsed > recentMaxLimit {
+					return apis.NewBadRequestError(fmt.Sprintf("limit must be between 1 and %d.", recentMaxLimit), nil)
+				}
+				limit = parsed
+			}
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+
+			records := []*models.Record{}
+			err = dao.RecordQuery("assignments").AndWhere(rosterExpGo(roster.Id)).OrderBy("date DESC", "id DESC").Limit(int64(limit)).All(&records)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				log.Printf("Error fetching recent assignments: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to fetch recent assignments.", err)
+			}
+			// Worker names come from the workers cache, so this is one query however many rows are returned.
+			entries := make([]map[string]interface{}, 0, len(records))
+			for _, record := range records {
+				entries = append(entries, assignmentDetailsGo(dao, record))
+			}
+			return c.JSON(http.StatusOK, entries)
+		},
+	})
+
+	// GET /api/dishduty/assignments/today
+	// Unlike /current-assignee this only reads: it never creates today's assignment.
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/assignments/today",
+		Handler: func(c echo.Context) error {
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+			todayYMD := getTodayYMDGo()
+			assignment, err := findAssignmentForDateGo(dao, roster.Id, todayYMD)
+			if err != nil {
+				log.Printf("Error fetching assignment for %s: %v", todayYMD, err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to fetch assignment.", err)
+			}
+			if assignment == nil {
+				return apis.NewNotFoundError("No assignment found for today.", nil)
+			}
+			if _, ok := recordDateYMDGo(assignment, "date"); !ok {
+				return apis.NewNotFoundError("No assignment found for today.", nil)
+			}
+			return c.JSON(http.StatusOK, assignmentDetailsGo(dao, assignment))
+		},
+	})
+
+	// PATCH /api/dishduty/assignments/:id/status
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPatch,
+		Path:   "/api/dishduty/assignments/:id/status",
+		Handler: func(c echo.Context) error {
+			assignmentID := c.PathParam("id")
+			requestData := struct {
+				Status        string   `json:"status"`
+				Weight        *float64 `json:"weight"`
+				Note          *string  `json:"note"` // stored as done_note
+				AdminPassword string   `json:"admin_password"`
+			}{}
+			if err := c.Bind(&requestData); err != nil {
+				return apis.NewBadRequestError("Failed to parse request data.", err)
+			}
+			if err := requireAdminGo(c, requestData.AdminPassword); err != nil {
+				return err
+			}
+			validStatuses := map[string]bool{"assigned": true, "done": true, "not_done": true}
+			if !validStatuses[requestData.Status] {
+				return apis.NewBadRequestError("Invalid status value.", nil)
+			}
+			if requestData.Weight != nil && *requestData.Weight <= 0 {
+				return apis.NewBadRequestError("weight must be greater than 0.", nil)
+			}
+			note := ""
+			if requestData.Note != nil {
+				note = strings.TrimSpace(*requestData.Note)
+				if len(note) > doneNoteMaxLength {
+					return apis.NewBadRequestError(fmt.Sprintf("note must be at most %d characters.", doneNoteMaxLength), nil)
+				}
+			}
+			// The save only goes through if nobody (e.g. the daily reassignment) changed the record since it
+			// was read; otherwise it is re-read and applied again.
+			var assignment *models.Record
+			var err error
+			for attempt := 1; ; attempt++ {
+				assignment, err = dao.FindRecordById("assignments", assignmentID)
+				if err != nil {
+					return apis.NewNotFoundError("Assignment not found.", err)
+				}
+				// Taking on a queued day goes through /accept, which also consumes its queue item.
+				if assignment.GetString("status") == "pending_acceptance" && requestData.Status != "not_done" {
+					return c.JSON(http.StatusConflict, map[string]interface{}{
+						"error": "This assignment is waiting for acceptance.",
+						"hint":  "Accept it via POST /api/dishduty/assignments/:id/accept first.",
+					})
+				}
+				if requestData.Status == "done" {
+					if problem := doneProofProblemGo(assignment, false, note); problem != "" {
+						return apis.NewApiError(http.StatusUnprocessableEntity, problem, nil)
+					}
+				}
+				assignment.Set("status", requestData.Status)
+				if requestData.Weight != nil {
+					assignment.Set("weight", *requestData.Weight)
+				}
+				if requestData.Note != nil {
+					assignment.Set("done_note", note)
+				}
+				err = dao.RunInTransaction(func(txDao *daos.Dao) error {
+					if err := claimRecordGo(txDao, assignment); err != nil {
+						return err
+					}
+					return txDao.SaveRecord(assignment)
+				})
+				if !errors.Is(err, errConcurrentUpdate) {
+					break
+				}
+				if attempt == concurrentUpdateAttempts {
+					return c.JSON(http.StatusConflict, map[string]interface{}{
+						"error": "The assignment kept changing while it was being updated. Please try again.",
+					})
+				}
+			}
+			if err != nil {
+				log.Printf("Error updating assignment status: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to update status.", err)
+			}
+			response := map[string]interface{}{"message": "Assignment status updated."}
+			if requestData.Status == "not_done" {
+				workerName := getWorkerNameGo(dao, assignment.GetString("worker_id"))
+				assignmentYMD := assignment.GetDateTime("date").Time().Format(timeLayoutYMD)
+				logActionGo(dao, "marked_not_done", map[string]interface{}{
+					"assignment_id": assignment.Id,
+					"worker_id":     assignment.GetString("worker_id"),
+					"worker_name":   workerName,
+					"date":          assignmentYMD,
+				})
+				if err := checkRepeatOffenderGo(dao, assignment.GetString("worker_id")); err != nil {
+					log.Printf("Error checking for repeated not_done: %v", err)
+				}
+				// Hand today's duty to someone else right away rather than on the next daily check.
+				if assignmentYMD == getTodayYMDGo() && !strings.EqualFold(strings.TrimSpace(os.Getenv("REASSIGN_ON_NOT_DONE_IMMEDIATELY")), "false") {
+					duties, err := findCurrentDutiesGo(dao, assignment.GetString("roster_id"))
+					if err != nil {
+						log.Printf("Error reassigning today's duty after not_done: %v", err)
+					} else if len(duties) > 0 {
+						// On a shared day the replacement is the one for the same half.
+						replacement := duties[0]
+						for _, duty := range duties {
+							if recordCoverageGo(duty.Assignment) == recordCoverageGo(assignment) {
+								replacement = duty
+							}
+						}
+						response["new_assignee"] = map[string]interface{}{
+							"assignment_id": replacement.Assignment.Id,
+							"worker_id":     replacement.Worker.Id,
+							"worker_name":   replacement.Worker.GetString("name"),
+							"source":        replacement.Assignment.GetString("source"),
+							"coverage":      recordCoverageGo(replacement.Assignment),
+						}
+					}
+				}
+			}
+			return c.JSON(http.StatusOK, response)
+		},
+	})
+
+	// POST /api/dishduty/assignments/:id/accept and /decline
+	// Answer a pending_acceptance assignment (REQUIRE_QUEUE_ACCEPTANCE). The worker answers with the token
+	// from their announcement (body field or ?token=), an admin with the admin password. Declining today's
+	// assignment hands the day to the next queue item right away.
+	for action, accept := range map[string]bool{"accept": true, "decline": false} {
+		e.Router.AddRoute(echo.Route{
+			Method: http.MethodPost,
+			Path:   "/api/dishduty/assignments/:id/" + action,
+			Handler: func(c echo.Context) error {
+				requestData := struct {
+					Token         string `json:"token"`
+					AdminPassword string `json:"admin_password"`
+				}{}
+				if err := c.Bind(&requestData); err != nil {
+					return apis.NewBadRequestError("Failed to parse request data.", err)
+				}
+				if requestData.Token == "" {
+					requestData.Token = c.QueryParam("token")
+				}
+				assignment, err := dao.FindRecordById("assignments", c.PathParam("id"))
+				if requestData.Token == "" || !acceptanceTokenMatchesGo(assignment, requestData.Token) {
+					if err := requireAdminGo(c, requestData.AdminPassword); err != nil {
+						return err
+					}
+				}
+				if err != nil {
+					return apis.NewNotFoundError("Assignment not found.", err)
+				}
+				if assignment.GetString("status") != "pending_acceptance" {
+					return c.JSON(http.StatusConflict, map[string]string{"error": "This assignment is not waiting for acceptance."})
+				}
+
+				if accept {
+					if err := acceptQueueAssignmentGo(dao, assignment); err != nil {
+						log.Printf("Error accepting assignment %s: %v", assignment.Id, err)
+						return apis.NewApiError(http.StatusInternalServerError, "Failed to accept assignment.", err)
+					}
+					return c.JSON(http.StatusOK, assignmentDetailsGo(dao, assignment))
+				}
+				if err := declineQueueAssignmentGo(dao, assignment); err != nil {
+					log.Printf("Error declining assignment %s: %v", assignment.Id, err)
+					return apis.NewApiError(http.StatusInternalServerError, "Failed to decline assignment.", err)
+				}
+				response := map[string]interface{}{"message": "Assignment declined."}
+				if assignment.GetDateTime("date").Time().Format(timeLayoutYMD) == getTodayYMDGo() {
+					if err := ensureDailyAssignmentGo(dao); err != nil {
+						log.Printf("Error reassigning today's duty after a decline: %v", err)
+					}
+					if next, err := findAssignmentForDateGo(dao, assignment.GetString("roster_id"), getTodayYMDGo()); err == nil && next != nil {
+						response["new_assignment"] = assignmentDetailsGo(dao, next)
+					}
+				}
+				return c.JSON(http.StatusOK, response)
+			},
+		})
+	}
+
+	// POST /api/dishduty/today/reset
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/dishduty/today/reset",
+		Handler: func(c echo.Context) error {
+			requestData := struct {
+				AdminPassword string `json:"admin_password"`
+			}{}
+			if err := c.Bind(&requestData); err != nil {
+				return apis.NewBadRequestError("Failed to parse request data.", err)
+			}
+			if err := requireAdminGo(c, requestData.AdminPassword); err != nil {
+				return err
+			}
+
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+
+			todayYMD := getTodayYMDGo()
+			assignment, err := findAssignmentForDateGo(dao, roster.Id, todayYMD)
+			if err != nil {
+				log.Printf("Error fetching today's assignment: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to fetch today's assignment.", err)
+			}
+			if assignment == nil {
+				return apis.NewNotFoundError("Not Found: No assignment for today.", nil)
+			}
+			if assignment.GetString("status") == "done" {
+				return c.JSON(http.StatusConflict, map[string]interface{}{
+					"error": "Today's assignment is already done.",
+					"hint":  "Set its status back to 'assigned' via PATCH /api/dishduty/assignments/:id/status first.",
+				})
+			}
+
+			if err := resetTodayAssignmentGo(dao, assignment); err != nil {
+				log.Printf("Error resetting today's assignment %s: %v", assignment.Id, err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to reset today's assignment.", err)
+			}
+			// The re-run picks someone other than the worker just taken off, unless nobody else can take the day.
+			if getSettingsGo(dao).SnoozeUntil < todayYMD {
+				err := assignRosterDayGo(dao, roster, getTodayStartGo(), nil, assignment.GetString("worker_id"))
+				if errors.Is(err, errConcurrentUpdate) {
+					return c.JSON(http.StatusConflict, map[string]interface{}{
+						"error": "Today was assigned again while it was being reset.",
+					})
+				}
+				if err != nil {
+					log.Printf("Error re-running daily assignment after reset: %v", err)
+					return apis.NewApiError(http.StatusInternalServerError, "Failed to pick a new assignee for today.", err)
+				}
+				if _, err := topUpQueueGo(dao, roster.Id); err != nil {
+					log.Printf("Error topping up the queue of roster %s: %v", roster.GetString("name"), err)
+				}
+			}
+
+			response := map[string]interface{}{"previous": assignmentDetailsGo(dao, assignment), "assignment": nil}
+			if newAssignment, err := findAssignmentForDateGo(dao, roster.Id, todayYMD); err == nil && newAssignment != nil {
+				response["assignment"] = assignmentDetailsGo(dao, newAssignment)
+			}
+			return c.JSON(http.StatusOK, response)
+		},
+	})
+
+	// POST /api/dishduty/assign-today
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/dishduty/assign-today",
+		Handler: func(c echo.Context) error {
+			requestData := struct {
+				WorkerID      string `json:"worker_id"`
+				ExternalRef   string `json:"external_ref"`
+				AdminPassword string `json:"admin_password"`
+			}{}
+			if err := c.Bind(&requestData); err != nil {
+				return apis.NewBadRequestError("Failed to parse request data.", err)
+			}
+			if err := requireAdminGo(c, requestData.AdminPassword); err != nil {
+				return err
+			}
+			if requestData.WorkerID == "" {
+				return apis.NewBadRequestError("worker_id is required.", nil)
+			}
+			if problem := validateExternalRefGo(&requestData.ExternalRef); problem != "" {
+				return apis.NewBadRequestError(problem, nil)
+			}
+			worker, err := dao.FindRecordById("workers", requestData.WorkerID)
+			if err != nil {
+				return apis.NewNotFoundError("Not Found: Worker not found.", err)
+			}
+			if worker.GetBool("inactive") {
+				return apis.NewBadRequestError("Worker is inactive.", nil)
+			}
+
+			// The worker takes over today in their own roster.
+			todayStart := getTodayStartGo()
+			todayYMD := todayStart.Format(timeLayoutYMD)
+			assignment, err := findAssignmentForDateGo(dao, worker.GetString("roster_id"), todayYMD)
+			if err != nil {
+				log.Printf("Error fetching today's assignment: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to fetch today's assignment.", err)
+			}
+			previousWorkerID := ""
+			if assignment != nil {
+				if assignment.GetString("status") == "done" {
+					return c.JSON(http.StatusConflict, map[string]interface{}{
+						"error": "Today's assignment is already done.",
+						"hint":  "Set its status back to 'assigned' via PATCH /api/dishduty/assignments/:id/status first.",
+					})
+				}
+				previousWorkerID = assignment.GetString("worker_id")
+			} else {
+				assignmentsCollection, errColl := dao.FindCollectionByNameOrId("assignments")
+				if errColl != nil {
+					return apis.NewApiError(http.StatusInternalServerError, "Assignments collection not found.", errColl)
+				}
+				assignment = models.NewRecord(assignmentsCollection)
+				assignment.Set("date", todayStart.Format(timeLayoutFull))
+				assignment.Set("roster_id", worker.GetString("roster_id"))
+			}
+			if requestData.ExternalRef != "" {
+				ownerID, err := findExternalRefOwnerGo(dao, requestData.ExternalRef, assignment.Id)
+				if err != nil {
+					log.Printf("Error checking external_ref '%s': %v", requestData.ExternalRef, err)
+					return apis.NewApiError(http.StatusInternalServerError, "Failed to save assignment.", err)
+				}
+				if ownerID != "" {
+					return c.JSON(http.StatusConflict, map[string]interface{}{
+						"error":       "This external_ref is already in use.",
+						"conflict_id": ownerID,
+					})
+				}
+				assignment.Set("external_ref", requestData.ExternalRef)
+			}
+			err = dao.RunInTransaction(func(txDao *daos.Dao) error {
+				if previousWorkerID != "" && previousWorkerID != worker.Id {
+					// The displaced worker gets back the date this assignment had moved them off.
+					if err := restoreLastAssignedDateGo(txDao, previousWorkerID, todayStart, assignment.GetString("previous_last_assigned_date")); err != nil {
+						return err
+					}
+				}
+				if previousWorkerID != worker.Id {
+					assignment.Set("previous_last_assigned_date", worker.GetString("last_assigned_date"))
+				}
+				assignment.Set("worker_id", worker.Id)
+				assignment.Set("status", "assigned")
+				assignment.Set("weight", 1)
+				assignment.Set("source", "manual")
+				// The day no longer comes from the queue, e.g. when it was still waiting for acceptance.
+				assignment.Set("queue_item_id", "")
+				assignment.Set("acceptance_token", "")
+				if err := txDao.SaveRecord(assignment); err != nil {
+					return fmt.Errorf("failed to save assignment for %s: %w", todayYMD, err)
+				}
+				worker.Set("last_assigned_date", todayStart.Format(timeLayoutFull))
+				if err := txDao.SaveRecord(worker); err != nil {
+					return fmt.Errorf("failed to update last_assigned_date for worker %s: %w", worker.Id, err)
+				}
+				return nil
+			})
+			if err != nil {
+				log.Printf("Error saving manual assignment for %s: %v", todayYMD, err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to save assignment.", err)
+			}
+
+			details := map[string]interface{}{
+				"assignment_id": assignment.Id,
+				"worker_id":     worker.Id,
+				"worker_name":   worker.GetString("name"),
+				"date":          todayYMD,
+			}
+			if previousWorkerID != "" {
+				details["previous_worker_id"] = previousWorkerID
+				details["previous_worker_name"] = getWorkerNameGo(dao, previousWorkerID)
+			}
+			logActionGo(dao, "manual_assign", details)
+			go runOnAssignCommandGo(worker, todayYMD, "manual")
+			if previousWorkerID != worker.Id {
+				rosterName := defaultRosterName
+				if roster, err := findRosterGo(dao, worker.GetString("roster_id")); err == nil && roster != nil {
+					rosterName = roster.GetString("name")
+				}
+				go notifierGo.announce(worker, rosterName, "Dish duty today", announcementMessageGo(worker, todayYMD, "manual", "You're on dish duty today."))
+			}
+			return c.JSON(http.StatusOK, assignment)
+		},
+	})
+
+	// GET /api/dishduty/recurring
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/recurring",
+		Handler: func(c echo.Context) error {
+			rules := []*models.Record{}
+			err := dao.RecordQuery("recurring_assignments").OrderBy("weekday ASC", "priority ASC", "created ASC").All(&rules)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				log.Printf("Error fetching recurring assignments: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to fetch recurring assignments.", err)
+			}
+			entries := make([]RecurringAssignmentEntry, 0, len(rules))
+			for _, rule := range rules {
+				entries = append(entries, recurringAssignmentEntryGo(dao, rule))
+			}
+			return c.JSON(http.StatusOK, entries)
+		},
+	})
+
+	// POST /api/dishduty/recurring
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/dishduty/recurring",
+		Handler: func(c echo.Context) error {
+			var req RecurringAssignmentRequest
+			if err := c.Bind(&req); err != nil {
+				return apis.NewBadRequestError("Invalid request body.", err)
+			}
+			if err := requireAdminGo(c, req.AdminPassword); err != nil {
+				return err
+			}
+			req.Weekday = strings.ToLower(strings.TrimSpace(req.Weekday))
+			if !list.ExistInSlice(req.Weekday, weekdayNames) {
+				return apis.NewBadRequestError("Invalid weekday. Must be a lowercase English day name, e.g. 'monday'.", nil)
+			}
+			if req.WorkerID == "" {
+				return apis.NewBadRequestError("Bad Request: worker_id is required.", nil)
+			}
+			worker, err := workersCacheGo.get(dao, req.WorkerID)
+			if err != nil || worker == nil {
+				return apis.NewNotFoundError("Not Found: Worker not found.", err)
+			}
+
+			collection, err := dao.FindCollectionByNameOrId("recurring_assignments")
+			if err != nil {
+				return apis.NewApiError(http.StatusInternalServerError, "Could not create recurring assignment.", err)
+			}
+			rule := models.NewRecord(collection)
+			rule.Set("worker_id", worker.Id)
+			rule.Set("weekday", req.Weekday)
+			if req.Priority != nil {
+				rule.Set("priority", *req.Priority)
+			}
+			if err := dao.SaveRecord(rule); err != nil {
+				log.Printf("Error saving recurring assignment: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Could not create recurring assignment.", err)
+			}
+			logActionGo(dao, "recurring_rule_created", map[string]interface{}{"rule_id": rule.Id, "worker_id": worker.Id, "worker_name": worker.GetString("name"), "weekday": req.Weekday, "priority": rule.GetInt("priority")})
+			return c.JSON(http.StatusCreated, recurringAssignmentEntryGo(dao, rule))
+		},
+	})
+
+	// PATCH /api/dishduty/recurring/:id
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPatch,
+		Path:   "/api/dishduty/recurring/:id",
+		Handler: func(c echo.Context) error {
+			var req RecurringAssignmentRequest
+			if err := c.Bind(&req); err != nil {
+				return apis.NewBadRequestError("Invalid request body.", err)
+			}
+			if err := requireAdminGo(c, req.AdminPassword); err != nil {
+				return err
+			}
+			rule, err := dao.FindRecordById("recurring_assignments", c.PathParam("id"))
+			if err != nil {
+				return apis.NewNotFoundError("Recurring assignment not found.", err)
+			}
+			if req.Weekday != "" {
+				req.Weekday = strings.ToLower(strings.TrimSpace(req.Weekday))
+				if !list.ExistInSlice(req.Weekday, weekdayNames) {
+					return apis.NewBadRequestError("Invalid weekday. Must be a lowercase English day name, e.g. 'monday'.", nil)
+				}
+				rule.Set("weekday", req.Weekday)
+			}
+			if req.WorkerID != "" {
+				worker, err := workersCacheGo.get(dao, req.WorkerID)
+				if err != nil || worker == nil {
+					return apis.NewNotFoundError("Not Found: Worker not found.", err)
+				}
+				rule.Set("worker_id", worker.Id)
+			}
+			if req.Priority != nil {
+				rule.Set("priority", *req.Priority)
+			}
+			if err := dao.SaveRecord(rule); err != nil {
+				log.Printf("Error updating recurring assignment %s: %v", rule.Id, err)
+				return apis.NewApiError(http.StatusInternalServerError, "Could not update recurring assignment.", err)
+			}
+			entry := recurringAssignmentEntryGo(dao, rule)
+			logActionGo(dao, "recurring_rule_updated", map[string]interface{}{"rule_id": rule.Id, "worker_id": entry.WorkerID, "worker_name": entry.WorkerName, "weekday": entry.Weekday, "priority": entry.Priority})
+			return c.JSON(http.StatusOK, entry)
+		},
+	})
+
+	// DELETE /api/dishduty/recurring/:id
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodDelete,
+		Path:   "/api/dishduty/recurring/:id",
+		Handler: func(c echo.Context) error {
+			requestData := struct {
+				AdminPassword string `json:"admin_password"`
+			}{}
+			if err := c.Bind(&requestData); err != nil {
+				return apis.NewBadRequestError("Failed to parse request data.", err)
+			}
+			if err := requireAdminGo(c, requestData.AdminPassword); err != nil {
+				return err
+			}
+			rule, err := dao.FindRecordById("recurring_assignments", c.PathParam("id"))
+			if err != nil {
+				return apis.NewNotFoundError("Recurring assignment not found.", err)
+			}
+			entry := recurringAssignmentEntryGo(dao, rule)
+			if err := dao.DeleteRecord(rule); err != nil {
+				log.Printf("Error deleting recurring assignment %s: %v", rule.Id, err)
+				return apis.NewApiError(http.StatusInternalServerError, "Could not delete recurring assignment.", err)
+			}
+			logActionGo(dao, "recurring_rule_deleted", map[string]interface{}{"rule_id": entry.ID, "worker_id": entry.WorkerID, "worker_name": entry.WorkerName, "weekday": entry.Weekday})
+			return c.NoContent(http.StatusNoContent)
+		},
+	})
+
+	// POST /api/dishduty/assignments/:id/done
+	// Multipart variant of the status PATCH that marks the assignment done with an optional "proof" photo
+	// and "note".
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/dishduty/assignments/:id/done",
+		Handler: func(c echo.Context) error {
+			if err := requireAdminGo(c, c.FormValue("admin_password")); err != nil {
+				return err
+			}
+
+			note := strings.TrimSpace(c.FormValue("note"))
+			if len(note) > doneNoteMaxLength {
+				return apis.NewBadRequestError(fmt.Sprintf("note must be at most %d characters.", doneNoteMaxLength), nil)
+			}
+			data := map[string]any{"status": "done"}
+			if note != "" {
+				data["done_note"] = note
+			}
+			fileHeader, err := c.FormFile("proof")
+			if err != nil && !errors.Is(err, http.ErrMissingFile) {
+				return apis.NewBadRequestError("Failed to read the proof upload.", err)
+			}
+			if fileHeader != nil {
+				if fileHeader.Size > proofMaxBytes {
+					return apis.NewBadRequestError(fmt.Sprintf("proof must be at most %d MB.", proofMaxBytes>>20), nil)
+				}
+				upload, err := fileHeader.Open()
+				if err != nil {
+					return apis.NewBadRequestError("Failed to read the proof upload.", err)
+				}
+				head := make([]byte, 512)
+				n, _ := upload.Read(head)
+				upload.Close()
+				if contentType := http.DetectContentType(head[:n]); !list.ExistInSlice(contentType, proofMimeTypes) {
+					return apis.NewBadRequestError(fmt.Sprintf("proof must be an image (%s).", strings.Join(proofMimeTypes, ", ")), nil)
+				}
+			}
+
+			// Like the status PATCH, the save only goes through if nobody changed the assignment since it was
+			// read; otherwise it is re-read and applied again.
+			var assignment *models.Record
+			for attempt := 1; ; attempt++ {
+				assignment, err = dao.FindRecordById("assignments", c.PathParam("id"))
+				if err != nil {
+					return apis.NewNotFoundError("Assignment not found.", err)
+				}
+				if assignment.GetString("status") == "pending_acceptance" {
+					return c.JSON(http.StatusConflict, map[string]interface{}{
+						"error": "This assignment is waiting for acceptance.",
+						"hint":  "Accept it via POST /api/dishduty/assignments/:id/accept first.",
+					})
+				}
+				if problem := doneProofProblemGo(assignment, fileHeader != nil, note); problem != "" {
+					return apis.NewApiError(http.StatusUnprocessableEntity, problem, nil)
+				}
+				form := forms.NewRecordUpsert(app, assignment)
+				if err := form.LoadData(data); err != nil {
+					return apis.NewBadRequestError("Failed to load request data.", err)
+				}
+				if fileHeader != nil {
+					proof, err := filesystem.NewFileFromMultipart(fileHeader)
+					if err != nil {
+						return apis.NewBadRequestError("Failed to read the proof upload.", err)
+					}
+					if err := form.AddFiles("proof", proof); err != nil {
+						return apis.NewBadRequestError("Failed to attach proof.", err)
+					}
+				}
+				err = dao.RunInTransaction(func(txDao *daos.Dao) error {
+					if err := claimRecordGo(txDao, assignment); err != nil {
+						return err
+					}
+					form.SetDao(txDao)
+					return form.Submit()
+				})
+				if !errors.Is(err, errConcurrentUpdate) {
+					break
+				}
+				if attempt == concurrentUpdateAttempts {
+					return c.JSON(http.StatusConflict, map[string]interface{}{
+						"error": "The assignment kept changing while it was being updated. Please try again.",
+					})
+				}
+			}
+			if err != nil {
+				log.Printf("Error marking assignment %s done: %v", assignment.Id, err)
+				return apis.NewBadRequestError("Failed to mark assignment done.", err)
+			}
+			return c.JSON(http.StatusOK, map[string]interface{}{"message": "Assignment marked done.", "proof_url": getProofURLGo(assignment)})
+		},
+	})
+
+	// GET /api/dishduty/action-log
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/action-log",
+		Handler: func(c echo.Context) error {
+			if err := requireAdminScopeGo(c, c.Request().Header.Get(adminPasswordHeader), adminScopeReadonly); err != nil {
+				return err
+			}
+			records, err := dao.FindRecordsByFilter("action_log", "1=1", "-timestamp", 50, 0)
+			if err != nil {
+				log.Printf("Error fetching action log: %v", err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch action log."})
+			}
+			return c.JSON(http.StatusOK, records)
+		},
+	})
+
+	// GET /api/dishduty/calendar - MOVED HERE
+	// The format follows the Accept header (JSON, iCalendar or CSV); calendar.ics and calendar.csv force one.
+	calendarHandler := func(routeFormat string) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			format := routeFormat
+			if format == "" {
+				format = negotiateCalendarFormatGo(c.Request().Header.Get(echo.HeaderAccept))
+				c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+			}
+			if format == "" {
+				return c.JSON(http.StatusNotAcceptable, map[string]string{"error": "Supported formats: application/json, text/calendar, text/csv."})
+			}
+			startDateStr := c.QueryParam("start_date")
+			endDateStr := c.QueryParam("end_date")
+
+			if startDateStr == "" || endDateStr == "" {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": queryParamsHintGo(c, "start_date and end_date query parameters are required.", rangeQueryParams)})
+			}
+
+			dateRegex := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+			if !dateRegex.MatchString(startDateStr) || !dateRegex.MatchString(endDateStr) {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": queryParamsHintGo(c, "Invalid date format. Use YYYY-MM-DD.", rangeQueryParams)})
+			}
+			if problem := strictQueryParamsProblemGo(c, rangeQueryParams); problem != "" {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": problem})
+			}
+			rangeStart, errStart := parseYMDToGoTime(startDateStr)
+			rangeEnd, errEnd := parseYMDToGoTime(endDateStr)
+			if errStart != nil || errEnd != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid date."})
+			}
+			if rangeEnd.Before(rangeStart) {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "end_date must not be before start_date."})
+			}
+			maxCalendarDays := getMaxCalendarDaysGo()
+			if daysBetweenGo(rangeStart, rangeEnd)+1 > maxCalendarDays {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Date range must not exceed %d days.", maxCalendarDays)})
+			}
+
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+
+			responseData, err := buildCalendarGo(dao, roster.Id, rangeStart, rangeEnd)
+			if err != nil {
+				log.Printf("Error fetching calendar assignments: %v", err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch calendar assignments."})
+			}
+			switch format {
+			case calendarFormatICS:
+				return c.Blob(http.StatusOK, "text/calendar; charset=utf-8", renderCalendarICSGo(responseData))
+			case calendarFormatCSV:
+				return c.Blob(http.StatusOK, "text/csv; charset=utf-8", renderCalendarCSVGo(responseData))
+			}
+			return c.JSON(http.StatusOK, responseData)
+		}
+	}
+	for path, format := range map[string]string{
+		"/api/dishduty/calendar":     "",
+		"/api/dishduty/calendar.ics": calendarFormatICS,
+		"/api/dishduty/calendar.csv": calendarFormatCSV,
+	} {
+		e.Router.AddRoute(echo.Route{
+			Method:  http.MethodGet,
+			Path:    path,
+			Handler: calendarHandler(format),
+		})
+	}
+
+	// GET /api/dishduty/month
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/month",
+		Handler: func(c echo.Context) error {
+			today := getTodayStartGo()
+			year, month := today.Year(), int(today.Month())
+			if yearStr := c.QueryParam("year"); yearStr != "" {
+				parsed, err := strconv.Atoi(yearStr)
+				if err != nil || parsed < 1970 || parsed > 9999 {
+					return apis.NewBadRequestError("year must be between 1970 and 9999.", nil)
+				}
+				year = parsed
+			}
+			if monthStr := c.QueryParam("month"); monthStr != "" {
+				parsed, err := strconv.Atoi(monthStr)
+				if err != nil || parsed < 1 || parsed > 12 {
+					return apis.NewBadRequestError("month must be between 1 and 12.", nil)
+				}
+				month = parsed
+			}
+			pad := c.QueryParam("pad") == "true"
+
+			// Dates are stored as calendar days in APP_TIMEZONE at UTC midnight, so the month is plain UTC dates.
+			monthStart := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+			monthEnd := monthStart.AddDate(0, 1, -1)
+			gridStart, gridEnd := monthStart, monthEnd
+			if pad {
+				// Six Monday-first weeks, so every month renders as the same 42-cell grid.
+				gridStart = monthStart.AddDate(0, 0, -((int(monthStart.Weekday()) + 6) % 7))
+				gridEnd = gridStart.AddDate(0, 0, 41)
+			}
+
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+
+			calendar, err := buildCalendarGo(dao, roster.Id, gridStart, gridEnd)
+			if err != nil {
+				log.Printf("Error building month grid: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to fetch month.", err)
+			}
+			colorOf := func(workerID string) string {
+				worker, _ := workersCacheGo.get(dao, workerID)
+				if worker == nil {
+					return ""
+				}
+				return worker.GetString("color")
+			}
+
+			cells := []MonthCell{}
+			index := map[string]int{}
+			for day := gridStart; !day.After(gridEnd); day = day.AddDate(0, 0, 1) {
+				ymd := day.Format(timeLayoutYMD)
+				index[ymd] = len(cells)
+				cells = append(cells, MonthCell{Date: ymd, InMonth: day.Month() == monthStart.Month(), Status: "unassigned", Queued: []QueuedInCell{}})
+			}
+			for _, entry := range calendar.Assignments {
+				if i, ok := index[entry.Date]; ok {
+					cells[i].WorkerID = entry.WorkerID
+					cells[i].WorkerName = entry.WorkerName
+					cells[i].Color = colorOf(entry.WorkerID)
+					cells[i].Status = entry.Status
+				}
+			}
+			for _, entry := range calendar.QueuedAssignments {
+				start, err := parseYMDToGoTime(entry.Date)
+				if err != nil {
+					continue
+				}
+				for offset := 0; offset < entry.DurationDays; offset++ {
+					if i, ok := index[start.AddDate(0, 0, offset).Format(timeLayoutYMD)]; ok {
+						cells[i].Queued = append(cells[i].Queued, QueuedInCell{WorkerID: entry.WorkerID, WorkerName: entry.WorkerName, Color: colorOf(entry.WorkerID)})
+					}
+				}
+			}
+
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"year":  year,
+				"month": month,
+				"days":  cells,
+			})
+		},
+	})
+
+	// GET /api/dishduty/blackout
+	// Blackout dates with their reasons between start_date and end_date (inclusive).
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/blackout",
+		Handler: func(c echo.Context) error {
+			rangeStart, rangeEnd, err := parseDateRangeQueryGo(c)
+			if err != nil {
+				return err
+			}
+			blackouts, err := findBlackoutsGo(dao, rangeStart, rangeEnd)
+			if err != nil {
+				log.Printf("Error fetching blackout dates: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to fetch blackout dates.", err)
+			}
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"start_date": rangeStart.Format(timeLayoutYMD),
+				"end_date":   rangeEnd.Format(timeLayoutYMD),
+				"blackouts":  blackouts,
+			})
+		},
+	})
+
+	// POST /api/dishduty/blackout
+	// Marks a date as a blackout date. Assignments that already exist on it are kept; reset or
+	// reassign them separately.
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/dishduty/blackout",
+		Handler: func(c echo.Context) error {
+			requestData := struct {
+				AdminPassword string `json:"admin_password"`
+				Date          string `json:"date"`
+				Reason        string `json:"reason"`
+			}{}
+			if err := c.Bind(&requestData); err != nil {
+				return apis.NewBadRequestError("Failed to parse request data.", err)
+			}
+			if err := requireAdminGo(c, requestData.AdminPassword); err != nil {
+				return err
+			}
+			day, err := parseYMDToGoTime(requestData.Date)
+			if err != nil {
+				return apis.NewBadRequestError("Invalid date format. Use YYYY-MM-DD.", err)
+			}
+			reason := strings.TrimSpace(requestData.Reason)
+			if utf8.RuneCountInString(reason) > blackoutReasonMaxLength {
+				return apis.NewBadRequestError(fmt.Sprintf("reason must be at most %d characters.", blackoutReasonMaxLength), nil)
+			}
+			if existing, err := findBlackoutsGo(dao, day, day); err != nil {
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to check blackout dates.", err)
+			} else if len(existing) > 0 {
+				return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("%s is already a blackout date.", requestData.Date)})
+			}
+
+			collection, err := dao.FindCollectionByNameOrId("blackout_dates")
+			if err != nil {
+				return apis.NewApiError(http.StatusInternalServerError, "Blackout dates are unavailable.", err)
+			}
+			record := models.NewRecord(collection)
+			record.Set("date", day.Format(timeLayoutFull))
+			record.Set("reason", reason)
+			if err := dao.SaveRecord(record); err != nil {
+				log.Printf("Error saving blackout date %s: %v", requestData.Date, err)
+				return apis.NewApiError(http.StatusInternalServerError, "Could not save blackout date.", err)
+			}
+			logActionGo(dao, "blackout_added", map[string]interface{}{"date": requestData.Date, "reason": reason})
+			return c.JSON(http.StatusCreated, BlackoutEntry{Date: requestData.Date, Reason: reason})
+		},
+	})
+
+	// DELETE /api/dishduty/blackout/:date
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodDelete,
+		Path:   "/api/dishduty/blackout/:date",
+		Handler: func(c echo.Context) error {
+			requestData := struct {
+				AdminPassword string `json:"admin_password"`
+			}{}
+			if err := c.Bind(&requestData); err != nil {
+				return apis.NewBadRequestError("Failed to parse request data.", err)
+			}
+			if err := requireAdminGo(c, requestData.AdminPassword); err != nil {
+				return err
+			}
+			day, err := parseYMDToGoTime(c.PathParam("date"))
+			if err != nil {
+				return apis.NewBadRequestError("Invalid date format. Use YYYY-MM-DD.", err)
+			}
+			records := []*models.Record{}
+			if err := dao.RecordQuery("blackout_dates").AndWhere(sameDayExpGo("date", day)).All(&records); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to fetch blackout dates.", err)
+			}
+			if len(records) == 0 {
+				return apis.NewNotFoundError("Blackout date not found.", nil)
+			}
+			for _, record := range records {
+				if err := dao.DeleteRecord(record); err != nil {
+					log.Printf("Error deleting blackout date %s: %v", record.Id, err)
+					return apis.NewApiError(http.StatusInternalServerError, "Could not delete blackout date.", err)
+				}
+			}
+			logActionGo(dao, "blackout_removed", map[string]interface{}{"date": day.Format(timeLayoutYMD), "reason": records[0].GetString("reason")})
+			return c.NoContent(http.StatusNoContent)
+		},
+	})
+
+	// GET /api/dishduty/gaps
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/gaps",
+		Handler: func(c echo.Context) error {
+			rangeStart, rangeEnd, err := parseDateRangeQueryGo(c)
+			if err != nil {
+				return err
+			}
+			// Days after today aren't missed yet, so the range stops at today.
+			if today := getTodayStartGo(); rangeEnd.After(today) {
+				rangeEnd = today
+			}
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+			gaps := []string{}
+			if !rangeEnd.Before(rangeStart) {
+				gaps, err = findCoverageGapsGo(dao, roster.Id, rangeStart, rangeEnd)
+				if err != nil {
+					log.Printf("Error finding coverage gaps: %v", err)
+					return apis.NewApiError(http.StatusInternalServerError, "Failed to find coverage gaps.", err)
+				}
+			}
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"start_date": rangeStart.Format(timeLayoutYMD),
+				"end_date":   rangeEnd.Format(timeLayoutYMD),
+				"gaps":       gaps,
+			})
+		},
+	})
+
+	// POST /api/dishduty/backfill
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/dishduty/backfill",
+		Handler: func(c echo.Context) error {
+			requestData := struct {
+				AdminPassword string `json:"admin_password"`
+			}{}
+			if err := c.Bind(&requestData); err != nil {
+				return apis.NewBadRequestError("Failed to parse request data.", err)
+			}
+			if err := requireAdminGo(c, requestData.AdminPassword); err != nil {
+				return err
+			}
+			rangeStart, rangeEnd, err := parseDateRangeQueryGo(c)
+			if err != nil {
+				return err
+			}
+			if today := getTodayStartGo(); rangeEnd.After(today) {
+				rangeEnd = today
+			}
+			if rangeEnd.Before(rangeStart) {
+				return apis.NewBadRequestError("Only days up to today can be backfilled.", nil)
+			}
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+
+			created, err := backfillAssignmentsGo(dao, roster.Id, rangeStart, rangeEnd)
+			if err != nil {
+				log.Printf("Error backfilling assignments: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to backfill assignments; nothing was created.", err)
+			}
+			assignments := make([]map[string]interface{}, 0, len(created))
+			dates := make([]string, 0, len(created))
+			for _, assignment := range created {
+				details := assignmentDetailsGo(dao, assignment)
+				assignments = append(assignments, details)
+				dates = append(dates, details["date"].(string))
+			}
+			logActionGo(dao, "backfill", map[string]interface{}{
+				"roster":     roster.GetString("name"),
+				"start_date": rangeStart.Format(timeLayoutYMD),
+				"end_date":   rangeEnd.Format(timeLayoutYMD),
+				"count":      len(created),
+				"dates":      dates,
+			})
+			return c.JSON(http.StatusOK, map[string]interface{}{"count": len(created), "assignments": assignments})
+		},
+	})
+
+	// POST /api/dishduty/volunteer
+	// Lets a worker claim an unassigned day up to today, e.g. a gap, without the admin password.
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/dishduty/volunteer",
+		Handler: func(c echo.Context) error {
+			requestData := struct {
+				WorkerID string `json:"worker_id"`
+				Date     string `json:"date"`
+			}{}
+			if err := c.Bind(&requestData); err != nil {
+				return apis.NewBadRequestError("Failed to parse request data.", err)
+			}
+			if requestData.WorkerID == "" || requestData.Date == "" {
+				return apis.NewBadRequestError("worker_id and date are required.", nil)
+			}
+			day, err := parseYMDToGoTime(requestData.Date)
+			if err != nil {
+				return apis.NewBadRequestError("Invalid date format. Use YYYY-MM-DD.", err)
+			}
+			if day.After(getTodayStartGo()) {
+				return apis.NewBadRequestError("Only days up to today can be claimed.", nil)
+			}
+			worker, err := dao.FindRecordById("workers", requestData.WorkerID)
+			if err != nil {
+				return apis.NewNotFoundError("Worker not found.", err)
+			}
+			if worker.GetBool("inactive") {
+				return apis.NewBadRequestError("Inactive workers can't volunteer.", nil)
+			}
+			if blackouts, err := findBlackoutsGo(dao, day, day); err != nil {
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to check blackout dates.", err)
+			} else if len(blackouts) > 0 {
+				return apis.NewBadRequestError(fmt.Sprintf("%s is a blackout date.", requestData.Date), nil)
+			}
+
+			assignment, err := volunteerForDayGo(dao, worker, day)
+			if errors.Is(err, errDayAlreadyAssigned) {
+				return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("%s already has an assignment.", requestData.Date)})
+			}
+			if err != nil {
+				log.Printf("Error recording volunteer %s for %s: %v", worker.GetString("name"), requestData.Date, err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to claim the day.", err)
+			}
+			return c.JSON(http.StatusCreated, assignmentDetailsGo(dao, assignment))
+		},
+	})
+
+	// POST /api/dishduty/rebalance
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/dishduty/rebalance",
+		Handler: func(c echo.Context) error {
+			requestData := struct {
+				AdminPassword string `json:"admin_password"`
+			}{}
+			if err := c.Bind(&requestData); err != nil {
+				return apis.NewBadRequestError("Failed to parse request data.", err)
+			}
+			if err := requireAdminGo(c, requestData.AdminPassword); err != nil {
+				return err
+			}
+			days, err := strconv.Atoi(c.QueryParam("days"))
+			if err != nil || days < 1 || days > getMaxCalendarDaysGo() {
+				return apis.NewBadRequestError(fmt.Sprintf("days must be a number between 1 and %d.", getMaxCalendarDaysGo()), nil)
+			}
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+
+			moves, err := rebalanceFutureAssignmentsGo(dao, roster.Id, days)
+			if err != nil {
+				log.Printf("Error rebalancing assignments: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to rebalance assignments; nothing was changed.", err)
+			}
+			if len(moves) > 0 {
+				logActionGo(dao, "rebalanced", map[string]interface{}{"roster": roster.GetString("name"), "days": days, "count": len(moves), "moves": moves})
+			}
+			return c.JSON(http.StatusOK, map[string]interface{}{"days": days, "count": len(moves), "moves": moves})
+		},
+	})
+
+	// GET /api/dishduty/stats
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/stats",
+		Handler: func(c echo.Context) error {
+			startDateStr := c.QueryParam("start_date")
+			endDateStr := c.QueryParam("end_date")
+			dateRegex := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+			if (startDateStr != "" && !dateRegex.MatchString(startDateStr)) || (endDateStr != "" && !dateRegex.MatchString(endDateStr)) {
+				return apis.NewBadRequestError(queryParamsHintGo(c, "Invalid date format. Use YYYY-MM-DD.", rangeQueryParams), nil)
+			}
+			if problem := strictQueryParamsProblemGo(c, rangeQueryParams); problem != "" {
+				return apis.NewBadRequestError(problem, nil)
+			}
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+			workerStats, err := getWorkerStatsGo(dao, roster.Id, startDateStr, endDateStr)
+			if err != nil {
+				log.Printf("Error computing stats: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to compute stats.", err)
+			}
+			return c.JSON(http.StatusOK, StatsResponse{
+				StartDate: startDateStr,
+				EndDate:   endDateStr,
+				Summary:   summarizeWorkerStatsGo(workerStats),
+				Workers:   workerStats,
+			})
+		},
+	})
+
+	// GET /api/dishduty/leaderboard
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/leaderboard",
+		Handler: func(c echo.Context) error {
+			period := c.QueryParam("period")
+			if period == "" {
+				period = "all"
+			}
+			startDateStr, endDateStr := "", ""
+			switch period {
+			case "all":
+			case "month":
+				today, _ := parseYMDToGoTime(getTodayYMDGo())
+				monthStart := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+				startDateStr = formatDateToYMDGo(monthStart)
+				endDateStr = formatDateToYMDGo(monthStart.AddDate(0, 1, -1))
+			default:
+				return apis.NewBadRequestError("period must be 'month' or 'all'.", nil)
+			}
+
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+			workerStats, err := getWorkerStatsGo(dao, roster.Id, startDateStr, endDateStr)
+			if err != nil {
+				log.Printf("Error computing leaderboard: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to compute leaderboard.", err)
+			}
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"period":      period,
+				"leaderboard": buildLeaderboardGo(workerStats),
+			})
+		},
+	})
+
+	// GET /api/dishduty/overdue
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/overdue",
+		Handler: func(c echo.Context) error {
+			days := getOverdueDaysGo()
+			if daysStr := c.QueryParam("days"); daysStr != "" {
+				parsed, err := strconv.Atoi(daysStr)
+				if err != nil || parsed < 0 {
+					return apis.NewBadRequestError("days must be a non-negative integer.", nil)
+				}
+				days = parsed
+			}
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+			overdue, err := findOverdueWorkersGo(dao, roster.Id, days)
+			if err != nil {
+				log.Printf("Error computing overdue workers: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to compute overdue workers.", err)
+			}
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"days":    days,
+				"overdue": overdue,
+			})
+		},
+	})
+
+	// GET /api/dishduty/streak
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/streak",
+		Handler: func(c echo.Context) error {
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+			streak, err := computeStreakGo(dao, roster.Id, getTodayYMDGo())
+			if err != nil {
+				log.Printf("Error computing streak: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to compute streak.", err)
+			}
+			return c.JSON(http.StatusOK, streak)
+		},
+	})
+
+	// GET /api/dishduty/status
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/status",
+		Handler: func(c echo.Context) error {
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+			queueLength, err := countQueueItemsGo(dao, roster.Id)
+			if err != nil {
+				log.Printf("Error counting queue items for status: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to fetch status.", err)
+			}
+			settings := getSettingsGo(dao)
+			mode := settings.OnEmptyQueue
+			response := StatusResponse{
+				OnEmptyQueue:  mode,
+				QueueLength:   queueLength,
+				QueueMaxItems: getQueueMaxItemsGo(),
+				// Only meaningful in "stop" mode, where an empty queue means nobody gets assigned.
+				QueueExhausted: mode == onEmptyQueueStop && queueLength == 0,
+			}
+			if settings.SnoozeUntil >= getTodayYMDGo() {
+				response.SnoozeUntil = settings.SnoozeUntil
+			}
+			return c.JSON(http.StatusOK, response)
+		},
+	})
+
+	// POST /api/dishduty/queue/recompute
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/dishduty/queue/recompute",
+		Handler: func(c echo.Context) error {
+			requestData := struct {
+				AdminPassword string `json:"admin_password"`
+			}{}
+			if err := c.Bind(&requestData); err != nil {
+				return apis.NewBadRequestError("Failed to parse request data.", err)
+			}
+			if err := requireAdminGo(c, requestData.AdminPassword); err != nil {
+				return err
+			}
+			changed, total, err := recomputeQueueStartDatesGo(dao)
+			if err != nil {
+				log.Printf("Error recomputing queue start dates: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to recompute queue.", err)
+			}
+			logActionGo(dao, "queue_recomputed", map[string]interface{}{"items_changed": changed, "items_total": total})
+			return c.JSON(http.StatusOK, map[string]interface{}{"message": "Queue start dates recomputed.", "items_changed": changed, "items_total": total})
+		},
+	})
+
+	// GET /api/dishduty/settings
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/settings",
+		Handler: func(c echo.Context) error {
+			return c.JSON(http.StatusOK, getSettingsGo(dao))
+		},
+	})
+
+	// PATCH /api/dishduty/settings
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPatch,
+		Path:   "/api/dishduty/settings",
+		Handler: func(c echo.Context) error {
+			var req UpdateSettingsRequest
+			if err := c.Bind(&req); err != nil {
+				return apis.NewBadRequestError("Failed to parse request data.", err)
+			}
+			if err := requireAdminGo(c, req.AdminPassword); err != nil {
+				return err
+			}
+			if req.OnEmptyQueue != nil && *req.OnEmptyQueue != onEmptyQueueRandom && *req.OnEmptyQueue != onEmptyQueueStop {
+				return apis.NewBadRequestError(fmt.Sprintf("Invalid on_empty_queue. Must be '%s' or '%s'.", onEmptyQueueRandom, onEmptyQueueStop), nil)
+			}
+
+			if err := ensureSettingsRecordGo(dao); err != nil {
+				log.Printf("Error seeding settings before update: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to update settings.", err)
+			}
+			record, err := findSettingsRecordGo(dao)
+			if err != nil || record == nil {
+				log.Printf("Error fetching settings for update: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to update settings.", err)
+			}
+
+			changes := map[string]interface{}{}
+			if req.OnEmptyQueue != nil {
+				changes["on_empty_queue"] = *req.OnEmptyQueue
+			}
+			if req.FairnessUseWeights != nil {
+				changes["fairness_use_weights"] = *req.FairnessUseWeights
+			}
+			if req.SkipWeekends != nil {
+				changes["skip_weekends"] = *req.SkipWeekends
+			}
+			if req.Paused != nil {
+				changes["paused"] = *req.Paused
+			}
+			if req.AllowDuplicateQueueEntries != nil {
+				changes["duplicate_queue_entries"] = "allow"
+				if !*req.AllowDuplicateQueueEntries {
+					changes["duplicate_queue_entries"] = "reject"
+				}
+			}
+			for field, value := range changes {
+				record.Set(field, value)
+			}
+			if err := dao.SaveRecord(record); err != nil {
+				log.Printf("Error saving settings: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to update settings.", err)
+			}
+			logActionGo(dao, "settings_updated", changes)
+			return c.JSON(http.StatusOK, getSettingsGo(dao))
+		},
+	})
+
+	// POST /api/dishduty/snooze
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/dishduty/snooze",
+		Handler: func(c echo.Context) error {
+			requestData := struct {
+				AdminPassword string `json:"admin_password"`
+			}{}
+			if err := c.Bind(&requestData); err != nil {
+				return apis.NewBadRequestError("Failed to parse request data.", err)
+			}
+			if err := requireAdminGo(c, requestData.AdminPassword); err != nil {
+				return err
+			}
+			// An empty until lifts the snooze.
+			until := strings.TrimSpace(c.QueryParam("until"))
+			if until != "" {
+				if _, err := parseYMDToGoTime(until); err != nil {
+					return apis.NewBadRequestError("Invalid until. Use YYYY-MM-DD.", err)
+				}
+				if until < getTodayYMDGo() {
+					return apis.NewBadRequestError("The until date can't be in the past.", nil)
+				}
+			}
+
+			if err := ensureSettingsRecordGo(dao); err != nil {
+				log.Printf("Error seeding settings before snooze: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to update settings.", err)
+			}
+			record, err := findSettingsRecordGo(dao)
+			if err != nil || record == nil {
+				log.Printf("Error fetching settings for snooze: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to update settings.", err)
+			}
+			if until == "" {
+				record.Set("snooze_until", "")
+			} else {
+				record.Set("snooze_until", ymdToStoredDateGo(until))
+			}
+			if err := dao.SaveRecord(record); err != nil {
+				log.Printf("Error saving snooze: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to update settings.", err)
+			}
+			logActionGo(dao, "settings_updated", map[string]interface{}{"snooze_until": until})
+			return c.JSON(http.StatusOK, getSettingsGo(dao))
+		},
+	})
+
+	// GET /api/dishduty/forecast
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/forecast",
+		Handler: func(c echo.Context) error {
+			days := 14
+			if daysStr := c.QueryParam("days"); daysStr != "" {
+				parsed, err := strconv.Atoi(daysStr)
+				if err != nil || parsed < 1 || parsed > forecastMaxDays {
+					return apis.NewBadRequestError(fmt.Sprintf("days must be between 1 and %d.", forecastMaxDays), nil)
+				}
+				days = parsed
+			}
+
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+			start := getTodayStartGo()
+			state, err := loadScheduleStateGo(dao, roster.Id, start, days)
+			if err != nil {
+				log.Printf("Error loading schedule state for forecast: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to compute forecast.", err)
+			}
+
+			entries := make([]ForecastEntry, 0, days)
+			for i := 0; i < days; i++ {
+				pick := state.pick(start.AddDate(0, 0, i))
+				state.apply(pick)
+				entry := ForecastEntry{Date: pick.Date.Format(timeLayoutYMD), Source: pick.Source, Skipped: pick.Skipped}
+				if pick.Worker != nil {
+					entry.WorkerID = pick.Worker.Id
+					entry.WorkerName = pick.Worker.GetString("name")
+				} else if pick.Existing != nil {
+					entry.WorkerID = pick.Existing.GetString("worker_id")
+					entry.WorkerName = "Unknown"
+				}
+				entries = append(entries, entry)
+			}
+			return c.JSON(http.StatusOK, entries)
+		},
+	})
+
+	// GET /api/dishduty/rotation-order
+	// The active workers in the order the fairness rotation would pick them for the next unassigned day.
+	// Recurring rules and the queue come first in the real pick and aren't reflected here.
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/rotation-order",
+		Handler: func(c echo.Context) error {
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+			today := getTodayStartGo()
+			state, err := loadScheduleStateGo(dao, roster.Id, today, 2)
+			if err != nil {
+				log.Printf("Error loading schedule state for rotation order: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to compute rotation order.", err)
+			}
+			day := today
+			if todayPick := state.pick(today); todayPick.Existing != nil {
+				state.apply(todayPick)
+				day = today.AddDate(0, 0, 1)
+			}
+
+			candidates := state.active
+			if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+				candidates = state.weekendPool(day)
+			}
+			lastAssignedYMD := func(worker *models.Record) string {
+				if date := worker.GetDateTime("last_assigned_date"); !date.IsZero() {
+					return date.Time().Format(timeLayoutYMD)
+				}
+				return ""
+			}
+			entries := make([]RotationOrderEntry, 0, len(state.active))
+			listed := map[string]bool{}
+			for _, ranked := range state.rankCandidates(day, candidates) {
+				entry := RotationOrderEntry{
+					WorkerID:         ranked.Worker.Id,
+					WorkerName:       ranked.Worker.GetString("name"),
+					LastAssignedDate: lastAssignedYMD(ranked.Worker),
+					Eligible:         true,
+				}
+				if !ranked.LastAssigned.IsZero() {
+					score := day.Sub(ranked.LastAssigned).Hours() / 24
+					entry.EffectiveLastAssigned = ranked.LastAssigned.Format(timeLayoutYMD)
+					entry.Score = &score
+				}
+				entries = append(entries, entry)
+				listed[ranked.Worker.Id] = true
+			}
+			for _, worker := range state.active {
+				if listed[worker.Id] {
+					continue
+				}
+				reason := state.excludedReason(worker, day)
+				if reason == "" {
+					reason = "not_weekend_ok"
+				}
+				entries = append(entries, RotationOrderEntry{
+					WorkerID:         worker.Id,
+					WorkerName:       worker.GetString("name"),
+					LastAssignedDate: lastAssignedYMD(worker),
+					Reason:           reason,
+				})
+			}
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"date":    day.Format(timeLayoutYMD),
+				"workers": entries,
+			})
+		},
+	})
+
+	// GET /api/dishduty/diagnostics/fairness
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/diagnostics/fairness",
+		Handler: func(c echo.Context) error {
+			if err := requireAdminScopeGo(c, c.Request().Header.Get(adminPasswordHeader), adminScopeReadonly); err != nil {
+				return err
+			}
+			days := 365
+			if daysStr := c.QueryParam("days"); daysStr != "" {
+				parsed, err := strconv.Atoi(daysStr)
+				if err != nil || parsed < 1 || parsed > fairnessDiagnosticsMaxDays {
+					return apis.NewBadRequestError(fmt.Sprintf("days must be between 1 and %d.", fairnessDiagnosticsMaxDays), nil)
+				}
+				days = parsed
+			}
+
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+			result, err := simulateFairnessGo(dao, roster.Id, days)
+			if err != nil {
+				log.Printf("Error simulating fairness: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to simulate fairness.", err)
+			}
+			return c.JSON(http.StatusOK, result)
+		},
+	})
+
+	// GET /api/dishduty/diagnostics/validate
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/diagnostics/validate",
+		Handler: func(c echo.Context) error {
+			if err := requireAdminScopeGo(c, c.Request().Header.Get(adminPasswordHeader), adminScopeReadonly); err != nil {
+				return err
+			}
+			result, err := validateConfigurationGo(dao)
+			if err != nil {
+				log.Printf("Error validating configuration: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to validate the configuration.", err)
+			}
+			return c.JSON(http.StatusOK, result)
+		},
+	})
+
+	// GET /api/dishduty/export
+	// Streams every worker, assignment, queue item, recurring rule and the settings as one JSON document;
+	// ?include_action_log=true adds the action log and ?roster= limits the document to one roster.
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/export",
+		Handler: func(c echo.Context) error {
+			if err := requireAdminScopeGo(c, c.Request().Header.Get(adminPasswordHeader), adminScopeReadonly); err != nil {
+				return err
+			}
+			includeActionLog := false
+			if value := c.QueryParam("include_action_log"); value != "" {
+				parsed, err := strconv.ParseBool(value)
+				if err != nil {
+					return apis.NewBadRequestError("include_action_log must be true or false.", nil)
+				}
+				includeActionLog = parsed
+			}
+			rosterID := ""
+			if c.QueryParam("roster") != "" {
+				roster, err := resolveRosterGo(dao, c)
+				if err != nil {
+					return err
+				}
+				rosterID = roster.Id
+			}
+
+			c.Response().Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+			c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"dishduty-export-%s.json\"", getTodayYMDGo()))
+			c.Response().WriteHeader(http.StatusOK)
+			if err := writeExportGo(dao, c.Response(), includeActionLog, rosterID); err != nil {
+				// The status line is already sent; the truncated document is the only signal the client gets.
+				log.Printf("Error writing export: %v", err)
+			}
+			return nil
+		},
+	})
+
+	// POST /api/dishduty/import?mode=merge|replace&roster=
+	// Restores a single-roster export document into the roster (default: the default roster); see
+	// importStateGo for how the modes treat local data.
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodPost,
+		Path:   "/api/dishduty/import",
+		Handler: func(c echo.Context) error {
+			var doc ImportDocument
+			if err := json.NewDecoder(io.LimitReader(c.Request().Body, importMaxBytes)).Decode(&doc); err != nil {
+				return apis.NewBadRequestError("Invalid import document.", err)
+			}
+			password := doc.AdminPassword
+			if password == "" {
+				password = c.Request().Header.Get(adminPasswordHeader)
+			}
+			if err := requireAdminGo(c, password); err != nil {
+				return err
+			}
+			mode := c.QueryParam("mode")
+			if mode == "" {
+				mode = importModeMerge
+			}
+			if mode != importModeMerge && mode != importModeReplace {
+				return apis.NewBadRequestError(fmt.Sprintf("mode must be '%s' or '%s'.", importModeMerge, importModeReplace), nil)
+			}
+			roster, err := resolveRosterGo(dao, c)
+			if err != nil {
+				return err
+			}
+			problems := validateImportDocumentGo(&doc)
+			if len(problems) == 0 {
+				problems, err = validateImportTargetGo(dao, &doc, roster.Id)
+				if err != nil {
+					return apis.NewApiError(http.StatusInternalServerError, "Could not validate the import document.", err)
+				}
+			}
+			if len(problems) > 0 {
+				return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid import document; nothing was imported.", "problems": problems})
+			}
+
+			result, err := importStateGo(dao, &doc, mode, roster.Id)
+			if err != nil {
+				log.Printf("Error importing state: %v", err)
+				return apis.NewApiError(http.StatusInternalServerError, "Failed to import; nothing was imported.", err)
+			}
+			if mode == importModeMerge && result.QueueItemsImported > 0 {
+				// Merged items were appended with their original dates; line them up behind the local queue.
+				if _, _, err := recomputeQueueStartDatesGo(dao); err != nil {
+					log.Printf("Error recomputing queue after import: %v", err)
+				}
+			}
+			result.Roster = roster.GetString("name")
+			logActionGo(dao, "state_imported", map[string]interface{}{
+				"mode":                 result.Mode,
+				"roster":               result.Roster,
+				"workers_created":      result.WorkersCreated,
+				"workers_matched":      result.WorkersMatched,
+				"workers_deleted":      result.WorkersDeleted,
+				"assignments_imported": result.AssignmentsImported,
+				"assignments_skipped":  result.AssignmentsSkipped,
+				"queue_items_imported": result.QueueItemsImported,
+				"queue_items_skipped":  result.QueueItemsSkipped,
+			})
+			return c.JSON(http.StatusOK, result)
+		},
+	})
+
+	// GET /api/dishduty/debug/config
+	e.Router.AddRoute(echo.Route{
+		Method: http.MethodGet,
+		Path:   "/api/dishduty/debug/config",
+		Handler: func(c echo.Context) error {
+			if err := requireAdminScopeGo(c, c.Request().Header.Get(adminPasswordHeader), adminScopeReadonly); err != nil {
+				return err
+			}
+			return c.JSON(http.StatusOK, getEffectiveConfigGo(dao))
+		},
+	})
+
+	// Wrong methods and unknown paths under /api/dishduty/ get a 405 or 404 in the API's error
+	// envelope instead of falling through to PocketBase's catch-all.
+	registerFallbackRoutesGo(e.Router)
+}
+
+// startBackgroundJobsGo starts the scheduled jobs and the first assignment check.
+func startBackgroundJobsGo(app core.App) {
+	dao := app.Dao()
+
+	// --- Scheduled Jobs ---
+	scheduler := cron.New()
+	scheduler.SetTimezone(getAppLocationGo())
+	scheduler.MustAdd("action_log_retention", "30 3 * * *", func() {
+		if _, err := purgeOldActionLogsGo(dao); err != nil {
+			log.Printf("Error purging old action_log entries: %v", err)
+		}
+	})
+	if getFairnessResetGo() == fairnessResetMonthly {
+		scheduler.MustAdd("fairness_reset", "0 0 1 * *", func() {
+			if err := resetFairnessGo(dao); err != nil {
+				log.Printf("Error resetting fairness: %v", err)
+			}
+		})
+	}
+	if strings.EqualFold(os.Getenv("REMIND_DAY_BEFORE"), "true") {
+		scheduler.MustAdd("reminder_day_before", fmt.Sprintf("0 %d * * *", getRemindDayBeforeHourGo()), func() {
+			if err := sendDayBeforeReminderGo(dao); err != nil {
+				log.Printf("Error sending day-before reminder: %v", err)
+			}
+		})
+	}
+	if digestDay, ok := getWeeklyDigestDayGo(); ok {
+		scheduler.MustAdd("weekly_digest", fmt.Sprintf("0 %d * * %d", getWeeklyDigestHourGo(), int(digestDay)), func() {
+			if err := sendWeeklyDigestGo(dao); err != nil {
+				log.Printf("Error sending weekly digest: %v", err)
+			}
+		})
+	}
+	scheduler.Start()
+
+	go func() {
+		time.Sleep(getInitialAssignDelayGo())
+		if err := waitForCollectionsGo(dao, initialAssignCollections, initialAssignTimeout); err != nil {
+			log.Printf("Skipping initial daily assignment check: %v", err)
+			return
+		}
+		log.Println("Attempting initial daily assignment check after startup...")
+		if err := ensureDailyAssignmentGo(dao); err != nil {
+			log.Printf("Error during initial ensureDailyAssignmentGo: %v", err)
+		}
+	}()
+}
+
+// --- Request Auditing ---
+
+// auditBodyMaxBytes caps how much of a request body is copied into the action log.
+const auditBodyMaxBytes = 64 << 10
+
+// auditRedactedKeys are body fields never written to the action log.
+var auditRedactedKeys = map[string]bool{"admin_password": true, "password": true, "token": true}
+
+// auditRequestBodiesEnabledGo reports whether mutating admin calls are logged with their request body
+// (AUDIT_REQUEST_BODIES, default false).
+func auditRequestBodiesEnabledGo() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("AUDIT_REQUEST_BODIES")), "true")
+}
+
+// redactAuditPayloadGo drops credential fields from a decoded JSON body, at any depth.
+func redactAuditPayloadGo(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if auditRedactedKeys[strings.ToLower(key)] {
+				delete(v, key)
+				continue
+			}
+			v[key] = redactAuditPayloadGo(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactAuditPayloadGo(item)
+		}
+	}
+	return value
+}
+
+// auditPayloadGo describes a request body for the action log: decoded and redacted JSON, form values without
+// credentials, or just the content type and size for anything else (e.g. CSV imports or oversized bodies).
+func auditPayloadGo(r *http.Request, body []byte, truncated bool) interface{} {
+	contentType := r.Header.Get(echo.HeaderContentType)
+	if !truncated && strings.HasPrefix(contentType, echo.MIMEApplicationJSON) {
+		var decoded interface{}
+		if err := json.Unmarshal(body, &decoded); err == nil {
+			return redactAuditPayloadGo(decoded)
+		}
+	}
+	if r.MultipartForm != nil || r.PostForm != nil {
+		values := r.PostForm
+		if r.MultipartForm != nil {
+			values = r.MultipartForm.Value
+		}
+		fields := map[string]interface{}{}
+		for key, items := range values {
+			if !auditRedactedKeys[strings.ToLower(key)] {
+				fields[key] = strings.Join(items, ",")
+			}
+		}
+		return fields
+	}
+	return map[string]interface{}{"content_type": contentType, "size": r.ContentLength}
+}
+
+// auditRequestBodyMiddlewareGo logs every successful mutating dishduty call that passed the admin check as an
+// "admin_request" action with its redacted body. Public calls such as POST /volunteer are not admin requests
+// and are left to their own action log entries. Credentials are never logged: the bearer token lives in a
+// header and password fields are stripped from the body.
+func auditRequestBodyMiddlewareGo(dao *daos.Dao) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions ||
+				!strings.HasPrefix(r.URL.Path, "/api/dishduty/") {
+				return next(c)
+			}
+			body, err := io.ReadAll(io.LimitReader(r.Body, auditBodyMaxBytes+1))
+			if err != nil {
+				return apis.NewBadRequestError("Failed to read request body.", err)
+			}
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+
+			if err := next(c); err != nil {
+				return err
+			}
+			if status := c.Response().Status; status >= http.StatusBadRequest {
+				return nil
+			}
+			if admin, _ := c.Get(adminRequestContextKey).(bool); !admin {
+				return nil
+			}
+			logActionGo(dao, "admin_request", map[string]interface{}{
+				"method":          r.Method,
+				"path":            r.URL.Path,
+				"status":          c.Response().Status,
+				"request_payload": auditPayloadGo(r, body, len(body) > auditBodyMaxBytes),
+			})
+			return nil
+		}
+	}
+}
+
+// --- Assignment Hooks ---
+
+// onAssignCommandTimeout is how long ON_ASSIGN_COMMAND may run before it is killed, and
+// onAssignOutputMaxBytes caps how much of its output is logged.
+const (
+	onAssignCommandTimeout = 30 * time.Second
+	onAssignOutputMaxBytes = 2048
+)
+
+// runOnAssignCommandGo runs ON_ASSIGN_COMMAND, if set, for a new assignment. The command is split on
+// whitespace and executed directly, never through a shell; the worker name, date and source are appended
+// as arguments and also passed as DISHDUTY_* environment variables. Blocks until the command exits, so
+// callers run it in a goroutine.
+func runOnAssignCommandGo(worker *models.Record, dateYMD string, source string) {
+	fields := strings.Fields(os.Getenv("ON_ASSIGN_COMMAND"))
+	if len(fields) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), onAssignCommandTimeout)
+	defer cancel()
+
+	args := append(fields[1:], worker.GetString("name"), dateYMD, source)
+	cmd := exec.CommandContext(ctx, fields[0], args...)
+	cmd.Env = append(os.Environ(),
+		"DISHDUTY_WORKER_ID="+worker.Id,
+		"DISHDUTY_WORKER_NAME="+worker.GetString("name"),
+		"DISHDUTY_DATE="+dateYMD,
+		"DISHDUTY_SOURCE="+source,
+	)
+	output, err := cmd.CombinedOutput()
+	if len(output) > onAssignOutputMaxBytes {
+		output = append(output[:onAssignOutputMaxBytes], "..."...)
+	}
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		log.Printf("ON_ASSIGN_COMMAND for %s on %s timed out after %s. Output: %s", worker.GetString("name"), dateYMD, onAssignCommandTimeout, strings.TrimSpace(string(output)))
+	case err != nil:
+		log.Printf("ON_ASSIGN_COMMAND for %s on %s failed (exit code %d): %v. Output: %s", worker.GetString("name"), dateYMD, exitCode, err, strings.TrimSpace(string(output)))
+	default:
+		log.Printf("ON_ASSIGN_COMMAND for %s on %s finished (exit code %d). Output: %s", worker.GetString("name"), dateYMD, exitCode, strings.TrimSpace(string(output)))
+	}
+}
+
+// --- Notifications ---
+
+// notifier delivers messages to workers through every configured channel: Telegram (TELEGRAM_BOT_TOKEN
+// plus the worker's telegram_chat_id) and email (PocketBase SMTP settings plus the worker's email).
+type notifier struct {
+	app        core.App
+	httpClient *http.Client
+
+	batchMu sync.Mutex
+	batches map[notifyTarget]*announcementBatch // pending announcements per recipient, see announce
+}
+
+var notifierGo = &notifier{httpClient: &http.Client{Timeout: 10 * time.Second}, batches: map[notifyTarget]*announcementBatch{}}
+
+// notifyTarget is one place a message can be delivered: a Telegram chat or an email address.
+type notifyTarget struct {
+	channel string // "telegram" or "email"
+	address string
+}
+
+// announcementBatch collects the duty announcements for one recipient until its flush timer fires.
+type announcementBatch struct {
+	entries []batchedAnnouncement
+}
+
+// batchedAnnouncement is one worker's duty announcement waiting in a batch.
+type batchedAnnouncement struct {
+	workerName string
+	rosterName string
+	subject    string
+	message    string
+}
+
+// render returns the subject and message sending the batch: a single announcement unchanged, several as
+// one "Today's duties" message under their distinct subjects joined in order, e.g. "Dish duty this
+// morning / Dish duty this afternoon".
+func (b *announcementBatch) render() (string, string) {
+	if len(b.entries) == 1 {
+		return b.entries[0].subject, b.entries[0].message
+	}
+	subjects := []string{}
+	duties := make([]string, len(b.entries))
+	details := make([]string, len(b.entries))
+	for i, entry := range b.entries {
+		if !list.ExistInSlice(entry.subject, subjects) {
+			subjects = append(subjects, entry.subject)
+		}
+		duties[i] = fmt.Sprintf("%s (%s)", entry.workerName, entry.rosterName)
+		details[i] = fmt.Sprintf("%s: %s", entry.workerName, entry.message)
+	}
+	return strings.Join(subjects, " / "), "Today's duties: " + strings.Join(duties, ", ") + "\n\n" + strings.Join(details, "\n")
+}
+
+// announceBatchMaxSeconds caps ANNOUNCE_BATCH_SECONDS, so a typo can't hold announcements back for hours.
+const announceBatchMaxSeconds = 300
+
+func (n *notifier) telegramToken() string {
+	return strings.TrimSpace(os.Getenv("TELEGRAM_BOT_TOKEN"))
+}
+
+func (n *notifier) emailEnabled() bool {
+	return n.app != nil && n.app.Settings().Smtp.Enabled
+}
+
+// configured reports whether at least one notification channel is set up.
+func (n *notifier) configured() bool {
+	return n.telegramToken() != "" || n.emailEnabled()
+}
+
+func (n *notifier) sendTelegram(chatID string, message string) error {
+	endpoint := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", n.telegramToken())
+	resp, err := n.httpClient.PostForm(endpoint, url.Values{"chat_id": {chatID}, "text": {message}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("telegram responded with %s", resp.Status)
+	}
+	return nil
+}
+
+func (n *notifier) sendEmail(address string, subject string, message string) error {
+	meta := n.app.Settings().Meta
+	return n.app.NewMailClient().Send(&mailer.Message{
+		From:    mail.Address{Name: meta.SenderName, Address: meta.SenderAddress},
+		To:      []mail.Address{{Address: address}},
+		Subject: subject,
+		Text:    message,
+	})
+}
+
+// workerTargets lists where the worker can be reached: every channel that is configured globally, has a
+// target on the worker and isn't excluded by the worker's notify_channels.
+func (n *notifier) workerTargets(worker *models.Record) []notifyTarget {
+	preferred := worker.GetStringSlice("notify_channels")
+	wants := func(channel string) bool {
+		return len(preferred) == 0 || list.ExistInSlice(channel, preferred)
+	}
+	targets := []notifyTarget{}
+	if chatID := worker.GetString("telegram_chat_id"); chatID != "" && n.telegramToken() != "" && wants("telegram") {
+		targets = append(targets, notifyTarget{channel: "telegram", address: chatID})
+	}
+	if address := worker.GetString("email"); address != "" && n.emailEnabled() && wants("email") {
+		targets = append(targets, notifyTarget{channel: "email", address: address})
+	}
+	return targets
+}
+
+// send delivers message to a single target.
+func (n *notifier) send(target notifyTarget, subject string, message string) error {
+	if target.channel == "telegram" {
+		return n.sendTelegram(target.address, message)
+	}
+	return n.sendEmail(target.address, subject, message)
+}
+
+// channelLabel names a channel in log messages.
+func channelLabel(channel string) string {
+	if channel == "telegram" {
+		return "Telegram"
+	}
+	return channel
+}
+
+// notifyWorker sends message to the worker through every target from workerTargets. It reports whether
+// anything was sent; failures are logged per channel.
+func (n *notifier) notifyWorker(worker *models.Record, subject string, message string) bool {
+	sent := false
+	for _, target := range n.workerTargets(worker) {
+		if err := n.send(target, subject, message); err != nil {
+			log.Printf("Error sending %s notification to worker %s: %v", channelLabel(target.channel), worker.GetString("name"), err)
+		} else {
+			sent = true
+		}
+	}
+	return sent
+}
+
+// getAnnounceBatchWindowGo returns ANNOUNCE_BATCH_SECONDS as a duration (default 0 = send immediately).
+func getAnnounceBatchWindowGo() time.Duration {
+	value := strings.TrimSpace(os.Getenv("ANNOUNCE_BATCH_SECONDS"))
+	if value == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 || seconds > announceBatchMaxSeconds {
+		log.Printf("Warning: invalid ANNOUNCE_BATCH_SECONDS '%s' (must be between 0 and %d). Sending announcements immediately.", value, announceBatchMaxSeconds)
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// announce sends a worker's duty announcement. With ANNOUNCE_BATCH_SECONDS set, announcements for the same
+// Telegram chat or email address (e.g. a shared household group) within that window are coalesced into one
+// message listing every duty, instead of one message per roster or assignee. The window starts with the
+// first announcement for a recipient. Batches still pending when the server stops are lost.
+func (n *notifier) announce(worker *models.Record, rosterName string, subject string, message string) {
+	window := getAnnounceBatchWindowGo()
+	if window <= 0 {
+		n.notifyWorker(worker, subject, message)
+		return
+	}
+	entry := batchedAnnouncement{workerName: worker.GetString("name"), rosterName: rosterName, subject: subject, message: message}
+	n.batchMu.Lock()
+	defer n.batchMu.Unlock()
+	for _, target := range n.workerTargets(worker) {
+		if batch, ok := n.batches[target]; ok {
+			batch.entries = append(batch.entries, entry)
+			continue
+		}
+		n.batches[target] = &announcementBatch{entries: []batchedAnnouncement{entry}}
+		target := target
+		time.AfterFunc(window, func() { n.flushAnnouncements(target) })
+	}
+}
+
+// flushAnnouncements sends the target's pending announcements as one message, see announcementBatch.render.
+func (n *notifier) flushAnnouncements(target notifyTarget) {
+	n.batchMu.Lock()
+	batch := n.batches[target]
+	delete(n.batches, target)
+	n.batchMu.Unlock()
+	if batch == nil || len(batch.entries) == 0 {
+		return
+	}
+
+	subject, message := batch.render()
+	if err := n.send(target, subject, message); err != nil {
+		log.Printf("Error sending %s announcement batch (%d message(s)): %v", channelLabel(target.channel), len(batch.entries), err)
+	}
+}
+
+// AnnouncementData is what ANNOUNCE_TEMPLATE can refer to.
+type AnnouncementData struct {
+	WorkerName string
+	Date       string // YYYY-MM-DD
+	Source     string // the assignment's source, e.g. "queue" or "manual"
+}
+
+var (
+	announceTemplateOnce sync.Once
+	announceTemplate     *template.Template // nil when ANNOUNCE_TEMPLATE is empty or invalid
+)
+
+// announcementMessageGo renders ANNOUNCE_TEMPLATE (a text/template) for an assignment announcement,
+// falling back to defaultMessage when the template is unset, doesn't parse, or fails to render.
+func announcementMessageGo(worker *models.Record, dateYMD string, source string, defaultMessage string) string {
+	announceTemplateOnce.Do(func() {
+		text := os.Getenv("ANNOUNCE_TEMPLATE")
+		if strings.TrimSpace(text) == "" {
+			return
+		}
+		parsed, err := template.New("announce").Parse(text)
+		if err != nil {
+			log.Printf("Warning: invalid ANNOUNCE_TEMPLATE, using the default messages: %v", err)
+			return
+		}
+		announceTemplate = parsed
+	})
+	if announceTemplate == nil {
+		return defaultMessage
+	}
+	var b strings.Builder
+	data := AnnouncementData{WorkerName: worker.GetString("name"), Date: dateYMD, Source: source}
+	if err := announceTemplate.Execute(&b, data); err != nil {
+		log.Printf("Error rendering ANNOUNCE_TEMPLATE, using the default message: %v", err)
+		return defaultMessage
+	}
+	return b.String()
+}
+
+// adminTargets returns where admin messages go: ADMIN_TELEGRAM_CHAT_ID and ADMIN_EMAIL.
+func (n *notifier) adminTargets() (string, string) {
+	return strings.TrimSpace(os.Getenv("ADMIN_TELEGRAM_CHAT_ID")), strings.TrimSpace(os.Getenv("ADMIN_EMAIL"))
+}
+
+// adminConfigured reports whether at least one channel can reach the admin.
+func (n *notifier) adminConfigured() bool {
+	chatID, address := n.adminTargets()
+	return (chatID != "" && n.telegramToken() != "") || (address != "" && n.emailEnabled())
+}
+
+// notifyAdmin sends message to the admin chat and/or email, like notifyWorker does for a worker.
+func (n *notifier) notifyAdmin(subject string, message string) bool {
+	chatID, address := n.adminTargets()
+	sent := false
+	if chatID != "" && n.telegramToken() != "" {
+		if err := n.sendTelegram(chatID, message); err != nil {
+			log.Printf("Error sending Telegram notification to the admin: %v", err)
+		} else {
+			sent = true
+		}
+	}
+	if address != "" && n.emailEnabled() {
+		if err := n.sendEmail(address, subject, message); err != nil {
+			log.Printf("Error sending email notification to the admin: %v", err)
+		} else {
+			sent = true
+		}
+	}
+	return sent
+}
+
+// getRemindDayBeforeHourGo returns the hour (app timezone) of the evening reminder, REMIND_DAY_BEFORE_HOUR (default 19).
+func getRemindDayBeforeHourGo() int {
+	value := strings.TrimSpace(os.Getenv("REMIND_DAY_BEFORE_HOUR"))
+	if value == "" {
+		return 19
+	}
+	hour, err := strconv.Atoi(value)
+	if err != nil || hour < 0 || hour > 23 {
+		log.Printf("Warning: invalid REMIND_DAY_BEFORE_HOUR '%s'. Falling back to 19.", value)
+		return 19
+	}
+	return hour
+}
+
+// getWeeklyDigestDayGo returns the weekday the admin digest is sent on, WEEKLY_DIGEST_DAY as a day name
+// ("monday", "mon") or number (0 = Sunday). ok is false when the digest is disabled (unset or invalid).
+func getWeeklyDigestDayGo() (time.Weekday, bool) {
+	value := strings.ToLower(strings.TrimSpace(os.Getenv("WEEKLY_DIGEST_DAY")))
+	if value == "" {
+		return 0, false
+	}
+	if number, err := strconv.Atoi(value); err == nil && number >= 0 && number <= 6 {
+		return time.Weekday(number), true
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		if value == name || value == name[:3] {
+			return day, true
+		}
+	}
+	log.Printf("Warning: invalid WEEKLY_DIGEST_DAY '%s'. The weekly digest is disabled.", value)
+	return 0, false
+}
+
+// weeklyDigestDayNameGo returns the configured digest weekday for the debug config, or "" when disabled.
+func weeklyDigestDayNameGo() string {
+	day, ok := getWeeklyDigestDayGo()
+	if !ok {
+		return ""
+	}
+	return strings.ToLower(day.String())
+}
+
+// getWeeklyDigestHourGo returns the hour (app timezone) of the weekly digest, WEEKLY_DIGEST_HOUR (default 9).
+func getWeeklyDigestHourGo() int {
+	value := strings.TrimSpace(os.Getenv("WEEKLY_DIGEST_HOUR"))
+	if value == "" {
+		return 9
+	}
+	hour, err := strconv.Atoi(value)
+	if err != nil || hour < 0 || hour > 23 {
+		log.Printf("Warning: invalid WEEKLY_DIGEST_HOUR '%s'. Falling back to 9.", value)
+		return 9
+	}
+	return hour
+}
+
+// buildWeeklyDigestGo summarizes the seven days before today: assignments per worker, the completion rate
+// and every not_done day.
+func buildWeeklyDigestGo(dao *daos.Dao) (string, error) {
+	today := getTodayStartGo()
+	startYMD := today.AddDate(0, 0, -7).Format(timeLayoutYMD)
+	endYMD := today.AddDate(0, 0, -1).Format(timeLayoutYMD)
+	workerStats, err := getWorkerStatsGo(dao, "", startYMD, endYMD)
+	if err != nil {
+		return "", err
+	}
+
+	var digest strings.Builder
+	fmt.Fprintf(&digest, "Dish duty digest for %s to %s\n", startYMD, endYMD)
+	total, done := 0, 0
+	for _, stats := range workerStats {
+		if stats.Total == 0 {
+			continue
+		}
+		total += stats.Total
+		done += stats.Done
+		fmt.Fprintf(&digest, "\n%s: %d assigned, %d done, %d not done", stats.WorkerName, stats.Total, stats.Done, stats.NotDone)
+	}
+	if total == 0 {
+		digest.WriteString("\nNo assignments this week.")
+		return digest.String(), nil
+	}
+	fmt.Fprintf(&digest, "\n\nCompletion rate: %d%% (%d of %d)", done*100/total, done, total)
+
+	notDone := []*models.Record{}
+	err = dao.RecordQuery("assignments").
+		AndWhere(dbx.HashExp{"status": "not_done"}).
+		AndWhere(dayRangeExpGo("date", today.AddDate(0, 0, -7), today.AddDate(0, 0, -1))).
+		OrderBy("date ASC").
+		All(&notDone)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("failed to fetch not_done assignments: %w", err)
+	}
+	if len(notDone) > 0 {
+		digest.WriteString("\n\nNot done:")
+		for _, assignment := range notDone {
+			ymd, ok := recordDateYMDGo(assignment, "date")
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(&digest, "\n- %s: %s", ymd, getWorkerNameGo(dao, assignment.GetString("worker_id")))
+		}
+	}
+	return digest.String(), nil
+}
+
+// sendWeeklyDigestGo sends the weekly digest to the admin. It does nothing when no admin channel is configured.
+func sendWeeklyDigestGo(dao *daos.Dao) error {
+	if !notifierGo.adminConfigured() {
+		return nil
+	}
+	digest, err := buildWeeklyDigestGo(dao)
+	if err != nil {
+		return err
+	}
+	if !notifierGo.notifyAdmin("Dish duty weekly digest", digest) {
+		return nil
+	}
+	logActionGo(dao, "digest_sent", map[string]interface{}{"date": getTodayYMDGo()})
+	return nil
+}
+
+// resetFairnessGo starts a new fairness period: every last_assigned_date from before the period is
+// cleared so the rotation restarts. Assignments are kept; the cleared dates are recorded in the action log.
+func resetFairnessGo(dao *daos.Dao) error {
+	periodStart := monthStartGo(getTodayStartGo())
+	workers, err := workersCacheGo.all(dao)
+	if err != nil {
+		return err
+	}
+	previous := map[string]string{}
+	for _, worker := range workers {
+		lad := worker.GetDateTime("last_assigned_date")
+		if lad.IsZero() || !lad.Time().Before(periodStart) {
+			continue
+		}
+		record, err := dao.FindRecordById("workers", worker.Id)
+		if err != nil {
+			log.Printf("Error loading worker %s for fairness reset: %v", worker.Id, err)
+			continue
+		}
+		record.Set("last_assigned_date", "")
+		if err := dao.SaveRecord(record); err != nil {
+			log.Printf("Error clearing last_assigned_date for worker %s: %v", worker.GetString("name"), err)
+			continue
+		}
+		previous[worker.Id] = lad.Time().Format(timeLayoutYMD)
+	}
+	log.Printf("Fairness reset for the period starting %s: cleared %d worker(s).", periodStart.Format(timeLayoutYMD), len(previous))
+	logActionGo(dao, "fairness_reset", map[string]interface{}{
+		"period_start":        periodStart.Format(timeLayoutYMD),
+		"previous_last_dates": previous,
+	})
+	return nil
+}
+
+// hasActionForWorkerDateGo reports whether an action of the given type was already logged for the worker and date.
+func hasActionForWorkerDateGo(dao *daos.Dao, actionType string, workerID string, ymd string) bool {
+	var count int
+	err := dao.RecordQuery("action_log").
+		Select("count(*)").
+		AndWhere(dbx.HashExp{"action_type": actionType}).
+		AndWhere(dbx.NewExp("json_extract(details, '$.worker_id') = {:workerId}", dbx.Params{"workerId": workerID})).
+		AndWhere(dbx.NewExp("json_extract(details, '$.date') = {:date}", dbx.Params{"date": ymd})).
+		Row(&count)
+	if err != nil {
+		log.Printf("Error checking for existing '%s' action: %v", actionType, err)
+		return false
+	}
+	return count > 0
+}
+
+// countWorkerActionsSinceGo counts the actions of the given type logged for the worker since the given time.
+func countWorkerActionsSinceGo(dao *daos.Dao, actionType string, workerID string, since time.Time) (int, error) {
+	var count int
+	err := dao.RecordQuery("action_log").
+		Select("count(*)").
+		AndWhere(dbx.HashExp{"action_type": actionType}).
+		AndWhere(dbx.NewExp("timestamp >= {:since}", dbx.Params{"since": since.UTC().Format(timeLayoutFull)})).
+		AndWhere(dbx.NewExp("json_extract(details, '$.worker_id') = {:workerId}", dbx.Params{"workerId": workerID})).
+		Row(&count)
+	return count, err
+}
+
+// checkRepeatOffenderGo flags a worker whose not_done markings within REPEAT_OFFENDER_WINDOW_DAYS exceed
+// REPEAT_OFFENDER_MAX_NOT_DONE: it logs a repeat_offender action and tells the admin, if an admin channel is
+// configured. A worker is flagged at most once per window, so every further not_done doesn't alert again.
+func checkRepeatOffenderGo(dao *daos.Dao, workerID string) error {
+	limit := getRepeatOffenderMaxNotDoneGo()
+	if limit == 0 {
+		return nil
+	}
+	windowDays := getRepeatOffenderWindowDaysGo()
+	since := time.Now().AddDate(0, 0, -windowDays)
+	count, err := countWorkerActionsSinceGo(dao, "marked_not_done", workerID, since)
+	if err != nil {
+		return fmt.Errorf("failed to count not_done markings: %w", err)
+	}
+	if count <= limit {
+		return nil
+	}
+	flagged, err := countWorkerActionsSinceGo(dao, "repeat_offender", workerID, since)
+	if err != nil {
+		return fmt.Errorf("failed to look up earlier repeat_offender actions: %w", err)
+	}
+	if flagged > 0 {
+		return nil
+	}
+
+	workerName := getWorkerNameGo(dao, workerID)
+	log.Printf("Worker %s was marked not_done %d times in the last %d days.", workerName, count, windowDays)
+	logActionGo(dao, "repeat_offender", map[string]interface{}{
+		"worker_id":      workerID,
+		"worker_name":    workerName,
+		"not_done_count": count,
+		"window_days":    windowDays,
+	})
+	if notifierGo.adminConfigured() {
+		go notifierGo.notifyAdmin("Dish duty: repeated not_done", fmt.Sprintf("%s was marked not_done %d times in the last %d days.", workerName, count, windowDays))
+	}
+	return nil
+}
+
+// sendDayBeforeReminderGo tells tomorrow's worker in each active roster, as predicted by the selection core,
+// that they're up next. Each worker is reminded at most once per date.
+func sendDayBeforeReminderGo(dao *daos.Dao) error {
+	if !notifierGo.configured() {
+		return nil
+	}
+	rosters, err := findActiveRostersGo(dao)
+	if err != nil {
+		return fmt.Errorf("failed to fetch rosters: %w", err)
+	}
+	today := getTodayStartGo()
+	for _, roster := range rosters {
+		state, err := loadScheduleStateGo(dao, roster.Id, today, 2)
+		if err != nil {
+			return fmt.Errorf("failed to load schedule state: %w", err)
+		}
+		state.apply(state.pick(today))
+		pick := state.pick(today.AddDate(0, 0, 1))
+		if pick.Worker == nil {
+			log.Printf("No reminder sent for roster %s: nobody is expected on duty tomorrow (%s).", roster.GetString("name"), pick.Skipped)
+			continue
+		}
+		tomorrowYMD := pick.Date.Format(timeLayoutYMD)
+		if hasActionForWorkerDateGo(dao, "reminder_sent", pick.Worker.Id, tomorrowYMD) {
+			continue
+		}
+		if !notifierGo.notifyWorker(pick.Worker, "Dish duty tomorrow", "You're on dish duty tomorrow.") {
+			continue
+		}
+		logActionGo(dao, "reminder_sent", map[string]interface{}{"worker_id": pick.Worker.Id, "worker_name": pick.Worker.GetString("name"), "date": tomorrowYMD})
+	}
+	return nil
+}
+
+// --- Schedule Selection ---
+
+// Reasons a day gets no assignment, as reported by scheduleState.pick and the forecast endpoint.
+const (
+	skipPaused         = "paused"
+	skipBlackout       = "blackout"
+	skipWeekend        = "weekend"
+	skipQueueExhausted = "queue_exhausted"
+	skipNoWorkers      = "no_workers"
+)
+
+// assignmentActionTypes maps an assignment source to the action type logged in the "assigned" entry.
+var assignmentActionTypes = map[string]string{
+	"recurring": "recurring_assigned",
+	"queue":     "queue_processed",
+	"random":    "randomly_assigned",
+}
+
+// scheduleState is an in-memory snapshot of everything the daily selection looks at. pick decides who
+// would be on duty on a day without writing anything; apply advances the snapshot as if that pick had
+// been persisted, so consecutive days can be simulated.
+type scheduleState struct {
+	rosterID            string // every worker, assignment and queue item in the snapshot belongs to this roster
+	settings            AppSettings
+	workers             []*models.Record
+	active              []*models.Record     // workers eligible for new picks
+	lastAssigned        map[string]time.Time // zero = never assigned
+	badLastDate         map[string]bool      // unparsable last_assigned_date, skipped like before
+	latestWeight        map[string]float64
+	queue               []*models.Record
+	recurring           map[time.Weekday][]string // worker ids by priority
+	existing            map[string]*models.Record // YMD -> assignment that still counts (not not_done)
+	onDuty              map[string]string         // YMD -> worker id, including not_done and simulated days
+	blackouts           map[string]bool           // YMD of blackout dates in the snapshot's range
+	roundRobin          bool
+	roundServed         map[string]bool // active workers already on duty in the current round
+	periodStart         time.Time       // start of the current fairness period; zero when FAIRNESS_RESET=never
+	fairnessWindowDays  int             // last turns older than this count as neutral; 0 = no window
+	warnedNoWeekendPool bool            // the missing weekend volunteers warning is logged once per snapshot
+	excluded            map[string]bool // workers left out of new picks, e.g. the one a reset just took off today
+}
+
+// dayPick is the outcome of scheduleState.pick for a single day.
+type dayPick struct {
+	Date          time.Time
+	Worker        *models.Record
+	Source        string
+	Existing      *models.Record
+	QueueItem     *models.Record
+	QueueItemDone bool   // the day is the last of the queue item's span
+	Coverage      string // coverageAM or coveragePM for a pick of half the day; "" for the whole day
+	Skipped       string
+}
+
+// halfDayGo returns the half of the day a queue item or assignment covers, or "" if it covers the whole day.
+func halfDayGo(record *models.Record) string {
+	if coverage := record.GetString("coverage"); coverage == coverageAM || coverage == coveragePM {
+		return coverage
+	}
+	return ""
+}
+
+// recordCoverageGo returns the coverage of a queue item or assignment, coverageFull if it has none.
+func recordCoverageGo(record *models.Record) string {
+	if half := halfDayGo(record); half != "" {
+		return half
+	}
+	return coverageFull
+}
+
+// otherHalfGo returns the half of the day that half leaves to someone else.
+func otherHalfGo(half string) string {
+	if half == coverageAM {
+		return coveragePM
+	}
+	return coverageAM
+}
+
+// dutyTimeLabelGo words when a duty of the given half ("" = whole day) is, for announcements.
+func dutyTimeLabelGo(half string) string {
+	switch half {
+	case coverageAM:
+		return "this morning"
+	case coveragePM:
+		return "this afternoon"
+	}
+	return "today"
+}
+
+// loadScheduleStateGo snapshots the data needed to pick a roster's workers for the days days starting at
+// from (UTC midnight).
+func loadScheduleStateGo(dao *daos.Dao, rosterID string, from time.Time, days int) (*scheduleState, error) {
+	state := &scheduleState{
+		rosterID:           rosterID,
+		settings:           getSettingsGo(dao),
+		lastAssigned:       map[string]time.Time{},
+		badLastDate:        map[string]bool{},
+		latestWeight:       map[string]float64{},
+		recurring:          map[time.Weekday][]string{},
+		existing:           map[string]*models.Record{},
+		onDuty:             map[string]string{},
+		blackouts:          map[string]bool{},
+		roundRobin:         getSelectionModeGo() == selectionModeRoundRobin,
+		roundServed:        map[string]bool{},
+		fairnessWindowDays: getFairnessWindowDaysGo(),
+		excluded:           map[string]bool{},
+	}
+	if getFairnessResetGo() == fairnessResetMonthly {
+		state.periodStart = monthStartGo(from)
+	}
+
+	workers, err := rosterWorkersGo(dao, rosterID)
+	if err != nil {
+		return nil, err
+	}
+	state.workers = workers
+	lookback := 0
+	for _, worker := range workers {
+		if !worker.GetBool("inactive") {
+			state.active = append(state.active, worker)
+		}
+		if lad := worker.GetString("last_assigned_date"); lad != "" {
+			ladTime, parseErr := time.Parse(timeLayoutFull, lad)
+			if parseErr != nil {
+				log.Printf("Error parsing last_assigned_date '%s' for worker %s: %v. Skipping.", lad, worker.GetString("name"), parseErr)
+				state.badLastDate[worker.Id] = true
+			}
+			state.lastAssigned[worker.Id] = ladTime
+		}
+		if state.settings.FairnessUseWeights {
+			state.latestWeight[worker.Id] = getLatestAssignmentWeightGo(dao, worker.Id)
+		}
+		if maxConsecutive := worker.GetInt("max_consecutive_days"); maxConsecutive > lookback {
+			lookback = maxConsecutive
+		}
+	}
+
+	if err := dao.RecordQuery("assignment_queue").AndWhere(rosterExpGo(rosterID)).OrderBy(queueOrderColumns...).All(&state.queue); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to load queue: %w", err)
+	}
+
+	// Rules of workers from other rosters are dropped by pick, which only knows this roster's workers.
+	rules := []*models.Record{}
+	if err := dao.RecordQuery("recurring_assignments").OrderBy("priority ASC", "created ASC").All(&rules); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to load recurring assignments: %w", err)
+	}
+	for _, rule := range rules {
+		for weekday, name := range weekdayNames {
+			if rule.GetString("weekday") == name {
+				state.recurring[time.Weekday(weekday)] = append(state.recurring[time.Weekday(weekday)], rule.GetString("worker_id"))
+			}
+		}
+	}
+
+	assignments := []*models.Record{}
+	err = dao.RecordQuery("assignments").
+		AndWhere(dayRangeExpGo("date", from.AddDate(0, 0, -lookback), from.AddDate(0, 0, days-1))).
+		AndWhere(rosterExpGo(rosterID)).
+		All(&assignments)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to load assignments: %w", err)
+	}
+	for _, assignment := range assignments {
+		ymd := assignment.GetDateTime("date").Time().Format(timeLayoutYMD)
+		state.onDuty[ymd] = assignment.GetString("worker_id")
+		if assignment.GetString("status") != "not_done" {
+			state.existing[ymd] = assignment
+		}
+	}
+
+	blackouts, err := findBlackoutsGo(dao, from, from.AddDate(0, 0, days-1))
+	if err != nil {
+		return nil, err
+	}
+	for _, blackout := range blackouts {
+		state.blackouts[blackout.Date] = true
+	}
+
+	if state.roundRobin {
+		if err := state.loadCurrentRoundGo(dao, from); err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
+
+// roundRobinCursor is the round_robin position stored on each roster, so a restart resumes the rotation
+// from where it was instead of rebuilding it from a bounded slice of history.
+type roundRobinCursor struct {
+	Through      string   `json:"through"` // YYYY-MM-DD of the last assignment the cursor accounts for
+	LastWorkerID string   `json:"last_worker_id"`
+	Served       []string `json:"served"` // workers already on duty in the current round
+}
+
+// readRoundRobinCursorGo returns the roster's persisted cursor, or nil if there is none yet.
+func readRoundRobinCursorGo(dao *daos.Dao, rosterID string) (*roundRobinCursor, error) {
+	record, err := dao.FindRecordById("rosters", rosterID)
+	if err != nil {
+		return nil, err
+	}
+	cursor := &roundRobinCursor{}
+	if err := record.UnmarshalJSONField("round_robin_cursor", cursor); err != nil || cursor.Through == "" {
+		return nil, nil
+	}
+	return cursor, nil
+}
+
+// saveRoundRobinCursorGo persists the current round of st on its roster after workerID was assigned on day.
+func saveRoundRobinCursorGo(dao *daos.Dao, st *scheduleState, day time.Time, workerID string) error {
+	record, err := dao.FindRecordById("rosters", st.rosterID)
+	if err != nil {
+		return fmt.Errorf("failed to look up roster %s: %w", st.rosterID, err)
+	}
+	served := []string{}
+	for id := range st.roundServed {
+		served = append(served, id)
+	}
+	sort.Strings(served)
+	record.Set("round_robin_cursor", roundRobinCursor{Through: day.Format(timeLayoutYMD), LastWorkerID: workerID, Served: served})
+	if err := dao.SaveRecord(record); err != nil {
+		return fmt.Errorf("failed to save round_robin cursor: %w", err)
+	}
+	return nil
+}
+
+// loadCurrentRoundGo finds who was already on duty in the current round: it starts from the persisted
+// cursor when that is still usable and replays the assignments between it (or the period start) and from.
+func (st *scheduleState) loadCurrentRoundGo(dao *daos.Dao, from time.Time) error {
+	history := []*models.Record{}
+	query := dao.RecordQuery("assignments").
+		AndWhere(dbx.NewExp("date < {:from}", dbx.Params{"from": from.Format(timeLayoutYMD)})).
+		AndWhere(rosterExpGo(st.rosterID))
+	cursor, err := readRoundRobinCursorGo(dao, st.rosterID)
+	if err != nil {
+		log.Printf("Error reading round_robin cursor, replaying history instead: %v", err)
+	}
+	var through time.Time
+	if cursor != nil {
+		through, err = time.Parse(timeLayoutYMD, cursor.Through)
+		// A cursor from a past fairness period, or at or after from (a preview of earlier days), doesn't apply.
+		if err != nil || !through.Before(from) || (!st.periodStart.IsZero() && through.Before(st.periodStart)) {
+			cursor = nil
+		}
+	}
+	if cursor != nil {
+		for _, workerID := range cursor.Served {
+			if worker := st.findWorker(workerID); worker != nil && !worker.GetBool("inactive") {
+				st.markServed(workerID)
+			}
+		}
+		// Assignments written after the cursor (backfills, manual edits) are still replayed on top of it.
+		query = query.AndWhere(dbx.NewExp("date >= {:after}", dbx.Params{"after": through.AddDate(0, 0, 1).Format(timeLayoutYMD)}))
+	} else if !st.periodStart.IsZero() {
+		query = query.AndWhere(dbx.NewExp("date >= {:periodStart}", dbx.Params{"periodStart": st.periodStart.Format(timeLayoutYMD)}))
+	}
+	err = query.OrderBy("date DESC").Limit(366).All(&history)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to load assignment history: %w", err)
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		workerID := history[i].GetString("worker_id")
+		if worker := st.findWorker(workerID); worker != nil && !worker.GetBool("inactive") {
+			st.markServed(workerID)
+		}
+	}
+	return nil
+}
+
+// startsNewPeriod reports whether day falls after the current fairness period, i.e. a simulated day
+// crossed a FAIRNESS_RESET boundary.
+func (st *scheduleState) startsNewPeriod(day time.Time) bool {
+	return !st.periodStart.IsZero() && monthStartGo(day).After(st.periodStart)
+}
+
+// markServed records workerID in the current round, starting a new round once everyone active had a turn.
+func (st *scheduleState) markServed(workerID string) {
+	if !st.roundRobin {
+		return
+	}
+	st.roundServed[workerID] = true
+	for _, worker := range st.active {
+		if !st.roundServed[worker.Id] {
+			return
+		}
+	}
+	st.roundServed = map[string]bool{}
+}
+
+func (st *scheduleState) findWorker(workerID string) *models.Record {
+	for _, worker := range st.workers {
+		if worker.Id == workerID {
+			return worker
+		}
+	}
+	return nil
+}
+
+// consecutiveDaysBefore counts how many days in a row the worker is on duty right before day, up to limit.
+func (st *scheduleState) consecutiveDaysBefore(workerID string, day time.Time, limit int) int {
+	count := 0
+	for count < limit && st.onDuty[day.AddDate(0, 0, -(count+1)).Format(timeLayoutYMD)] == workerID {
+		count++
+	}
+	return count
+}
+
+// pick decides who is on duty on day: an existing assignment, a recurring rule, the first due queue
+// item, or the worker who has waited longest (restricted to the current round in round_robin mode and to
+// weekend volunteers on weekends).
+func (st *scheduleState) pick(day time.Time) dayPick {
+	ymd := day.Format(timeLayoutYMD)
+	if existing, ok := st.existing[ymd]; ok {
+		return dayPick{Date: day, Worker: st.findWorker(existing.GetString("worker_id")), Source: existing.GetString("source"), Existing: existing}
+	}
+	if st.blackouts[ymd] {
+		return dayPick{Date: day, Skipped: skipBlackout}
+	}
+	if st.settings.Paused {
+		return dayPick{Date: day, Skipped: skipPaused}
+	}
+	if st.settings.SkipWeekends && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) {
+		return dayPick{Date: day, Skipped: skipWeekend}
+	}
+
+	// Standing weekday assignments take precedence over the queue and the random rotation.
+	for _, workerID := range st.recurring[day.Weekday()] {
+		if worker := st.findWorker(workerID); worker != nil && !worker.GetBool("inactive") && !st.excluded[worker.Id] {
+			return dayPick{Date: day, Worker: worker, Source: "recurring"}
+		}
+	}
+
+	// A pinned item owns its dates, whatever is next in line.
+	for _, item := range st.queue {
+		if !item.GetBool("pinned") {
+			continue
+		}
+		itemStart, itemEnd := queueItemSpanGo(item)
+		if day.Before(itemStart) || day.After(itemEnd) {
+			continue
+		}
+		worker := st.findWorker(item.GetString("worker_id"))
+		if worker == nil || worker.GetBool("inactive") || st.excluded[worker.Id] {
+			continue
+		}
+		return dayPick{Date: day, Worker: worker, Source: "queue", QueueItem: item, QueueItemDone: !day.Before(itemEnd), Coverage: halfDayGo(item)}
+	}
+
+	for _, item := range st.queue {
+		if item.GetBool("pinned") {
+			continue
+		}
+		itemStart := item.GetDateTime("start_date").Time()
+		if itemStart.After(day) {
+			continue
+		}
+		worker := st.findWorker(item.GetString("worker_id"))
+		if worker == nil {
+			log.Printf("Queue item %s references missing worker %s.", item.Id, item.GetString("worker_id"))
+			continue
+		}
+		if worker.GetBool("inactive") || st.excluded[worker.Id] {
+			continue
+		}
+		// Queue items may have been edited directly, so re-check the worker's cap before assigning.
+		if maxConsecutive := worker.GetInt("max_consecutive_days"); maxConsecutive > 0 && st.consecutiveDaysBefore(worker.Id, day, maxConsecutive) >= maxConsecutive {
+			log.Printf("Worker %s has reached their limit of consecutive days. Leaving queue item %s for later.", worker.GetString("name"), item.Id)
+			continue
+		}
+		itemEnd := itemStart.AddDate(0, 0, item.GetInt("duration_days")-1)
+		return dayPick{Date: day, Worker: worker, Source: "queue", QueueItem: item, QueueItemDone: !day.Before(itemEnd), Coverage: halfDayGo(item)}
+	}
+
+	if st.settings.OnEmptyQueue == onEmptyQueueStop {
+		return dayPick{Date: day, Skipped: skipQueueExhausted}
+	}
+	if len(st.active) == 0 {
+		return dayPick{Date: day, Skipped: skipNoWorkers}
+	}
+
+	candidates := st.active
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		candidates = st.weekendPool(day)
+	}
+
+	chosenWorker := candidates[0]
+	if ranked := st.rankCandidates(day, candidates); len(ranked) > 0 {
+		chosenWorker = ranked[0].Worker
+	}
+	return dayPick{Date: day, Worker: chosenWorker, Source: "random"}
+}
+
+// pickHalf decides who covers one half of day next to partner, who has (or is about to get) the other
+// half: the first queue item for that half due by day, otherwise the fairness rotation without partner,
+// and partner itself if nobody else is available.
+func (st *scheduleState) pickHalf(day time.Time, half string, partner *models.Record) dayPick {
+	for _, item := range st.queue {
+		if halfDayGo(item) != half {
+			continue
+		}
+		itemStart, itemEnd := queueItemSpanGo(item)
+		if itemStart.After(day) || (item.GetBool("pinned") && day.After(itemEnd)) {
+			continue
+		}
+		worker := st.findWorker(item.GetString("worker_id"))
+		if worker == nil || worker.GetBool("inactive") || st.excluded[worker.Id] {
+			continue
+		}
+		return dayPick{Date: day, Worker: worker, Source: "queue", QueueItem: item, QueueItemDone: !day.Before(itemEnd), Coverage: half}
+	}
+
+	if st.settings.OnEmptyQueue == onEmptyQueueStop {
+		return dayPick{Date: day, Skipped: skipQueueExhausted, Coverage: half}
+	}
+	pool := st.active
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		pool = st.weekendPool(day)
+	}
+	candidates := []*models.Record{}
+	for _, worker := range pool {
+		if partner == nil || worker.Id != partner.Id {
+			candidates = append(candidates, worker)
+		}
+	}
+	if ranked := st.rankCandidates(day, candidates); len(ranked) > 0 {
+		return dayPick{Date: day, Worker: ranked[0].Worker, Source: "random", Coverage: half}
+	}
+	if partner != nil && !partner.GetBool("inactive") {
+		return dayPick{Date: day, Worker: partner, Source: "random", Coverage: half}
+	}
+	return dayPick{Date: day, Skipped: skipNoWorkers, Coverage: half}
+}
+
+// Reasons a candidate is left out of the fairness ranking, as reported by the rotation-order endpoint.
+const (
+	rankServedThisRound = "served_this_round"
+	rankBadLastDate     = "invalid_last_assigned_date"
+	rankExcluded        = "excluded"
+)
+
+// rankedWorker is a candidate of the fairness pick with the last turn it is compared by.
+type rankedWorker struct {
+	Worker       *models.Record
+	LastAssigned time.Time // effective last turn; zero = never assigned
+}
+
+// fairnessLessGo reports whether a goes before b in the fairness pick: whoever has waited longest, ties
+// to the lower priority. Workers still tied keep their order, i.e. whoever was added first.
+func fairnessLessGo(a, b rankedWorker) bool {
+	if !a.LastAssigned.Equal(b.LastAssigned) {
+		return a.LastAssigned.Before(b.LastAssigned)
+	}
+	return a.Worker.GetInt("priority") < b.Worker.GetInt("priority")
+}
+
+// rankCandidates orders the candidates for day the way pick chooses among them, most eligible first.
+// Candidates that can't be picked (see excludedReason) are left out.
+func (st *scheduleState) rankCandidates(day time.Time, candidates []*models.Record) []rankedWorker {
+	var windowStart, neutralDate time.Time
+	hasNeutral := false
+	if st.fairnessWindowDays > 0 {
+		windowStart = day.AddDate(0, 0, -st.fairnessWindowDays)
+		neutralDate, hasNeutral = st.neutralLastAssigned(candidates, windowStart)
+	}
+	ranked := make([]rankedWorker, 0, len(candidates))
+	for _, worker := range candidates {
+		if st.excludedReason(worker, day) != "" {
+			continue
+		}
+		lastAssigned, assigned := st.lastAssigned[worker.Id]
+		if assigned && !st.periodStart.IsZero() && lastAssigned.Before(monthStartGo(day)) {
+			assigned = false // last turn was in an earlier fairness period
+		}
+		if !assigned {
+			lastAssigned = time.Time{} // never assigned sorts before any date
+		} else if hasNeutral && lastAssigned.Before(windowStart) {
+			// A turn from before the window says nothing about the current rotation, so a long-absent worker
+			// slots into the middle of it instead of jumping to the front.
+			lastAssigned = neutralDate
+		} else if st.settings.FairnessUseWeights {
+			// A weight-2 day counts as two days of duty, so the worker's next turn comes a day later. Turns are
+			// whole days, so fractional weights round to the nearest day.
+			extraDays := st.latestWeight[worker.Id] - 1
+			lastAssigned = lastAssigned.AddDate(0, 0, int(math.Round(extraDays)))
+		}
+		if bias := selectionBiasDaysGo(worker, day); assigned && bias != 0 {
+			lastAssigned = lastAssigned.AddDate(0, 0, -bias)
+		}
+		ranked = append(ranked, rankedWorker{Worker: worker, LastAssigned: lastAssigned})
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return fairnessLessGo(ranked[i], ranked[j]) })
+	return ranked
+}
+
+// excludedReason returns why worker can't be picked by the fairness rotation on day, or "" if it can.
+func (st *scheduleState) excludedReason(worker *models.Record, day time.Time) string {
+	if st.excluded[worker.Id] {
+		return rankExcluded
+	}
+	if st.roundRobin && st.roundServed[worker.Id] && !st.startsNewPeriod(day) {
+		return rankServedThisRound
+	}
+	if st.badLastDate[worker.Id] && !st.roundRobin {
+		return rankBadLastDate
+	}
+	return ""
+}
+
+// neutralLastAssigned returns the average last turn of the candidates whose last turn falls inside the
+// fairness window, or false if none does.
+func (st *scheduleState) neutralLastAssigned(candidates []*models.Record, windowStart time.Time) (time.Time, bool) {
+	var total int64
+	count := 0
+	for _, worker := range candidates {
+		lastAssigned, assigned := st.lastAssigned[worker.Id]
+		if !assigned || st.badLastDate[worker.Id] || lastAssigned.Before(windowStart) {
+			continue
+		}
+		total += lastAssigned.Unix()
+		count++
+	}
+	if count == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(total/int64(count), 0).UTC(), true
+}
+
+// selectionBiasDaysGo returns the worker's selection_bias if it is still in effect on day, otherwise 0.
+func selectionBiasDaysGo(worker *models.Record, day time.Time) int {
+	until := worker.GetDateTime("selection_bias_until").Time()
+	if until.IsZero() || day.After(until) {
+		return 0
+	}
+	return worker.GetInt("selection_bias")
+}
+
+// weekendPool returns the active workers who volunteered for weekends, or every active worker if nobody did.
+func (st *scheduleState) weekendPool(day time.Time) []*models.Record {
+	volunteers := []*models.Record{}
+	for _, worker := range st.active {
+		if worker.GetBool("weekend_ok") {
+			volunteers = append(volunteers, worker)
+		}
+	}
+	if len(volunteers) == 0 {
+		if st.warnedNoWeekendPool {
+			return st.active
+		}
+		st.warnedNoWeekendPool = true
+		log.Printf("Warning: no active worker is marked weekend_ok. Picking from all workers for %s.", day.Format(timeLayoutYMD))
+		return st.active
+	}
+	return volunteers
+}
+
+// apply advances the snapshot as if p had been persisted.
+func (st *scheduleState) apply(p dayPick) {
+	if p.Worker == nil {
+		return
+	}
+	st.onDuty[p.Date.Format(timeLayoutYMD)] = p.Worker.Id
+	if st.startsNewPeriod(p.Date) {
+		st.periodStart = monthStartGo(p.Date)
+		st.roundServed = map[string]bool{}
+	}
+	st.markServed(p.Worker.Id)
+	if p.Existing != nil {
+		return
+	}
+	st.lastAssigned[p.Worker.Id] = p.Date
+	delete(st.badLastDate, p.Worker.Id)
+	st.latestWeight[p.Worker.Id] = 1
+	if p.QueueItem != nil && p.QueueItemDone {
+		for i, item := range st.queue {
+			if item.Id == p.QueueItem.Id {
+				st.queue = append(st.queue[:i], st.queue[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// resetTodayAssignmentGo deletes today's assignment and rolls its worker's last_assigned_date back to the
+// value it replaced, so the worker doesn't lose their place in the rotation.
+func resetTodayAssignmentGo(dao *daos.Dao, assignment *models.Record) error {
+	workerID := assignment.GetString("worker_id")
+	previous := assignment.GetString("previous_last_assigned_date")
+	if previous == "" {
+		// Assignments created before the previous date was recorded: fall back to the worker's latest earlier one.
+		earlier := &models.Record{}
+		err := dao.RecordQuery("assignments").
+			AndWhere(dbx.HashExp{"worker_id": workerID}).
+			AndWhere(dbx.NewExp("date < {:date}", dbx.Params{"date": assignment.GetDateTime("date").Time().Format(timeLayoutYMD)})).
+			OrderBy("date DESC").
+			Limit(1).
+			One(earlier)
+		if err == nil {
+			previous = earlier.GetString("date")
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to find the worker's previous assignment: %w", err)
+		}
+	}
+
+	return dao.RunInTransaction(func(txDao *daos.Dao) error {
+		if err := restoreLastAssignedDateGo(txDao, workerID, assignment.GetDateTime("date").Time(), previous); err != nil {
+			return err
+		}
+		if err := txDao.DeleteRecord(assignment); err != nil {
+			return fmt.Errorf("failed to delete assignment: %w", err)
+		}
+		logActionGo(txDao, "today_reset", map[string]interface{}{
+			"assignment_id":      assignment.Id,
+			"worker_id":          workerID,
+			"worker_name":        getWorkerNameGo(txDao, workerID),
+			"date":               assignment.GetDateTime("date").Time().Format(timeLayoutYMD),
+			"last_assigned_date": previous,
+		})
+		return nil
+	})
+}
+
+// restoreLastAssignedDateGo rolls a worker's last_assigned_date back to previous when their assignment on day
+// goes away. Nothing changes if something else has moved the date since that assignment set it, or if the
+// worker is gone.
+func restoreLastAssignedDateGo(txDao *daos.Dao, workerID string, day time.Time, previous string) error {
+	worker, err := txDao.FindRecordById("workers", workerID)
+	if err != nil || !worker.GetDateTime("last_assigned_date").Time().Equal(day) {
+		return nil
+	}
+	worker.Set("last_assigned_date", previous)
+	if err := txDao.SaveRecord(worker); err != nil {
+		return fmt.Errorf("failed to restore last_assigned_date: %w", err)
+	}
+	return nil
+}
+
+// acceptQueueAssignmentGo confirms a pending_acceptance assignment. Its queue item is consumed if the
+// assignment covers the item's last day, as it would have been without acceptance.
+func acceptQueueAssignmentGo(dao *daos.Dao, assignment *models.Record) error {
+	return dao.RunInTransaction(func(txDao *daos.Dao) error {
+		day := assignment.GetDateTime("date").Time()
+		if itemID := assignment.GetString("queue_item_id"); itemID != "" {
+			if item, err := txDao.FindRecordById("assignment_queue", itemID); err == nil {
+				if _, itemEnd := queueItemSpanGo(item); !day.Before(itemEnd) {
+					if err := txDao.DeleteRecord(item); err != nil {
+						return fmt.Errorf("failed to consume queue item %s: %w", itemID, err)
+					}
+				}
 			}
+		}
+		assignment.Set("status", "assigned")
+		assignment.Set("acceptance_token", "")
+		if err := txDao.SaveRecord(assignment); err != nil {
+			return fmt.Errorf("failed to save assignment: %w", err)
+		}
+		logActionGo(txDao, "queue_accepted", map[string]interface{}{
+			"assignment_id": assignment.Id,
+			"queue_item_id": assignment.GetString("queue_item_id"),
+			"worker_id":     assignment.GetString("worker_id"),
+			"worker_name":   getWorkerNameGo(txDao, assignment.GetString("worker_id")),
+			"date":          day.Format(timeLayoutYMD),
+		})
+		return nil
+	})
+}
+
+// declineQueueAssignmentGo drops a pending_acceptance assignment together with its queue item, so the day
+// goes to the next item in line, and rolls the worker's last_assigned_date back like a reset does.
+func declineQueueAssignmentGo(dao *daos.Dao, assignment *models.Record) error {
+	return dao.RunInTransaction(func(txDao *daos.Dao) error {
+		workerID := assignment.GetString("worker_id")
+		if err := restoreLastAssignedDateGo(txDao, workerID, assignment.GetDateTime("date").Time(), assignment.GetString("previous_last_assigned_date")); err != nil {
+			return err
+		}
+		itemID := assignment.GetString("queue_item_id")
+		if item, err := txDao.FindRecordById("assignment_queue", itemID); err == nil {
+			if err := txDao.DeleteRecord(item); err != nil {
+				return fmt.Errorf("failed to drop queue item %s: %w", itemID, err)
+			}
+		}
+		if err := txDao.DeleteRecord(assignment); err != nil {
+			return fmt.Errorf("failed to delete assignment: %w", err)
+		}
+		logActionGo(txDao, "queue_declined", map[string]interface{}{
+			"assignment_id": assignment.Id,
+			"queue_item_id": itemID,
+			"worker_id":     workerID,
+			"worker_name":   getWorkerNameGo(txDao, workerID),
+			"date":          assignment.GetDateTime("date").Time().Format(timeLayoutYMD),
+		})
+		return nil
+	})
+}
+
+// --- Daily Assignment Logic ---
+
+// errConcurrentUpdate means a record changed between being read and being written, e.g. a status PATCH
+// racing the daily reassignment. The write is rolled back and can be retried with fresh data.
+var errConcurrentUpdate = errors.New("record was changed concurrently")
+
+// concurrentUpdateAttempts is how often a write that lost such a race is tried in total.
+const concurrentUpdateAttempts = 3
+
+// claimRecordGo bumps record's updated timestamp, but only if the stored row still has the one record was
+// read with. Called first inside a transaction, it makes the rest of the transaction conditional on nobody
+// having changed or deleted the record in between.
+func claimRecordGo(txDao *daos.Dao, record *models.Record) error {
+	result, err := txDao.DB().Update(
+		record.TableName(),
+		dbx.Params{"updated": types.NowDateTime().String()},
+		dbx.HashExp{"id": record.Id, "updated": record.Updated.String()},
+	).Execute()
+	if err != nil {
+		return fmt.Errorf("failed to claim %s record %s: %w", record.TableName(), record.Id, err)
+	}
+	if claimed, _ := result.RowsAffected(); claimed == 0 {
+		return errConcurrentUpdate
+	}
+	return nil
+}
+
+// ensureDailyAssignmentGo makes sure every active roster has today assigned. A failing roster doesn't stop
+// the others; their errors are returned together.
+func ensureDailyAssignmentGo(dao *daos.Dao) error {
+	log.Println("ensureDailyAssignmentGo: Checking for today's assignment...")
+	todayStart := getTodayStartGo() // today in APP_TIMEZONE, stored as UTC midnight
+	todayYMD := todayStart.Format(timeLayoutYMD)
+
+	if snoozeUntil := getSettingsGo(dao).SnoozeUntil; snoozeUntil >= todayYMD {
+		log.Printf("ensureDailyAssignmentGo: Snoozed through %s. Not creating an assignment for %s.", snoozeUntil, todayYMD)
+		return nil
+	}
+
+	rosters, err := findActiveRostersGo(dao)
+	if err != nil {
+		return fmt.Errorf("failed to fetch rosters: %w", err)
+	}
+	var errs []error
+	for _, roster := range rosters {
+		err := ensureRosterDailyAssignmentGo(dao, roster, todayStart)
+		for attempt := 1; errors.Is(err, errConcurrentUpdate) && attempt < concurrentUpdateAttempts; attempt++ {
+			log.Printf("ensureDailyAssignmentGo: Today's assignment for roster %s changed meanwhile. Checking again.", roster.GetString("name"))
+			err = ensureRosterDailyAssignmentGo(dao, roster, todayStart)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("roster %s: %w", roster.GetString("name"), err))
+			continue
+		}
+		// Today may just have used up a queue item.
+		if _, err := topUpQueueGo(dao, roster.Id); err != nil {
+			log.Printf("ensureDailyAssignmentGo: Error topping up the queue of roster %s: %v", roster.GetString("name"), err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ensureRosterDailyAssignmentGo assigns today for one roster, replacing a not_done assignment. On a day
+// shared by two half-day assignments only the not_done half is replaced.
+func ensureRosterDailyAssignmentGo(dao *daos.Dao, roster *models.Record, todayStart time.Time) error {
+	todayYMD := todayStart.Format(timeLayoutYMD)
+
+	// Check for existing assignments for today, by date range and by the exact value saved below
+	existingAssignments := []*models.Record{}
+	err := dao.RecordQuery("assignments").
+		AndWhere(dbx.And(rosterExpGo(roster.Id), sameDayExpGo("date", todayStart))).
+		OrderBy("coverage ASC"). // am before pm
+		All(&existingAssignments)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check today's assignment: %w", err)
+	}
+	if len(existingAssignments) == 0 {
+		log.Printf("ensureDailyAssignmentGo: No assignment found for today (%s). Proceeding to assign.", todayYMD)
+		return assignRosterDayGo(dao, roster, todayStart, nil, "")
+	}
+	for _, existingAssignment := range existingAssignments {
+		log.Printf("ensureDailyAssignmentGo: Assignment for today (%s) already exists (ID: %s). Status: %s", todayYMD, existingAssignment.Id, existingAssignment.GetString("status"))
+		if existingAssignment.GetString("status") != "not_done" {
+			continue
+		}
+		log.Printf("ensureDailyAssignmentGo: Today's assignment (%s) was 'not_done'. Reassigning.", todayYMD)
+		// The pick below already ignores not_done days, so the record only goes once its replacement is saved.
+		if err := assignRosterDayGo(dao, roster, todayStart, existingAssignment, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assignRosterDayGo picks and saves today's assignment for one roster: one for the whole day, or two when
+// the pick is a half-day queue item and someone else takes the other half. replacedAssignment is today's
+// not_done assignment as it was read, or nil; it is deleted only if it is still unchanged, otherwise
+// nothing is written and errConcurrentUpdate is returned. A replaced half only has that half picked again.
+// excludedWorkerID, if set, is left out of the pick unless nobody else can take the day.
+func assignRosterDayGo(dao *daos.Dao, roster *models.Record, todayStart time.Time, replacedAssignment *models.Record, excludedWorkerID string) error {
+	todayYMD := todayStart.Format(timeLayoutYMD)
+	existingAssignmentFilter := dbx.And(rosterExpGo(roster.Id), sameDayExpGo("date", todayStart))
+	reassignedFromWorkerID := ""
+	reassignedFromAssignmentID := ""
+	replacedHalf := ""
+	if replacedAssignment != nil {
+		reassignedFromWorkerID = replacedAssignment.GetString("worker_id")
+		reassignedFromAssignmentID = replacedAssignment.Id
+		replacedHalf = halfDayGo(replacedAssignment)
+	}
+
+	state, err := loadScheduleStateGo(dao, roster.Id, todayStart, 1)
+	if err != nil {
+		log.Printf("ensureDailyAssignmentGo: Error loading schedule state: %v", err)
+		return fmt.Errorf("failed to load schedule state: %w", err)
+	}
+	if excludedWorkerID != "" {
+		state.excluded[excludedWorkerID] = true
+	}
+	var pick dayPick
+	if replacedHalf != "" {
+		// The other half, if still open, is the only assignment left in the snapshot.
+		var partner *models.Record
+		if other, ok := state.existing[todayYMD]; ok {
+			partner = state.findWorker(other.GetString("worker_id"))
+		}
+		pick = state.pickHalf(todayStart, replacedHalf, partner)
+	} else {
+		pick = state.pick(todayStart)
+	}
+	switch pick.Skipped {
+	case "":
+	case skipNoWorkers:
+		log.Println("ensureDailyAssignmentGo: No workers available to assign.")
+		return fmt.Errorf("no workers available to assign for %s", todayYMD)
+	default:
+		log.Printf("ensureDailyAssignmentGo: No assignment created for %s (%s).", todayYMD, pick.Skipped)
+		return nil
+	}
+	if pick.Existing != nil {
+		// Today was still not_done when it was read, so it has been changed (or replaced) since.
+		if replacedAssignment != nil {
+			return errConcurrentUpdate
+		}
+		return nil
+	}
+
+	picks := []dayPick{pick}
+	state.apply(pick)
+	if pick.Coverage != "" && replacedHalf == "" {
+		// A half-day pick shares the day; without anyone for the other half it stays open.
+		if other := state.pickHalf(todayStart, otherHalfGo(pick.Coverage), pick.Worker); other.Worker != nil {
+			picks = append(picks, other)
+			state.apply(other)
 		} else {
-			log.Println("ensureDailyAssignmentGo: No workers available to assign.")
-			return fmt.Errorf("no workers available to assign for %s", todayYMD)
+			log.Printf("ensureDailyAssignmentGo: Nobody covers the other half of %s (%s).", todayYMD, other.Skipped)
 		}
 	}
 
+	previousLastAssigned := make([]string, len(picks))
+	// With REQUIRE_QUEUE_ACCEPTANCE a queued day stays pending, and its item stays queued, until the worker answers.
+	pendingAcceptance := make([]bool, len(picks))
+	for i, p := range picks {
+		log.Printf("ensureDailyAssignmentGo: Assigning worker %s (ID: %s) for %s. Source: %s.", p.Worker.GetString("name"), p.Worker.Id, todayYMD, p.Source)
+		previousLastAssigned[i] = p.Worker.GetString("last_assigned_date")
+		pendingAcceptance[i] = p.QueueItem != nil && queueAcceptanceRequiredGo()
+	}
+
 	assignmentsCollection, _ := dao.FindCollectionByNameOrId("assignments")
-	newAssignment := models.NewRecord(assignmentsCollection)
-	newAssignment.Set("worker_id", workerToAssign.Id)
-	newAssignment.Set("date", todayStart.Format(timeLayoutYMD))
-	newAssignment.Set("status", "assigned")
-	if err := dao.SaveRecord(newAssignment); err != nil {
-		log.Printf("ensureDailyAssignmentGo: Error saving new assignment for %s on %s: %v", workerToAssign.GetString("name"), todayYMD, err)
-		return fmt.Errorf("failed to save new assignment: %w", err)
-	}
-	log.Printf("ensureDailyAssignmentGo: Assigned worker %s (ID: %s) for %s. Source: %s. ID: %s", workerToAssign.GetString("name"), workerToAssign.Id, todayYMD, assignmentSource, newAssignment.Id)
-	logActionGo(dao, "assigned", map[string]interface{}{"worker_id": workerToAssign.Id, "worker_name": workerToAssign.GetString("name"), "date": todayYMD, "source": assignmentSource})
+	newAssignments := make([]*models.Record, len(picks))
+	err = dao.RunInTransaction(func(txDao *daos.Dao) error {
+		if replacedAssignment != nil {
+			// A status change since it was read (or another run replacing it first) wins.
+			if err := claimRecordGo(txDao, replacedAssignment); err != nil {
+				return err
+			}
+			if err := txDao.DeleteRecord(replacedAssignment); err != nil {
+				return fmt.Errorf("failed to delete 'not_done' assignment %s: %w", replacedAssignment.Id, err)
+			}
+		}
+		// Another run may have assigned today (or the replaced half) while this one was picking.
+		meanwhileQuery := txDao.RecordQuery("assignments").Select("count(*)").AndWhere(existingAssignmentFilter)
+		if replacedHalf != "" {
+			meanwhileQuery = meanwhileQuery.AndWhere(dbx.In("coverage", replacedHalf, coverageFull, ""))
+		}
+		var assignedMeanwhile int
+		if err := meanwhileQuery.Row(&assignedMeanwhile); err != nil {
+			return fmt.Errorf("failed to re-check today's assignment: %w", err)
+		}
+		if assignedMeanwhile > 0 {
+			return errConcurrentUpdate
+		}
+		for i, p := range picks {
+			p.Worker.Set("last_assigned_date", todayStart.Format(timeLayoutFull))
+			if err := txDao.SaveRecord(p.Worker); err != nil {
+				return fmt.Errorf("failed to update last_assigned_date for worker %s: %w", p.Worker.GetString("name"), err)
+			}
+			// A queue item covers its whole span and is only consumed on its last day.
+			if p.QueueItem != nil && p.QueueItemDone && !pendingAcceptance[i] {
+				if err := txDao.DeleteRecord(p.QueueItem); err != nil {
+					return fmt.Errorf("failed to delete queue item %s: %w", p.QueueItem.Id, err)
+				}
+			}
+
+			newAssignment := models.NewRecord(assignmentsCollection)
+			newAssignment.Set("roster_id", roster.Id)
+			newAssignment.Set("worker_id", p.Worker.Id)
+			newAssignment.Set("date", todayStart.Format(timeLayoutFull)) // same format the day-range queries compare against
+			newAssignment.Set("coverage", coverageFull)
+			if p.Coverage != "" {
+				newAssignment.Set("coverage", p.Coverage)
+			}
+			newAssignment.Set("status", "assigned")
+			if pendingAcceptance[i] {
+				newAssignment.Set("status", "pending_acceptance")
+				newAssignment.Set("queue_item_id", p.QueueItem.Id)
+				newAssignment.Set("acceptance_token", security.RandomString(acceptanceTokenLength))
+			}
+			newAssignment.Set("weight", 1)
+			newAssignment.Set("source", p.Source)
+			newAssignment.Set("previous_last_assigned_date", previousLastAssigned[i])
+			if p.QueueItem != nil {
+				// Only the first assigned day of a multi-day item takes its ref, since refs are unique.
+				if ref := p.QueueItem.GetString("external_ref"); ref != "" {
+					if taken, err := findAssignmentByExternalRefGo(txDao, ref); err == nil && taken == nil {
+						newAssignment.Set("external_ref", ref)
+					}
+				}
+			}
+			if err := txDao.SaveRecord(newAssignment); err != nil {
+				return fmt.Errorf("failed to save new assignment: %w", err)
+			}
+			newAssignments[i] = newAssignment
+		}
+		// The cursor moves together with the assignment so a restart can't see one without the other.
+		if state.roundRobin {
+			if err := saveRoundRobinCursorGo(txDao, state, todayStart, picks[len(picks)-1].Worker.Id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("ensureDailyAssignmentGo: Error saving new assignment for %s on %s: %v", pick.Worker.GetString("name"), todayYMD, err)
+		return err
+	}
+	for i, p := range picks {
+		workerToAssign := p.Worker
+		newAssignment := newAssignments[i]
+		assignmentSource := assignmentActionTypes[p.Source]
+		log.Printf("ensureDailyAssignmentGo: Assigned worker %s (ID: %s) for %s. Source: %s. ID: %s", workerToAssign.GetString("name"), workerToAssign.Id, todayYMD, assignmentSource, newAssignment.Id)
+		details := map[string]interface{}{"assignment_id": newAssignment.Id, "worker_id": workerToAssign.Id, "worker_name": workerToAssign.GetString("name"), "date": todayYMD, "source": assignmentSource}
+		if p.Coverage != "" {
+			details["coverage"] = p.Coverage
+		}
+		logActionGo(dao, "assigned", details)
+		go runOnAssignCommandGo(workerToAssign, todayYMD, p.Source)
+		when := dutyTimeLabelGo(p.Coverage)
+		if reassignedFromWorkerID != "" {
+			logActionGo(dao, "reassigned_coverage", map[string]interface{}{
+				"assignment_id":          newAssignment.Id,
+				"original_assignment_id": reassignedFromAssignmentID,
+				"worker_id":              workerToAssign.Id,
+				"worker_name":            workerToAssign.GetString("name"),
+				"original_worker_id":     reassignedFromWorkerID,
+				"original_worker_name":   getWorkerNameGo(dao, reassignedFromWorkerID),
+				"date":                   todayYMD,
+			})
+			go notifierGo.announce(workerToAssign, roster.GetString("name"), "Dish duty "+when+" (reassigned)", announcementMessageGo(workerToAssign, todayYMD, p.Source, "You're covering dish duty "+when+" (reassigned)."))
+		} else if pendingAcceptance[i] {
+			answerPath := "/api/dishduty/assignments/" + newAssignment.Id + "/%s?token=" + newAssignment.GetString("acceptance_token")
+			message := fmt.Sprintf("You're up for dish duty %s from the queue. Please accept (POST "+answerPath+") or decline (POST "+answerPath+").", when, "accept", "decline")
+			go notifierGo.announce(workerToAssign, roster.GetString("name"), "Dish duty "+when+" (please confirm)", announcementMessageGo(workerToAssign, todayYMD, p.Source, message))
+		} else {
+			go notifierGo.announce(workerToAssign, roster.GetString("name"), "Dish duty "+when, announcementMessageGo(workerToAssign, todayYMD, p.Source, "You're on dish duty "+when+"."))
+		}
+	}
 	return nil
 }